@@ -18,10 +18,32 @@ const _ = grpc.SupportPackageIsVersion7
 // For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
 type ApiClient interface {
 	Run(ctx context.Context, in *ContainerRequest, opts ...grpc.CallOption) (*ContainerResponse, error)
-	Ps(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ActiveProcesses, error)
+	Ps(ctx context.Context, in *PsRequest, opts ...grpc.CallOption) (*ActiveProcesses, error)
 	Kill(ctx context.Context, in *KillCommand, opts ...grpc.CallOption) (*ContainerResponse, error)
+	Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*ContainerResponse, error)
 	Events(ctx context.Context, in *EventStreamRequest, opts ...grpc.CallOption) (Api_EventsClient, error)
 	RequestStream(ctx context.Context, opts ...grpc.CallOption) (Api_RequestStreamClient, error)
+	ImageImport(ctx context.Context, opts ...grpc.CallOption) (Api_ImageImportClient, error)
+	ImageLs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Images, error)
+	ImageRm(ctx context.Context, in *ImageRemoveRequest, opts ...grpc.CallOption) (*Empty, error)
+	VolumeCreate(ctx context.Context, in *VolumeRequest, opts ...grpc.CallOption) (*Volume, error)
+	VolumeLs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Volumes, error)
+	VolumeInspect(ctx context.Context, in *VolumeRequest, opts ...grpc.CallOption) (*Volume, error)
+	VolumeRm(ctx context.Context, in *VolumeRequest, opts ...grpc.CallOption) (*Empty, error)
+	VolumePrune(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Volumes, error)
+	NetworkCreate(ctx context.Context, in *NetworkRequest, opts ...grpc.CallOption) (*Network, error)
+	NetworkLs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Networks, error)
+	NetworkRm(ctx context.Context, in *NetworkRequest, opts ...grpc.CallOption) (*Empty, error)
+	Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (Api_StatsClient, error)
+	Inspect(ctx context.Context, in *InspectRequest, opts ...grpc.CallOption) (*ContainerInspect, error)
+	Rm(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*Empty, error)
+	PodCreate(ctx context.Context, in *PodRequest, opts ...grpc.CallOption) (*Pod, error)
+	PodLs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Pods, error)
+	PodStop(ctx context.Context, in *PodStopRequest, opts ...grpc.CallOption) (*Empty, error)
+	PodRm(ctx context.Context, in *PodRemoveRequest, opts ...grpc.CallOption) (*Empty, error)
+	Port(ctx context.Context, in *PortRequest, opts ...grpc.CallOption) (*PortMappings, error)
+	Wait(ctx context.Context, in *WaitRequest, opts ...grpc.CallOption) (*ExitStatus, error)
+	Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*ContainerResponse, error)
 }
 
 type apiClient struct {
@@ -41,7 +63,7 @@ func (c *apiClient) Run(ctx context.Context, in *ContainerRequest, opts ...grpc.
 	return out, nil
 }
 
-func (c *apiClient) Ps(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*ActiveProcesses, error) {
+func (c *apiClient) Ps(ctx context.Context, in *PsRequest, opts ...grpc.CallOption) (*ActiveProcesses, error) {
 	out := new(ActiveProcesses)
 	err := c.cc.Invoke(ctx, "/api.Api/Ps", in, out, opts...)
 	if err != nil {
@@ -59,6 +81,15 @@ func (c *apiClient) Kill(ctx context.Context, in *KillCommand, opts ...grpc.Call
 	return out, nil
 }
 
+func (c *apiClient) Stop(ctx context.Context, in *StopRequest, opts ...grpc.CallOption) (*ContainerResponse, error) {
+	out := new(ContainerResponse)
+	err := c.cc.Invoke(ctx, "/api.Api/Stop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *apiClient) Events(ctx context.Context, in *EventStreamRequest, opts ...grpc.CallOption) (Api_EventsClient, error) {
 	stream, err := c.cc.NewStream(ctx, &_Api_serviceDesc.Streams[0], "/api.Api/Events", opts...)
 	if err != nil {
@@ -122,15 +153,274 @@ func (x *apiRequestStreamClient) Recv() (*StreamResponse, error) {
 	return m, nil
 }
 
+func (c *apiClient) ImageImport(ctx context.Context, opts ...grpc.CallOption) (Api_ImageImportClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Api_serviceDesc.Streams[2], "/api.Api/ImageImport", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &apiImageImportClient{stream}
+	return x, nil
+}
+
+type Api_ImageImportClient interface {
+	Send(*ImageChunk) error
+	CloseAndRecv() (*Image, error)
+	grpc.ClientStream
+}
+
+type apiImageImportClient struct {
+	grpc.ClientStream
+}
+
+func (x *apiImageImportClient) Send(m *ImageChunk) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *apiImageImportClient) CloseAndRecv() (*Image, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(Image)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *apiClient) ImageLs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Images, error) {
+	out := new(Images)
+	err := c.cc.Invoke(ctx, "/api.Api/ImageLs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) ImageRm(ctx context.Context, in *ImageRemoveRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/api.Api/ImageRm", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) VolumeCreate(ctx context.Context, in *VolumeRequest, opts ...grpc.CallOption) (*Volume, error) {
+	out := new(Volume)
+	err := c.cc.Invoke(ctx, "/api.Api/VolumeCreate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) VolumeLs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Volumes, error) {
+	out := new(Volumes)
+	err := c.cc.Invoke(ctx, "/api.Api/VolumeLs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) VolumeInspect(ctx context.Context, in *VolumeRequest, opts ...grpc.CallOption) (*Volume, error) {
+	out := new(Volume)
+	err := c.cc.Invoke(ctx, "/api.Api/VolumeInspect", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) VolumeRm(ctx context.Context, in *VolumeRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/api.Api/VolumeRm", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) VolumePrune(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Volumes, error) {
+	out := new(Volumes)
+	err := c.cc.Invoke(ctx, "/api.Api/VolumePrune", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) NetworkCreate(ctx context.Context, in *NetworkRequest, opts ...grpc.CallOption) (*Network, error) {
+	out := new(Network)
+	err := c.cc.Invoke(ctx, "/api.Api/NetworkCreate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) NetworkLs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Networks, error) {
+	out := new(Networks)
+	err := c.cc.Invoke(ctx, "/api.Api/NetworkLs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) NetworkRm(ctx context.Context, in *NetworkRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/api.Api/NetworkRm", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) Stats(ctx context.Context, in *StatsRequest, opts ...grpc.CallOption) (Api_StatsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Api_serviceDesc.Streams[3], "/api.Api/Stats", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &apiStatsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Api_StatsClient interface {
+	Recv() (*StatsSample, error)
+	grpc.ClientStream
+}
+
+type apiStatsClient struct {
+	grpc.ClientStream
+}
+
+func (x *apiStatsClient) Recv() (*StatsSample, error) {
+	m := new(StatsSample)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *apiClient) Inspect(ctx context.Context, in *InspectRequest, opts ...grpc.CallOption) (*ContainerInspect, error) {
+	out := new(ContainerInspect)
+	err := c.cc.Invoke(ctx, "/api.Api/Inspect", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) Rm(ctx context.Context, in *RemoveRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/api.Api/Rm", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) PodCreate(ctx context.Context, in *PodRequest, opts ...grpc.CallOption) (*Pod, error) {
+	out := new(Pod)
+	err := c.cc.Invoke(ctx, "/api.Api/PodCreate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) PodLs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*Pods, error) {
+	out := new(Pods)
+	err := c.cc.Invoke(ctx, "/api.Api/PodLs", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) PodStop(ctx context.Context, in *PodStopRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/api.Api/PodStop", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) PodRm(ctx context.Context, in *PodRemoveRequest, opts ...grpc.CallOption) (*Empty, error) {
+	out := new(Empty)
+	err := c.cc.Invoke(ctx, "/api.Api/PodRm", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) Port(ctx context.Context, in *PortRequest, opts ...grpc.CallOption) (*PortMappings, error) {
+	out := new(PortMappings)
+	err := c.cc.Invoke(ctx, "/api.Api/Port", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) Wait(ctx context.Context, in *WaitRequest, opts ...grpc.CallOption) (*ExitStatus, error) {
+	out := new(ExitStatus)
+	err := c.cc.Invoke(ctx, "/api.Api/Wait", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *apiClient) Exec(ctx context.Context, in *ExecRequest, opts ...grpc.CallOption) (*ContainerResponse, error) {
+	out := new(ContainerResponse)
+	err := c.cc.Invoke(ctx, "/api.Api/Exec", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ApiServer is the server API for Api service.
 // All implementations must embed UnimplementedApiServer
 // for forward compatibility
 type ApiServer interface {
 	Run(context.Context, *ContainerRequest) (*ContainerResponse, error)
-	Ps(context.Context, *Empty) (*ActiveProcesses, error)
+	Ps(context.Context, *PsRequest) (*ActiveProcesses, error)
 	Kill(context.Context, *KillCommand) (*ContainerResponse, error)
+	Stop(context.Context, *StopRequest) (*ContainerResponse, error)
 	Events(*EventStreamRequest, Api_EventsServer) error
 	RequestStream(Api_RequestStreamServer) error
+	ImageImport(Api_ImageImportServer) error
+	ImageLs(context.Context, *Empty) (*Images, error)
+	ImageRm(context.Context, *ImageRemoveRequest) (*Empty, error)
+	VolumeCreate(context.Context, *VolumeRequest) (*Volume, error)
+	VolumeLs(context.Context, *Empty) (*Volumes, error)
+	VolumeInspect(context.Context, *VolumeRequest) (*Volume, error)
+	VolumeRm(context.Context, *VolumeRequest) (*Empty, error)
+	VolumePrune(context.Context, *Empty) (*Volumes, error)
+	NetworkCreate(context.Context, *NetworkRequest) (*Network, error)
+	NetworkLs(context.Context, *Empty) (*Networks, error)
+	NetworkRm(context.Context, *NetworkRequest) (*Empty, error)
+	Stats(*StatsRequest, Api_StatsServer) error
+	Inspect(context.Context, *InspectRequest) (*ContainerInspect, error)
+	Rm(context.Context, *RemoveRequest) (*Empty, error)
+	PodCreate(context.Context, *PodRequest) (*Pod, error)
+	PodLs(context.Context, *Empty) (*Pods, error)
+	PodStop(context.Context, *PodStopRequest) (*Empty, error)
+	PodRm(context.Context, *PodRemoveRequest) (*Empty, error)
+	Port(context.Context, *PortRequest) (*PortMappings, error)
+	Wait(context.Context, *WaitRequest) (*ExitStatus, error)
+	Exec(context.Context, *ExecRequest) (*ContainerResponse, error)
 	mustEmbedUnimplementedApiServer()
 }
 
@@ -141,18 +431,84 @@ type UnimplementedApiServer struct {
 func (UnimplementedApiServer) Run(context.Context, *ContainerRequest) (*ContainerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Run not implemented")
 }
-func (UnimplementedApiServer) Ps(context.Context, *Empty) (*ActiveProcesses, error) {
+func (UnimplementedApiServer) Ps(context.Context, *PsRequest) (*ActiveProcesses, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Ps not implemented")
 }
 func (UnimplementedApiServer) Kill(context.Context, *KillCommand) (*ContainerResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Kill not implemented")
 }
+func (UnimplementedApiServer) Stop(context.Context, *StopRequest) (*ContainerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Stop not implemented")
+}
 func (UnimplementedApiServer) Events(*EventStreamRequest, Api_EventsServer) error {
 	return status.Errorf(codes.Unimplemented, "method Events not implemented")
 }
 func (UnimplementedApiServer) RequestStream(Api_RequestStreamServer) error {
 	return status.Errorf(codes.Unimplemented, "method RequestStream not implemented")
 }
+func (UnimplementedApiServer) ImageImport(Api_ImageImportServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImageImport not implemented")
+}
+func (UnimplementedApiServer) ImageLs(context.Context, *Empty) (*Images, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImageLs not implemented")
+}
+func (UnimplementedApiServer) ImageRm(context.Context, *ImageRemoveRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ImageRm not implemented")
+}
+func (UnimplementedApiServer) VolumeCreate(context.Context, *VolumeRequest) (*Volume, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeCreate not implemented")
+}
+func (UnimplementedApiServer) VolumeLs(context.Context, *Empty) (*Volumes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeLs not implemented")
+}
+func (UnimplementedApiServer) VolumeInspect(context.Context, *VolumeRequest) (*Volume, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeInspect not implemented")
+}
+func (UnimplementedApiServer) VolumeRm(context.Context, *VolumeRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumeRm not implemented")
+}
+func (UnimplementedApiServer) VolumePrune(context.Context, *Empty) (*Volumes, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method VolumePrune not implemented")
+}
+func (UnimplementedApiServer) NetworkCreate(context.Context, *NetworkRequest) (*Network, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NetworkCreate not implemented")
+}
+func (UnimplementedApiServer) NetworkLs(context.Context, *Empty) (*Networks, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NetworkLs not implemented")
+}
+func (UnimplementedApiServer) NetworkRm(context.Context, *NetworkRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method NetworkRm not implemented")
+}
+func (UnimplementedApiServer) Stats(*StatsRequest, Api_StatsServer) error {
+	return status.Errorf(codes.Unimplemented, "method Stats not implemented")
+}
+func (UnimplementedApiServer) Inspect(context.Context, *InspectRequest) (*ContainerInspect, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Inspect not implemented")
+}
+func (UnimplementedApiServer) Rm(context.Context, *RemoveRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Rm not implemented")
+}
+func (UnimplementedApiServer) PodCreate(context.Context, *PodRequest) (*Pod, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PodCreate not implemented")
+}
+func (UnimplementedApiServer) PodLs(context.Context, *Empty) (*Pods, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PodLs not implemented")
+}
+func (UnimplementedApiServer) PodStop(context.Context, *PodStopRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PodStop not implemented")
+}
+func (UnimplementedApiServer) PodRm(context.Context, *PodRemoveRequest) (*Empty, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PodRm not implemented")
+}
+func (UnimplementedApiServer) Port(context.Context, *PortRequest) (*PortMappings, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Port not implemented")
+}
+func (UnimplementedApiServer) Wait(context.Context, *WaitRequest) (*ExitStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Wait not implemented")
+}
+func (UnimplementedApiServer) Exec(context.Context, *ExecRequest) (*ContainerResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Exec not implemented")
+}
 func (UnimplementedApiServer) mustEmbedUnimplementedApiServer() {}
 
 // UnsafeApiServer may be embedded to opt out of forward compatibility for this service.
@@ -185,7 +541,7 @@ func _Api_Run_Handler(srv interface{}, ctx context.Context, dec func(interface{}
 }
 
 func _Api_Ps_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
-	in := new(Empty)
+	in := new(PsRequest)
 	if err := dec(in); err != nil {
 		return nil, err
 	}
@@ -197,7 +553,7 @@ func _Api_Ps_Handler(srv interface{}, ctx context.Context, dec func(interface{})
 		FullMethod: "/api.Api/Ps",
 	}
 	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
-		return srv.(ApiServer).Ps(ctx, req.(*Empty))
+		return srv.(ApiServer).Ps(ctx, req.(*PsRequest))
 	}
 	return interceptor(ctx, in, info, handler)
 }
@@ -220,6 +576,24 @@ func _Api_Kill_Handler(srv interface{}, ctx context.Context, dec func(interface{
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Api_Stop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).Stop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/Stop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).Stop(ctx, req.(*StopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _Api_Events_Handler(srv interface{}, stream grpc.ServerStream) error {
 	m := new(EventStreamRequest)
 	if err := stream.RecvMsg(m); err != nil {
@@ -267,34 +641,513 @@ func (x *apiRequestStreamServer) Recv() (*StreamRequest, error) {
 	return m, nil
 }
 
-var _Api_serviceDesc = grpc.ServiceDesc{
-	ServiceName: "api.Api",
-	HandlerType: (*ApiServer)(nil),
-	Methods: []grpc.MethodDesc{
-		{
-			MethodName: "Run",
-			Handler:    _Api_Run_Handler,
-		},
-		{
-			MethodName: "Ps",
-			Handler:    _Api_Ps_Handler,
-		},
-		{
-			MethodName: "Kill",
-			Handler:    _Api_Kill_Handler,
-		},
-	},
-	Streams: []grpc.StreamDesc{
-		{
-			StreamName:    "Events",
-			Handler:       _Api_Events_Handler,
-			ServerStreams: true,
-		},
-		{
-			StreamName:    "RequestStream",
-			Handler:       _Api_RequestStream_Handler,
-			ServerStreams: true,
-			ClientStreams: true,
+func _Api_ImageImport_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ApiServer).ImageImport(&apiImageImportServer{stream})
+}
+
+type Api_ImageImportServer interface {
+	SendAndClose(*Image) error
+	Recv() (*ImageChunk, error)
+	grpc.ServerStream
+}
+
+type apiImageImportServer struct {
+	grpc.ServerStream
+}
+
+func (x *apiImageImportServer) SendAndClose(m *Image) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *apiImageImportServer) Recv() (*ImageChunk, error) {
+	m := new(ImageChunk)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Api_ImageLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).ImageLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/ImageLs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).ImageLs(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_ImageRm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ImageRemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).ImageRm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/ImageRm",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).ImageRm(ctx, req.(*ImageRemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_VolumeCreate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).VolumeCreate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/VolumeCreate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).VolumeCreate(ctx, req.(*VolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_VolumeLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).VolumeLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/VolumeLs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).VolumeLs(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_VolumeInspect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).VolumeInspect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/VolumeInspect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).VolumeInspect(ctx, req.(*VolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_VolumeRm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VolumeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).VolumeRm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/VolumeRm",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).VolumeRm(ctx, req.(*VolumeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_VolumePrune_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).VolumePrune(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/VolumePrune",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).VolumePrune(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_NetworkCreate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NetworkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).NetworkCreate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/NetworkCreate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).NetworkCreate(ctx, req.(*NetworkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_NetworkLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).NetworkLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/NetworkLs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).NetworkLs(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_NetworkRm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(NetworkRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).NetworkRm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/NetworkRm",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).NetworkRm(ctx, req.(*NetworkRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_Stats_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StatsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ApiServer).Stats(m, &apiStatsServer{stream})
+}
+
+type Api_StatsServer interface {
+	Send(*StatsSample) error
+	grpc.ServerStream
+}
+
+type apiStatsServer struct {
+	grpc.ServerStream
+}
+
+func (x *apiStatsServer) Send(m *StatsSample) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Api_Inspect_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InspectRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).Inspect(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/Inspect",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).Inspect(ctx, req.(*InspectRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_Rm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).Rm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/Rm",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).Rm(ctx, req.(*RemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_PodCreate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PodRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).PodCreate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/PodCreate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).PodCreate(ctx, req.(*PodRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_PodLs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).PodLs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/PodLs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).PodLs(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_PodStop_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PodStopRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).PodStop(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/PodStop",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).PodStop(ctx, req.(*PodStopRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_PodRm_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PodRemoveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).PodRm(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/PodRm",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).PodRm(ctx, req.(*PodRemoveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_Port_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PortRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).Port(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/Port",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).Port(ctx, req.(*PortRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_Wait_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WaitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).Wait(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/Wait",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).Wait(ctx, req.(*WaitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Api_Exec_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ExecRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ApiServer).Exec(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Api/Exec",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ApiServer).Exec(ctx, req.(*ExecRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Api_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "api.Api",
+	HandlerType: (*ApiServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Run",
+			Handler:    _Api_Run_Handler,
+		},
+		{
+			MethodName: "Ps",
+			Handler:    _Api_Ps_Handler,
+		},
+		{
+			MethodName: "Kill",
+			Handler:    _Api_Kill_Handler,
+		},
+		{
+			MethodName: "Stop",
+			Handler:    _Api_Stop_Handler,
+		},
+		{
+			MethodName: "ImageLs",
+			Handler:    _Api_ImageLs_Handler,
+		},
+		{
+			MethodName: "ImageRm",
+			Handler:    _Api_ImageRm_Handler,
+		},
+		{
+			MethodName: "VolumeCreate",
+			Handler:    _Api_VolumeCreate_Handler,
+		},
+		{
+			MethodName: "VolumeLs",
+			Handler:    _Api_VolumeLs_Handler,
+		},
+		{
+			MethodName: "VolumeInspect",
+			Handler:    _Api_VolumeInspect_Handler,
+		},
+		{
+			MethodName: "VolumeRm",
+			Handler:    _Api_VolumeRm_Handler,
+		},
+		{
+			MethodName: "VolumePrune",
+			Handler:    _Api_VolumePrune_Handler,
+		},
+		{
+			MethodName: "NetworkCreate",
+			Handler:    _Api_NetworkCreate_Handler,
+		},
+		{
+			MethodName: "NetworkLs",
+			Handler:    _Api_NetworkLs_Handler,
+		},
+		{
+			MethodName: "NetworkRm",
+			Handler:    _Api_NetworkRm_Handler,
+		},
+		{
+			MethodName: "Inspect",
+			Handler:    _Api_Inspect_Handler,
+		},
+		{
+			MethodName: "Rm",
+			Handler:    _Api_Rm_Handler,
+		},
+		{
+			MethodName: "PodCreate",
+			Handler:    _Api_PodCreate_Handler,
+		},
+		{
+			MethodName: "PodLs",
+			Handler:    _Api_PodLs_Handler,
+		},
+		{
+			MethodName: "PodStop",
+			Handler:    _Api_PodStop_Handler,
+		},
+		{
+			MethodName: "PodRm",
+			Handler:    _Api_PodRm_Handler,
+		},
+		{
+			MethodName: "Port",
+			Handler:    _Api_Port_Handler,
+		},
+		{
+			MethodName: "Wait",
+			Handler:    _Api_Wait_Handler,
+		},
+		{
+			MethodName: "Exec",
+			Handler:    _Api_Exec_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Events",
+			Handler:       _Api_Events_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "RequestStream",
+			Handler:       _Api_RequestStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "ImageImport",
+			Handler:       _Api_ImageImport_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Stats",
+			Handler:       _Api_Stats_Handler,
+			ServerStreams: true,
 		},
 	},
 	Metadata: "api/api.proto",
@@ -0,0 +1,200 @@
+package cgroup
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	mountPoint    = "/sys/fs/cgroup"
+	rootGroup     = "cont"   // parent of all container cgroups when running as root
+	daemonGroup   = "daemon" // leaf the rootless daemon moves itself into
+	defaultPeriod = 100000   // cpu.max period in microseconds
+	removeTimeout = time.Second
+)
+
+var controllers = []string{"cpu", "io", "memory", "pids"}
+
+// Resources are container resource limits. Zero values mean no limit.
+type Resources struct {
+	Memory int64    // memory.max in bytes
+	CPUs   float64  // number of CPUs, converted to a cpu.max quota
+	Pids   int64    // pids.max
+	IO     []string // io.max lines, e.g. "8:0 rbps=1048576 wiops=120"
+}
+
+// Cgroup is a cgroup v2 directory of a single container.
+type Cgroup struct {
+	Path string
+}
+
+// Parent returns the delegated cgroup subtree container cgroups are created in. Rootless daemons use their own cgroup
+// and move themselves into a leaf, cgroup v2 doesn't allow processes in cgroups with child controllers.
+func Parent() (string, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(mountPoint, &stat); err != nil {
+		return "", fmt.Errorf("cannot statfs %s: %w", mountPoint, err)
+	}
+	if stat.Type != unix.CGROUP2_SUPER_MAGIC {
+		return "", fmt.Errorf("cgroup v2 isn't mounted on %s", mountPoint)
+	}
+	if os.Geteuid() == 0 {
+		parent := filepath.Join(mountPoint, rootGroup)
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return "", fmt.Errorf("cannot create cgroup %s: %w", parent, err)
+		}
+		enableControllers(mountPoint)
+		enableControllers(parent)
+		return parent, nil
+	}
+
+	current, err := currentGroup()
+	if err != nil {
+		return "", err
+	}
+	if filepath.Base(current) == daemonGroup { // already moved
+		current = filepath.Dir(current)
+	}
+	parent := filepath.Join(mountPoint, current)
+	leaf := filepath.Join(parent, daemonGroup)
+	if err := os.MkdirAll(leaf, 0755); err != nil {
+		return "", fmt.Errorf("cannot create cgroup %s (is the cgroup delegated?): %w", leaf, err)
+	}
+	if err := writeFile(leaf, "cgroup.procs", strconv.Itoa(os.Getpid())); err != nil {
+		return "", fmt.Errorf("cannot move the daemon into %s: %w", leaf, err)
+	}
+	enableControllers(parent)
+	return parent, nil
+}
+
+// New creates a cgroup in parent and applies resource limits. An empty cgroup left by a previous run of the
+// container is reused.
+func New(parent, name string, resources Resources) (*Cgroup, error) {
+	c := &Cgroup{Path: filepath.Join(parent, name)}
+	if err := os.Mkdir(c.Path, 0755); err != nil && !(os.IsExist(err) && c.empty()) {
+		return nil, fmt.Errorf("cannot create cgroup: %w", err)
+	}
+	if err := c.apply(resources); err != nil {
+		_ = c.Remove()
+		return nil, err
+	}
+	return c, nil
+}
+
+// AddProcess moves a process into the cgroup.
+func (c *Cgroup) AddProcess(pid int) error {
+	if err := writeFile(c.Path, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+		return fmt.Errorf("cannot add process %d to cgroup: %w", pid, err)
+	}
+	return nil
+}
+
+// Remove kills the processes left in the cgroup and removes it. The cgroup stays busy while they exit.
+func (c *Cgroup) Remove() error {
+	_ = writeFile(c.Path, "cgroup.kill", "1") // Linux 5.14+
+	deadline := time.Now().Add(removeTimeout)
+	for {
+		err := os.Remove(c.Path)
+		if err == nil || os.IsNotExist(err) {
+			return nil
+		}
+		if !errors.Is(err, unix.EBUSY) || time.Now().After(deadline) {
+			return fmt.Errorf("cannot remove cgroup: %w", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (c *Cgroup) empty() bool {
+	procs, err := ioutil.ReadFile(filepath.Join(c.Path, "cgroup.procs"))
+	return err == nil && len(bytes.TrimSpace(procs)) == 0
+}
+
+func (c *Cgroup) apply(resources Resources) error {
+	if resources.Memory > 0 {
+		if err := writeFile(c.Path, "memory.max", strconv.FormatInt(resources.Memory, 10)); err != nil {
+			return err
+		}
+	}
+	if resources.CPUs > 0 {
+		quota := int64(resources.CPUs * defaultPeriod)
+		if err := writeFile(c.Path, "cpu.max", fmt.Sprintf("%d %d", quota, defaultPeriod)); err != nil {
+			return err
+		}
+	}
+	if resources.Pids > 0 {
+		if err := writeFile(c.Path, "pids.max", strconv.FormatInt(resources.Pids, 10)); err != nil {
+			return err
+		}
+	}
+	for _, line := range resources.IO {
+		if err := writeFile(c.Path, "io.max", line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// enableControllers delegates all available controllers we use to children of dir.
+func enableControllers(dir string) {
+	available, err := ioutil.ReadFile(filepath.Join(dir, "cgroup.controllers"))
+	if err != nil {
+		log.Printf("cannot read available controllers of %s: %v", dir, err)
+		return
+	}
+	for _, controller := range controllers {
+		if !contains(strings.Fields(string(available)), controller) {
+			log.Printf("cgroup controller %s isn't available in %s", controller, dir)
+			continue
+		}
+		if err := writeFile(dir, "cgroup.subtree_control", "+"+controller); err != nil {
+			log.Printf("cannot enable cgroup controller %s in %s: %v", controller, dir, err)
+		}
+	}
+}
+
+// currentGroup returns the cgroup v2 path of the current process, relative to the cgroup mount.
+func currentGroup() (string, error) {
+	file, err := os.Open("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "0::") {
+			return strings.TrimPrefix(scanner.Text(), "0::"), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("cgroup v2 isn't mounted")
+}
+
+func writeFile(dir, file, value string) error {
+	if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+		return fmt.Errorf("cannot write %s: %w", file, err)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package cgroup
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Stats is a resource usage sample of a cgroup.
+type Stats struct {
+	Memory      int64 // memory.current in bytes
+	MemoryLimit int64 // memory.max in bytes, 0 if unlimited
+	CPUUsage    int64 // total CPU time in microseconds
+	IORead      int64 // bytes read from all devices
+	IOWrite     int64 // bytes written to all devices
+	Pids        int64 // pids.current
+}
+
+// Stats reads the current resource usage of the cgroup. Files of controllers which aren't enabled are skipped.
+func (c *Cgroup) Stats() (*Stats, error) {
+	var stats Stats
+	var err error
+	if stats.Memory, err = readInt(c.Path, "memory.current"); err != nil {
+		return nil, err
+	}
+	if stats.MemoryLimit, err = readInt(c.Path, "memory.max"); err != nil {
+		return nil, err
+	}
+	if stats.Pids, err = readInt(c.Path, "pids.current"); err != nil {
+		return nil, err
+	}
+
+	cpu, err := readKeyValues(filepath.Join(c.Path, "cpu.stat"))
+	if err != nil {
+		return nil, err
+	}
+	stats.CPUUsage = cpu["usage_usec"]
+
+	if err := readIOStat(c.Path, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// readInt reads a single value cgroup file. Missing files and "max" are reported as 0.
+func readInt(dir, file string) (int64, error) {
+	content, err := ioutil.ReadFile(filepath.Join(dir, file))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	value := strings.TrimSpace(string(content))
+	if value == "max" {
+		return 0, nil
+	}
+	result, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse %s: %w", file, err)
+	}
+	return result, nil
+}
+
+// readKeyValues reads flat keyed cgroup files such as cpu.stat ("usage_usec 1234" lines).
+func readKeyValues(path string) (map[string]int64, error) {
+	result := make(map[string]int64)
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return result, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		value, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		result[fields[0]] = value
+	}
+	return result, scanner.Err()
+}
+
+// readIOStat sums read and written bytes of all devices in io.stat ("8:0 rbytes=1 wbytes=2 rios=3 ..." lines).
+func readIOStat(dir string, stats *Stats) error {
+	file, err := os.Open(filepath.Join(dir, "io.stat"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		for _, field := range fields[1:] {
+			parts := strings.SplitN(field, "=", 2)
+			if len(parts) != 2 {
+				continue
+			}
+			value, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				continue
+			}
+			switch parts[0] {
+			case "rbytes":
+				stats.IORead += value
+			case "wbytes":
+				stats.IOWrite += value
+			}
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,61 @@
+package cgroup
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for file, content := range map[string]string{
+		"memory.current": "1048576\n",
+		"memory.max":     "max\n",
+		"pids.current":   "3\n",
+		"cpu.stat":       "usage_usec 250000\nuser_usec 200000\nsystem_usec 50000\nnr_periods 0\n",
+		"io.stat": "8:0 rbytes=4096 wbytes=8192 rios=1 wios=2 dbytes=0 dios=0\n" +
+			"8:16 rbytes=100 wbytes=0 rios=1 wios=0 dbytes=0 dios=0\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stats, err := (&Cgroup{Path: dir}).Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Stats{Memory: 1 << 20, CPUUsage: 250000, IORead: 4196, IOWrite: 8192, Pids: 3}
+	if *stats != expected {
+		t.Errorf("Stats = %+v, expected %+v", *stats, expected)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "memory.max"), []byte("536870912\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"cpu.stat", "io.stat", "pids.current"} {
+		if err := os.Remove(filepath.Join(dir, file)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if stats, err = (&Cgroup{Path: dir}).Stats(); err != nil {
+		t.Fatal(err)
+	}
+	expected = Stats{Memory: 1 << 20, MemoryLimit: 512 << 20}
+	if *stats != expected {
+		t.Errorf("Stats without the cpu, io and pids controllers = %+v, expected %+v", *stats, expected)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "memory.current"), []byte("lots\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&Cgroup{Path: dir}).Stats(); err == nil {
+		t.Error("Stats didn't fail on an invalid memory.current")
+	}
+}
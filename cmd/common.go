@@ -26,12 +26,22 @@ func GrpcDial() (*grpc.ClientConn, error) {
 	return grpc.Dial(target+ApiPort, grpc.WithInsecure())
 }
 
+// withClient dials the daemon and calls f with an API client, closing the connection afterwards.
+func withClient(f func(client api.ApiClient)) {
+	conn, err := GrpcDial()
+	must(err)
+	defer conn.Close()
+
+	f(api.NewApiClient(conn))
+}
+
 const (
 	Failed int32 = iota
 	Created
 	Started
 	Done
 	Killed // todo: make a distinction between done and killed
+	Restarted
 )
 
 func handleEvents(client api.ApiClient, signals chan os.Signal, started chan bool, containerID []byte) {
@@ -47,6 +57,10 @@ func handleEvents(client api.ApiClient, signals chan os.Signal, started chan boo
 			signals <- syscall.SIGTERM
 			return
 		}
+		if event.Type == Failed { // the container never started, there's nothing to wait for
+			_, _ = fmt.Fprintf(os.Stderr, "failed: %s\n", event.Data)
+			os.Exit(1)
+		}
 		if event.Type == Started {
 			started <- true
 		}
@@ -60,6 +74,13 @@ func handleEvents(client api.ApiClient, signals chan os.Signal, started chan boo
 	}
 }
 
+// exitWithContainer waits for the container to exit and exits with its exit code.
+func exitWithContainer(client api.ApiClient, containerID uuid.UUID) {
+	status, err := client.Wait(context.Background(), &api.WaitRequest{Id: []byte(containerID.String())})
+	must(err)
+	os.Exit(int(status.ExitCode))
+}
+
 func closePipes(stdin, stdout, stderr io.ReadWriteCloser) {
 	stdin.Close()
 	stdout.Close()
@@ -98,7 +119,6 @@ func setupInteractive(wg *sync.WaitGroup, stdin, stdout io.ReadWriteCloser) {
 				return
 			}
 		}
-		wg.Done()
 	}()
 }
 
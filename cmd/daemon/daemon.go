@@ -6,16 +6,39 @@ import (
 	"cont/container"
 	"cont/daemon"
 	"cont/multiplex"
+	"errors"
+	"flag"
 	"google.golang.org/grpc"
 	"net"
 	"os"
+	"os/exec"
+	"syscall"
 )
 
 func main() {
 	if len(os.Args) >= 2 && os.Args[1] == "init" {
-		must(container.RunChild())
+		exitWithCommand(container.RunChild())
 		return
 	}
+	if len(os.Args) >= 2 && os.Args[1] == "exec" {
+		exitWithCommand(container.RunExec())
+		return
+	}
+	if len(os.Args) >= 2 && os.Args[1] == "tap" {
+		must(container.RunTap())
+		return
+	}
+	if len(os.Args) >= 2 && os.Args[1] == "nethelper" {
+		must(container.RunNetHelper())
+		return
+	}
+	if len(os.Args) >= 2 && os.Args[1] == "pause" {
+		container.Pause()
+		return
+	}
+	stateDir := flag.String("state", daemon.DefaultStateDir(), "directory to keep daemon state in")
+	flag.Parse()
+
 	listen, err := net.Listen("tcp", cmd.ApiPort)
 	must(err)
 	defer listen.Close()
@@ -25,7 +48,7 @@ func main() {
 
 	muxClient := multiplex.NewClient()
 
-	daemonServer, err := daemon.NewServer(muxClient, streamListener)
+	daemonServer, err := daemon.NewServer(muxClient, streamListener, *stateDir)
 	must(err)
 
 	s := grpc.NewServer()
@@ -33,6 +56,22 @@ func main() {
 	must(s.Serve(listen))
 }
 
+// exitWithCommand exits with the exit code of the command init or exec ran, 128 + signal number if it was killed.
+func exitWithCommand(err error) {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) { // the command failed, not init or exec
+		if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+			os.Exit(128 + int(status.Signal()))
+		}
+		os.Exit(exitErr.ExitCode())
+	}
+	var initErr *container.ExitError
+	if errors.As(err, &initErr) {
+		os.Exit(initErr.ExitCode())
+	}
+	must(err)
+}
+
 func must(err error) {
 	if err != nil {
 		panic(err)
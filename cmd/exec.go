@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"cont/api"
+	"context"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var execCmd = &cobra.Command{
+	Use:   "exec <container_id> cmd [args...]",
+	Short: "run a command inside a running container",
+	Long:  "Run a command in all namespaces of a running container, with its own stdin, stdout & stderr streams.",
+	Args:  cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		containerID, err := uuid.Parse(args[0])
+		must(err)
+		containerIDBytes, err := containerID.MarshalBinary()
+		must(err)
+
+		clientID := uuid.New()
+		clientIDBytes, err := clientID.MarshalBinary()
+		must(err)
+
+		conn, err := GrpcDial()
+		must(err)
+		defer conn.Close()
+
+		host, err := cmd.Flags().GetString("host")
+		must(err)
+
+		isInteractive, err := cmd.Flags().GetBool("it")
+		must(err)
+
+		workdir, err := cmd.Flags().GetString("workdir")
+		must(err)
+
+		isLocal := host == Localhost
+
+		client := api.NewApiClient(conn)
+		response, err := client.Exec(context.Background(), &api.ExecRequest{
+			Id:          containerIDBytes,
+			Cmd:         args[1],
+			Args:        args[2:],
+			Workdir:     workdir,
+			Interactive: isInteractive,
+		})
+		must(err)
+
+		signals := make(chan os.Signal, 1)
+		started := make(chan bool, 1)
+
+		go handleEvents(client, signals, started, response.Uuid)
+
+		execID, err := uuid.FromBytes(response.Uuid)
+		must(err)
+
+		var stdin, stdout, stderr io.ReadWriteCloser
+		if isLocal {
+			pipes := setupLocalPipes(execID, started)
+
+			stdin = pipes[0]
+			stdout = pipes[1]
+			stderr = pipes[2]
+		} else {
+			<-started
+			stdin, stdout, stderr = setupRemotePipes(client, clientID, clientIDBytes, response.Uuid)
+		}
+		defer closePipes(stdin, stdout, stderr)
+
+		var wg sync.WaitGroup
+
+		if isInteractive {
+			setupInteractive(&wg, stdin, stdout)
+		} else {
+			attachOutput(&wg, stdout, stderr)
+			signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+			go func() {
+				<-signals
+				closePipes(stdin, stdout, stderr)
+				os.Exit(0)
+			}()
+		}
+		wg.Wait()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+
+	execCmd.Flags().SetInterspersed(false) // flags after the container ID belong to the command
+
+	execCmd.Flags().Bool("it", false, "determines whether to connect stdin with the process stdin")
+	execCmd.Flags().String("workdir", "", "sets the process workdir, container root by default")
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"cont/api"
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+const imageChunkSize = 64 * 1024
+
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "manage images",
+}
+
+var imageImportCmd = &cobra.Command{
+	Use:   "import <tar>",
+	Short: "import an OCI image layout or a docker save tarball",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name, err := cmd.Flags().GetString("name")
+		must(err)
+
+		archive, err := os.Open(args[0])
+		must(err)
+		defer archive.Close()
+
+		conn, err := GrpcDial()
+		must(err)
+		defer conn.Close()
+
+		client := api.NewApiClient(conn)
+		importClient, err := client.ImageImport(context.Background())
+		must(err)
+
+		buffer := make([]byte, imageChunkSize)
+		for {
+			n, err := archive.Read(buffer)
+			if n > 0 {
+				must(importClient.Send(&api.ImageChunk{Name: name, Data: buffer[:n]}))
+				name = ""
+			}
+			if err == io.EOF {
+				break
+			}
+			must(err)
+		}
+		img, err := importClient.CloseAndRecv()
+		must(err)
+
+		fmt.Println(img.Id)
+	},
+}
+
+var imageLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "list images",
+	Run: func(cmd *cobra.Command, args []string) {
+		conn, err := GrpcDial()
+		must(err)
+		defer conn.Close()
+
+		client := api.NewApiClient(conn)
+		images, err := client.ImageLs(context.Background(), &api.Empty{})
+		must(err)
+
+		must(printImages(images))
+	},
+}
+
+var imageRmCmd = &cobra.Command{
+	Use:   "rm <image>",
+	Short: "remove an image",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		conn, err := GrpcDial()
+		must(err)
+		defer conn.Close()
+
+		client := api.NewApiClient(conn)
+		_, err = client.ImageRm(context.Background(), &api.ImageRemoveRequest{Ref: args[0]})
+		must(err)
+	},
+}
+
+func printImages(images *api.Images) error {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"ID", "NAMES", "SIZE", "CREATED"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	for _, img := range images.Images {
+		id := strings.TrimPrefix(img.Id, "sha256:")
+		if len(id) > 12 {
+			id = id[:12]
+		}
+		table.Append([]string{id, strings.Join(img.Names, ", "), formatSize(img.Size), time.Unix(img.Created, 0).Format(time.RFC3339)})
+	}
+	table.Render()
+	return nil
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%dB", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+func init() {
+	rootCmd.AddCommand(imageCmd)
+	imageCmd.AddCommand(imageImportCmd, imageLsCmd, imageRmCmd)
+
+	imageImportCmd.Flags().String("name", "", "additional image name, e.g. alpine:3.12")
+}
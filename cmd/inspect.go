@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"bytes"
+	"cont/api"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/cobra"
+)
+
+var inspectCmd = &cobra.Command{
+	Use:   "inspect <container_id>",
+	Short: "show container spec & state",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withClient(func(client api.ApiClient) {
+			response, err := client.Inspect(context.Background(), &api.InspectRequest{Id: []byte(args[0])})
+			must(err)
+
+			var state bytes.Buffer
+			must(json.Indent(&state, []byte(response.State), "", "  "))
+			fmt.Println(state.String())
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(inspectCmd)
+}
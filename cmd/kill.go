@@ -11,11 +11,14 @@ var killCmd = &cobra.Command{
 	Use:   "kill",
 	Short: "kill a container by its ID",
 	Run: func(cmd *cobra.Command, args []string) {
+		signal, err := cmd.Flags().GetString("signal")
+		must(err)
+
 		conn, err := GrpcDial()
 		must(err)
 
 		client := api.NewApiClient(conn)
-		response, err := client.Kill(context.Background(), &api.KillCommand{Id: []byte(args[0])})
+		response, err := client.Kill(context.Background(), &api.KillCommand{Id: []byte(args[0]), Signal: signal})
 		must(err)
 
 		fmt.Print(response)
@@ -24,4 +27,6 @@ var killCmd = &cobra.Command{
 
 func init() {
 	rootCmd.AddCommand(killCmd)
+
+	killCmd.Flags().StringP("signal", "s", "", "signal to send to the container (e.g. SIGHUP, HUP or 1), SIGKILL by default")
 }
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"cont/api"
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+	"time"
+)
+
+var networkCmd = &cobra.Command{
+	Use:   "network",
+	Short: "manage bridge networks",
+}
+
+var networkCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "create a bridge network",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		subnet, err := cmd.Flags().GetString("subnet")
+		must(err)
+		withClient(func(client api.ApiClient) {
+			n, err := client.NetworkCreate(context.Background(), &api.NetworkRequest{Name: args[0], Subnet: subnet})
+			must(err)
+			fmt.Println(n.Name)
+		})
+	},
+}
+
+var networkLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "list networks",
+	Run: func(cmd *cobra.Command, args []string) {
+		withClient(func(client api.ApiClient) {
+			networks, err := client.NetworkLs(context.Background(), &api.Empty{})
+			must(err)
+			must(printNetworks(networks))
+		})
+	},
+}
+
+var networkRmCmd = &cobra.Command{
+	Use:   "rm <name>...",
+	Short: "remove networks without connected containers",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withClient(func(client api.ApiClient) {
+			for _, name := range args {
+				_, err := client.NetworkRm(context.Background(), &api.NetworkRequest{Name: name})
+				must(err)
+			}
+		})
+	},
+}
+
+func printNetworks(networks *api.Networks) error {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"NAME", "BRIDGE", "SUBNET", "GATEWAY", "CREATED", "CONTAINERS"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	for _, n := range networks.Networks {
+		table.Append([]string{
+			n.Name,
+			n.Bridge,
+			n.Subnet,
+			n.Gateway,
+			time.Unix(n.Created, 0).Format(time.RFC3339),
+			strconv.Itoa(len(n.Containers)),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(networkCmd)
+	networkCmd.AddCommand(networkCreateCmd, networkLsCmd, networkRmCmd)
+	networkCreateCmd.Flags().String("subnet", "", "sets the network subnet (CIDR), a free 10.x.0.0/16 by default")
+}
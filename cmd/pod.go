@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"cont/api"
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"os"
+	"strconv"
+	"time"
+)
+
+var podCmd = &cobra.Command{
+	Use:   "pod",
+	Short: "manage pods, groups of containers sharing namespaces",
+}
+
+var podCreateCmd = &cobra.Command{
+	Use:   "create <name>",
+	Short: "create a pod",
+	Long:  "Create a pod and start its infra container, which owns the pod namespaces. Containers run with --pod join them.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		hostname, err := cmd.Flags().GetString("hostname")
+		must(err)
+		network, err := cmd.Flags().GetString("network")
+		must(err)
+		ports, err := parsePorts(cmd)
+		must(err)
+		dns, err := cmd.Flags().GetStringArray("dns")
+		must(err)
+		dnsSearch, err := cmd.Flags().GetStringArray("dns-search")
+		must(err)
+		extraHosts, err := cmd.Flags().GetStringArray("add-host")
+		must(err)
+
+		withClient(func(client api.ApiClient) {
+			pod, err := client.PodCreate(context.Background(), &api.PodRequest{
+				Name:       args[0],
+				Hostname:   hostname,
+				Network:    network,
+				Ports:      ports,
+				Dns:        dns,
+				DnsSearch:  dnsSearch,
+				ExtraHosts: extraHosts,
+			})
+			must(err)
+			fmt.Println(pod.Name)
+		})
+	},
+}
+
+var podLsCmd = &cobra.Command{
+	Use:     "ps",
+	Aliases: []string{"ls"},
+	Short:   "list pods",
+	Run: func(cmd *cobra.Command, args []string) {
+		withClient(func(client api.ApiClient) {
+			pods, err := client.PodLs(context.Background(), &api.Empty{})
+			must(err)
+			must(printPods(pods))
+		})
+	},
+}
+
+var podStopCmd = &cobra.Command{
+	Use:   "stop <name>...",
+	Short: "stop the containers of pods and then their infra containers",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, err := cmd.Flags().GetInt64("time")
+		must(err)
+
+		withClient(func(client api.ApiClient) {
+			for _, name := range args {
+				_, err := client.PodStop(context.Background(), &api.PodStopRequest{Name: name, Timeout: timeout})
+				must(err)
+			}
+		})
+	},
+}
+
+var podRmCmd = &cobra.Command{
+	Use:   "rm <name>...",
+	Short: "remove stopped pods with their containers",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, err := cmd.Flags().GetBool("force")
+		must(err)
+
+		withClient(func(client api.ApiClient) {
+			for _, name := range args {
+				_, err := client.PodRm(context.Background(), &api.PodRemoveRequest{Name: name, Force: force})
+				must(err)
+			}
+		})
+	},
+}
+
+func printPods(pods *api.Pods) error {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"NAME", "STATUS", "INFRA ID", "NETWORK", "CREATED", "CONTAINERS"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	for _, p := range pods.Pods {
+		table.Append([]string{
+			p.Name,
+			p.Status,
+			p.InfraId,
+			p.Network,
+			time.Unix(p.Created, 0).Format(time.RFC3339),
+			strconv.Itoa(len(p.Containers)),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(podCmd)
+	podCmd.AddCommand(podCreateCmd, podLsCmd, podStopCmd, podRmCmd)
+
+	podCreateCmd.Flags().String("hostname", "", "sets the pod hostname, the pod name by default")
+	podCreateCmd.Flags().String("network", "", "connects the pod to a bridge network or slirp, none by default: only a loopback interface")
+	podCreateCmd.Flags().StringArrayP("publish", "p", nil, "publishes a pod port on the daemon host ([host_ip:][host_port:]container_port[/tcp|udp])")
+	podCreateCmd.Flags().StringArray("dns", nil, "sets a nameserver the pod DNS server forwards to, the daemon host nameservers by default")
+	podCreateCmd.Flags().StringArray("dns-search", nil, "adds a DNS search domain to the pod containers /etc/resolv.conf")
+	podCreateCmd.Flags().StringArray("add-host", nil, "adds a host:ip entry to the pod containers /etc/hosts")
+	podStopCmd.Flags().Int64P("time", "t", 10, "seconds to wait for each container to stop before killing it")
+	podRmCmd.Flags().BoolP("force", "f", false, "kill running pod containers before removing them")
+}
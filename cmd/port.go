@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"cont/api"
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"net"
+	"strconv"
+)
+
+var portCmd = &cobra.Command{
+	Use:   "port <container_id>",
+	Short: "list published container ports",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withClient(func(client api.ApiClient) {
+			response, err := client.Port(context.Background(), &api.PortRequest{Id: []byte(args[0])})
+			must(err)
+
+			for _, p := range response.Ports {
+				hostIP := p.HostIp
+				if hostIP == "" {
+					hostIP = "0.0.0.0"
+				}
+				fmt.Printf("%d/%s -> %s\n", p.ContainerPort, p.Protocol, net.JoinHostPort(hostIP, strconv.Itoa(int(p.HostPort))))
+			}
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(portCmd)
+}
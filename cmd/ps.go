@@ -13,11 +13,14 @@ var psCmd = &cobra.Command{
 	Use:   "ps",
 	Short: "list active processes",
 	Run: func(cmd *cobra.Command, args []string) {
+		all, err := cmd.Flags().GetBool("all")
+		must(err)
+
 		dial, err := GrpcDial()
 		must(err)
 
 		client := api.NewApiClient(dial)
-		processes, err := client.Ps(context.Background(), &api.Empty{})
+		processes, err := client.Ps(context.Background(), &api.PsRequest{All: all})
 		must(err)
 
 		must(printProcesses(processes))
@@ -26,16 +29,28 @@ var psCmd = &cobra.Command{
 
 func printProcesses(processes *api.ActiveProcesses) error {
 	table := tablewriter.NewWriter(os.Stdout)
-	table.SetHeader([]string{"UUID", "CMD", "PID", "NAME"})
+	table.SetHeader([]string{"UUID", "CMD", "PID", "NAME", "STATUS", "RESTARTS"})
 	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
 	table.SetCenterSeparator("|")
 	for _, proc := range processes.Processes {
-		table.Append([]string{proc.Id, proc.Cmd, fmt.Sprint(proc.Pid), proc.Name})
+		table.Append([]string{proc.Id, proc.Cmd, fmt.Sprint(proc.Pid), proc.Name, processStatus(proc), fmt.Sprint(proc.Restarts)})
 	}
 	table.Render()
 	return nil
 }
 
+func processStatus(proc *api.Process) string {
+	if proc.Status != "exited" {
+		return proc.Status
+	}
+	if proc.ExitCode == -1 {
+		return "exited (unknown)"
+	}
+	return fmt.Sprintf("exited (%d)", proc.ExitCode)
+}
+
 func init() {
 	rootCmd.AddCommand(psCmd)
+
+	psCmd.Flags().BoolP("all", "a", false, "also list exited containers")
 }
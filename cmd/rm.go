@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"cont/api"
+	"context"
+	"github.com/spf13/cobra"
+)
+
+var rmCmd = &cobra.Command{
+	Use:   "rm <container_id>...",
+	Short: "remove exited containers",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		force, err := cmd.Flags().GetBool("force")
+		must(err)
+
+		withClient(func(client api.ApiClient) {
+			for _, id := range args {
+				_, err := client.Rm(context.Background(), &api.RemoveRequest{Id: []byte(id), Force: force})
+				must(err)
+			}
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rmCmd)
+
+	rmCmd.Flags().BoolP("force", "f", false, "kill running containers before removing them")
+}
@@ -2,21 +2,40 @@ package cmd
 
 import (
 	"cont/api"
+	"cont/container"
+	"cont/seccomp"
+	"cont/volume"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 	"golang.org/x/sys/unix"
 	"io"
+	"io/ioutil"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
 )
 
 var runCmd = &cobra.Command{
-	Use:   "run",
+	Use:   "run [flags] [--] [command [args...]]",
 	Short: "run a container",
+	Args: func(cmd *cobra.Command, args []string) error {
+		image, err := cmd.Flags().GetString("image")
+		if err != nil {
+			return err
+		}
+		if image == "" && len(args) == 0 {
+			return errors.New("requires a command, only images can provide a default one")
+		}
+		return nil
+	},
 	Run: func(cmd *cobra.Command, args []string) {
 		clientID := uuid.New()
 		clientIDBytes, err := clientID.MarshalBinary()
@@ -46,41 +65,125 @@ var runCmd = &cobra.Command{
 		name, err := cmd.Flags().GetString("name")
 		must(err)
 
-		shareNSID, err := cmd.Flags().GetString("share-ns")
+		rootfs, err := cmd.Flags().GetString("rootfs")
 		must(err)
 
-		var shareNS int
-		var shareID []byte
-		if shareNSID != "" {
-			shareUUID, err := uuid.Parse(shareNSID)
-			if err != nil {
-				panic(fmt.Errorf("cannot parse share ID: %w", err))
-			}
-			shareID, err = shareUUID.MarshalBinary()
-			if err != nil {
-				panic(fmt.Errorf("cannot marshal share UUID to binary: %w", err))
-			}
-			shareNS =
-				syscall.CLONE_NEWUTS |
-					//syscall.CLONE_NEWPID |
-					syscall.CLONE_NEWNS |
-					syscall.CLONE_NEWNET |
-					//syscall.CLONE_NEWUSER |
-					//syscall.CLONE_NEWIPC |
-					unix.CLONE_NEWCGROUP
+		image, err := cmd.Flags().GetString("image")
+		must(err)
+
+		volumes, err := cmd.Flags().GetStringArray("volume")
+		must(err)
+
+		restart, err := cmd.Flags().GetString("restart")
+		must(err)
+
+		stopSignal, err := cmd.Flags().GetString("stop-signal")
+		must(err)
+
+		env, err := parseEnv(cmd)
+		must(err)
+
+		user, err := cmd.Flags().GetString("user")
+		must(err)
+
+		uidMaps, err := parseIDMaps(cmd, "uidmap")
+		must(err)
+
+		gidMaps, err := parseIDMaps(cmd, "gidmap")
+		must(err)
+
+		capAdd, err := cmd.Flags().GetStringArray("cap-add")
+		must(err)
+
+		capDrop, err := cmd.Flags().GetStringArray("cap-drop")
+		must(err)
+
+		allowNewPrivileges, err := cmd.Flags().GetBool("allow-new-privileges")
+		must(err)
+
+		seccompProfile, err := parseSecurityOpts(cmd)
+		must(err)
+
+		readOnly, err := cmd.Flags().GetBool("read-only")
+		must(err)
+
+		tmpfs, err := parseTmpfs(cmd)
+		must(err)
+
+		network, err := cmd.Flags().GetString("network")
+		must(err)
+
+		ports, err := parsePorts(cmd)
+		must(err)
+
+		dns, err := cmd.Flags().GetStringArray("dns")
+		must(err)
+
+		dnsSearch, err := cmd.Flags().GetStringArray("dns-search")
+		must(err)
+
+		extraHosts, err := cmd.Flags().GetStringArray("add-host")
+		must(err)
+
+		resources, err := parseResources(cmd)
+		must(err)
+
+		mounts := make([]*api.Mount, 0, len(volumes))
+		for _, volume := range volumes {
+			mount, err := parseVolume(volume)
+			must(err)
+			mounts = append(mounts, mount)
+		}
+
+		if (rootfs != "" || image != "") && !cmd.Flags().Changed("workdir") {
+			workdir = "" // the host workdir doesn't make sense in a different rootfs, let the daemon decide
+		}
+
+		shareNS, shareID, err := parseShareNS(cmd)
+		must(err)
+
+		pod, podNamespaces, err := parsePod(cmd)
+		must(err)
+
+		var command string
+		if len(args) > 0 { // otherwise the image command runs
+			command, args = args[0], args[1:]
 		}
 
 		client := api.NewApiClient(conn)
 		cReq := &api.ContainerRequest{
-			Name:     name,
-			Hostname: hostname,
-			Workdir:  workdir,
-			Cmd:      args[0],
-			Args:     args[1:],
+			Name:               name,
+			Hostname:           hostname,
+			Workdir:            workdir,
+			Rootfs:             rootfs,
+			Image:              image,
+			Mounts:             mounts,
+			Tmpfs:              tmpfs,
+			Network:            network,
+			Ports:              ports,
+			Dns:                dns,
+			DnsSearch:          dnsSearch,
+			ExtraHosts:         extraHosts,
+			Pod:                pod,
+			PodNamespaces:      podNamespaces,
+			ReadOnly:           readOnly,
+			Resources:          resources,
+			StopSignal:         stopSignal,
+			Restart:            restart,
+			Env:                env,
+			User:               user,
+			UidMaps:            uidMaps,
+			GidMaps:            gidMaps,
+			CapAdd:             capAdd,
+			CapDrop:            capDrop,
+			Cmd:                command,
+			Args:               args,
+			AllowNewPrivileges: allowNewPrivileges,
+			Seccomp:            seccompProfile,
 			Opts: &api.ContainerOpts{
 				Interactive: isInteractive,
 				ShareOpts: &api.ShareNSOpts{
-					Flags:   int64(shareNS),
+					Flags:   shareNS,
 					ShareID: shareID,
 				},
 			},
@@ -125,17 +228,319 @@ var runCmd = &cobra.Command{
 			// if no interactive mode, just output
 			attachOutput(&wg, stdout, stderr)
 
-			signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+			// events signal the end of the container through signals, the output ends on its own
+			interrupts := make(chan os.Signal, 1)
+			signal.Notify(interrupts, syscall.SIGTERM, syscall.SIGINT)
 			go func() {
-				<-signals
+				<-interrupts
 				closePipes(stdin, stdout, stderr)
 				os.Exit(0)
 			}()
 		}
 		wg.Wait()
+		closePipes(stdin, stdout, stderr)
+		exitWithContainer(client, containerID)
 	},
 }
 
+// parseEnv returns environment variables from env files and --env flags, later ones override earlier ones.
+func parseEnv(cmd *cobra.Command) ([]string, error) {
+	envFiles, err := cmd.Flags().GetStringArray("env-file")
+	if err != nil {
+		return nil, err
+	}
+	variables := make([]string, 0)
+	for _, envFile := range envFiles {
+		fileVariables, err := readEnvFile(envFile)
+		if err != nil {
+			return nil, err
+		}
+		variables = append(variables, fileVariables...)
+	}
+	flagVariables, err := cmd.Flags().GetStringArray("env")
+	if err != nil {
+		return nil, err
+	}
+	variables = append(variables, flagVariables...)
+
+	env := make([]string, 0, len(variables))
+	for _, variable := range variables {
+		if strings.Contains(variable, "=") {
+			env = append(env, variable)
+		} else if value, ok := os.LookupEnv(variable); ok { // like docker, only the key passes the local value
+			env = append(env, variable+"="+value)
+		}
+	}
+	return env, nil
+}
+
+// readEnvFile reads KEY=VALUE lines, empty lines and lines starting with # are skipped.
+func readEnvFile(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read env file: %w", err)
+	}
+	variables := make([]string, 0)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		variables = append(variables, line)
+	}
+	return variables, nil
+}
+
+// parseTmpfs parses tmpfs mounts in the path[:options] format.
+func parseTmpfs(cmd *cobra.Command) ([]*api.Tmpfs, error) {
+	values, err := cmd.Flags().GetStringArray("tmpfs")
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*api.Tmpfs, 0, len(values))
+	for _, value := range values {
+		destination, options := value, ""
+		if i := strings.IndexByte(value, ':'); i != -1 {
+			destination, options = value[:i], value[i+1:]
+		}
+		if !filepath.IsAbs(destination) {
+			return nil, fmt.Errorf("invalid tmpfs %s, the path has to be absolute", value)
+		}
+		result = append(result, &api.Tmpfs{Destination: destination, Options: options})
+	}
+	return result, nil
+}
+
+// parseSecurityOpts returns the seccomp profile of the seccomp=<file|unconfined> security options.
+func parseSecurityOpts(cmd *cobra.Command) (string, error) {
+	opts, err := cmd.Flags().GetStringArray("security-opt")
+	if err != nil {
+		return "", err
+	}
+	profile := ""
+	for _, opt := range opts {
+		value := strings.TrimPrefix(opt, "seccomp=")
+		if value == opt || value == "" {
+			return "", fmt.Errorf("unsupported security option %q, expected seccomp=<file|unconfined>", opt)
+		}
+		if value == seccomp.Unconfined {
+			profile = value
+			continue
+		}
+		data, err := ioutil.ReadFile(value)
+		if err != nil {
+			return "", fmt.Errorf("cannot read seccomp profile: %w", err)
+		}
+		if _, err := seccomp.Parse(data); err != nil {
+			return "", err
+		}
+		profile = string(data)
+	}
+	return profile, nil
+}
+
+func parseResources(cmd *cobra.Command) (*api.Resources, error) {
+	memory, err := cmd.Flags().GetString("memory")
+	if err != nil {
+		return nil, err
+	}
+	cpus, err := cmd.Flags().GetFloat64("cpus")
+	if err != nil {
+		return nil, err
+	}
+	pids, err := cmd.Flags().GetInt64("pids-limit")
+	if err != nil {
+		return nil, err
+	}
+	ioMax, err := cmd.Flags().GetStringArray("io-max")
+	if err != nil {
+		return nil, err
+	}
+	resources := &api.Resources{Cpus: cpus, Pids: pids, IoMax: ioMax}
+	if memory != "" {
+		if resources.Memory, err = parseSize(memory); err != nil {
+			return nil, err
+		}
+	}
+	return resources, nil
+}
+
+// parseSize parses sizes like 1024, 512k, 64m or 2g (in bytes).
+func parseSize(size string) (int64, error) {
+	value := strings.TrimSuffix(strings.ToLower(size), "b")
+	multiplier := int64(1)
+	if value != "" {
+		switch value[len(value)-1] {
+		case 'k':
+			multiplier = 1 << 10
+		case 'm':
+			multiplier = 1 << 20
+		case 'g':
+			multiplier = 1 << 30
+		case 't':
+			multiplier = 1 << 40
+		}
+		if multiplier != 1 {
+			value = value[:len(value)-1]
+		}
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %s", size)
+	}
+	return n * multiplier, nil
+}
+
+// parseVolume parses a source:container[:ro|rw] volume, the source is a host path or a volume name.
+func parseVolume(spec string) (*api.Mount, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid volume %s, expected source:container[:ro]", spec)
+	}
+	mount := &api.Mount{Source: parts[0], Destination: parts[1]}
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "ro":
+			mount.ReadOnly = true
+		case "rw":
+		default:
+			return nil, fmt.Errorf("invalid volume option %s", parts[2])
+		}
+	}
+	if !filepath.IsAbs(mount.Source) && !volume.IsName(mount.Source) { // the daemon may run on another host
+		return nil, fmt.Errorf("invalid volume source %s, expected an absolute host path or a volume name", mount.Source)
+	}
+	return mount, nil
+}
+
+// parsePorts parses published ports in the [host_ip:][host_port:]container_port[/protocol] format.
+func parsePorts(cmd *cobra.Command) ([]*api.PortMapping, error) {
+	specs, err := cmd.Flags().GetStringArray("publish")
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*api.PortMapping, 0, len(specs))
+	for _, spec := range specs {
+		invalid := fmt.Errorf("invalid port %s, expected [host_ip:][host_port:]container_port[/tcp|udp]", spec)
+		mapping := &api.PortMapping{Protocol: "tcp"}
+		value := spec
+		if i := strings.IndexByte(value, '/'); i != -1 {
+			value, mapping.Protocol = value[:i], value[i+1:]
+			if mapping.Protocol != "tcp" && mapping.Protocol != "udp" {
+				return nil, invalid
+			}
+		}
+		parts := strings.Split(value, ":")
+		if len(parts) > 3 {
+			return nil, invalid
+		}
+		if len(parts) == 3 {
+			mapping.HostIp = parts[0]
+			if net.ParseIP(mapping.HostIp) == nil {
+				return nil, invalid
+			}
+			parts = parts[1:]
+		}
+		port, err := strconv.ParseUint(parts[len(parts)-1], 10, 16)
+		if err != nil || port == 0 {
+			return nil, invalid
+		}
+		mapping.ContainerPort = int32(port)
+		if len(parts) == 2 && parts[0] != "" {
+			port, err := strconv.ParseUint(parts[0], 10, 16)
+			if err != nil || port == 0 {
+				return nil, invalid
+			}
+			mapping.HostPort = int32(port)
+		}
+		result = append(result, mapping)
+	}
+	return result, nil
+}
+
+// shareNamespaces are the CLONE_NEW* flags of the namespaces a container can share by their /proc/<pid>/ns names.
+var shareNamespaces = map[string]int64{
+	"user":   unix.CLONE_NEWUSER,
+	"mnt":    unix.CLONE_NEWNS,
+	"net":    unix.CLONE_NEWNET,
+	"ipc":    unix.CLONE_NEWIPC,
+	"uts":    unix.CLONE_NEWUTS,
+	"pid":    unix.CLONE_NEWPID,
+	"cgroup": unix.CLONE_NEWCGROUP,
+	"time":   container.CloneNewTime,
+}
+
+// defaultShareNamespaces are shared if --share-ns doesn't select any.
+var defaultShareNamespaces = []string{"net", "ipc", "uts"}
+
+// parseShareNS parses id[:namespace,...] and returns the CLONE_NEW* flags and the binary container ID.
+func parseShareNS(cmd *cobra.Command) (int64, []byte, error) {
+	spec, err := cmd.Flags().GetString("share-ns")
+	if err != nil || spec == "" {
+		return 0, nil, err
+	}
+	id, namespaces := spec, defaultShareNamespaces
+	if i := strings.IndexByte(spec, ':'); i != -1 {
+		id, namespaces = spec[:i], strings.Split(spec[i+1:], ",")
+	}
+	shareUUID, err := uuid.Parse(id)
+	if err != nil {
+		return 0, nil, fmt.Errorf("cannot parse share ID: %w", err)
+	}
+	shareID, err := shareUUID.MarshalBinary()
+	if err != nil {
+		return 0, nil, fmt.Errorf("cannot marshal share UUID to binary: %w", err)
+	}
+	var flags int64
+	for _, ns := range namespaces {
+		flag, ok := shareNamespaces[ns]
+		if !ok {
+			return 0, nil, fmt.Errorf("invalid namespace %q, expected user, mnt, net, ipc, uts, pid, cgroup or time", ns)
+		}
+		flags |= flag
+	}
+	return flags, shareID, nil
+}
+
+// parsePod parses the name[:namespace,...] pod of a container.
+func parsePod(cmd *cobra.Command) (string, []string, error) {
+	spec, err := cmd.Flags().GetString("pod")
+	if err != nil || spec == "" {
+		return "", nil, err
+	}
+	i := strings.IndexByte(spec, ':')
+	if i == -1 {
+		return spec, nil, nil
+	}
+	if i == 0 || i == len(spec)-1 {
+		return "", nil, fmt.Errorf("invalid pod %s, expected name[:net,ipc,uts,pid]", spec)
+	}
+	return spec[:i], strings.Split(spec[i+1:], ","), nil
+}
+
+// parseIDMaps parses user namespace ID maps in the container_id:host_id:size format.
+func parseIDMaps(cmd *cobra.Command, flag string) ([]*api.IDMap, error) {
+	specs, err := cmd.Flags().GetStringArray(flag)
+	if err != nil {
+		return nil, err
+	}
+	idMaps := make([]*api.IDMap, 0, len(specs))
+	for _, spec := range specs {
+		parts := strings.Split(spec, ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("invalid %s %s, expected container_id:host_id:size", flag, spec)
+		}
+		var values [3]int64
+		for i, part := range parts {
+			if values[i], err = strconv.ParseInt(part, 10, 64); err != nil || values[i] < 0 {
+				return nil, fmt.Errorf("invalid %s %s, expected container_id:host_id:size", flag, spec)
+			}
+		}
+		idMaps = append(idMaps, &api.IDMap{ContainerId: values[0], HostId: values[1], Size: values[2]})
+	}
+	return idMaps, nil
+}
+
 func init() {
 	rootCmd.AddCommand(runCmd)
 
@@ -151,8 +556,34 @@ func init() {
 
 	runCmd.Flags().Bool("it", false, "determines whether to connect stdin with container stdin")
 	runCmd.Flags().BoolP("detached", "d", false, "run in detached mode")
-	runCmd.Flags().String("share-ns", "", "selects which container to share namespaces with. The containers have to be co-located (on the same host). Will not try to share mount NS.")
+	runCmd.Flags().String("share-ns", "", "shares namespaces with a running container on the same host (id[:user,mnt,net,ipc,uts,pid,cgroup,time]), net, ipc & uts by default. Its user namespace is always joined")
+	runCmd.Flags().String("pod", "", "runs the container in a pod, joining its net, ipc & uts namespaces or the selected ones (name[:net,ipc,uts,pid])")
 	runCmd.Flags().String("hostname", hostname, "sets container hostname")
 	runCmd.Flags().String("workdir", homeDir, "sets container workdir")
 	runCmd.Flags().String("name", "", "sets container name")
+	runCmd.Flags().String("rootfs", "", "sets container root filesystem directory (on the daemon host)")
+	runCmd.Flags().String("image", "", "runs the container from an imported image")
+	runCmd.Flags().String("memory", "", "limits container memory, e.g. 512m or 2g")
+	runCmd.Flags().Float64("cpus", 0, "limits the number of CPUs the container can use, e.g. 1.5")
+	runCmd.Flags().Int64("pids-limit", 0, "limits the number of container processes")
+	runCmd.Flags().StringArray("io-max", nil, "cgroup v2 io.max limit, e.g. \"8:0 rbps=1048576 wiops=120\"")
+	runCmd.Flags().StringArrayP("volume", "v", nil, "mounts a daemon host path or a named volume into the container (source:container[:ro])")
+	runCmd.Flags().Bool("read-only", false, "mounts the container root filesystem read-only")
+	runCmd.Flags().StringArray("tmpfs", nil, "mounts a tmpfs into the container (path[:options], e.g. /tmp:size=64m,mode=1777)")
+	runCmd.Flags().String("network", "", "connects the container to a bridge network (e.g. cont, the default network) or slirp for rootless userspace networking (slirp:allow_host_loopback=true lets the container reach the daemon host loopback through 10.0.2.2), none by default: only a loopback interface")
+	runCmd.Flags().StringArrayP("publish", "p", nil, "publishes a container port on the daemon host ([host_ip:][host_port:]container_port[/tcp|udp]), a random host port is used if it's missing")
+	runCmd.Flags().StringArray("dns", nil, "sets a nameserver the container DNS server forwards to, the daemon host nameservers by default")
+	runCmd.Flags().StringArray("dns-search", nil, "adds a DNS search domain to the container /etc/resolv.conf")
+	runCmd.Flags().StringArray("add-host", nil, "adds a host:ip entry to the container /etc/hosts")
+	runCmd.Flags().String("restart", "", "restarts the container when it exits: no (the default), on-failure[:max_retries], always or unless-stopped")
+	runCmd.Flags().String("stop-signal", "", "signal sent to the container on stop, SIGTERM by default")
+	runCmd.Flags().StringArrayP("env", "e", nil, "sets a container environment variable (KEY=VALUE, or KEY to use the local value)")
+	runCmd.Flags().StringArray("env-file", nil, "reads container environment variables from a file, one KEY=VALUE per line")
+	runCmd.Flags().StringP("user", "u", "", "runs the container command as uid[:gid]")
+	runCmd.Flags().StringArray("uidmap", nil, "maps container uids to host uids (container_id:host_id:size), root & subordinate uids of the daemon user by default")
+	runCmd.Flags().StringArray("gidmap", nil, "maps container gids to host gids (container_id:host_id:size), root & subordinate gids of the daemon user by default")
+	runCmd.Flags().StringArray("cap-add", nil, "adds a capability to the default ones (e.g. NET_ADMIN), ALL adds all capabilities")
+	runCmd.Flags().StringArray("cap-drop", nil, "drops a capability from the default ones (e.g. CHOWN), ALL drops all capabilities")
+	runCmd.Flags().StringArray("security-opt", nil, "sets a security option: seccomp=<file> for a seccomp profile (OCI/Docker JSON), seccomp=unconfined disables syscall filtering")
+	runCmd.Flags().Bool("allow-new-privileges", false, "lets the container command gain privileges, e.g. through setuid binaries (no_new_privs isn't set)")
 }
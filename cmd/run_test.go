@@ -0,0 +1,233 @@
+package cmd
+
+import (
+	"bytes"
+	"cont/api"
+	"cont/container"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// commandWithFlags returns a command with the run flags the parsers read, parsed from args.
+func commandWithFlags(t *testing.T, args ...string) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{}
+	cmd.Flags().StringArrayP("publish", "p", nil, "")
+	cmd.Flags().String("share-ns", "", "")
+	cmd.Flags().StringArray("tmpfs", nil, "")
+	cmd.Flags().StringArrayP("env", "e", nil, "")
+	cmd.Flags().StringArray("env-file", nil, "")
+	cmd.Flags().StringArray("uidmap", nil, "")
+	if err := cmd.Flags().Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return cmd
+}
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected *api.PortMapping
+	}{
+		{"80", &api.PortMapping{ContainerPort: 80, Protocol: "tcp"}},
+		{"8080:80", &api.PortMapping{HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}},
+		{":80", &api.PortMapping{ContainerPort: 80, Protocol: "tcp"}},
+		{"53/udp", &api.PortMapping{ContainerPort: 53, Protocol: "udp"}},
+		{"127.0.0.1:8080:80/tcp", &api.PortMapping{HostIp: "127.0.0.1", HostPort: 8080, ContainerPort: 80, Protocol: "tcp"}},
+		{"0.0.0.0::53/udp", &api.PortMapping{HostIp: "0.0.0.0", ContainerPort: 53, Protocol: "udp"}},
+		{"65535:65535", &api.PortMapping{HostPort: 65535, ContainerPort: 65535, Protocol: "tcp"}},
+	}
+	for _, test := range tests {
+		ports, err := parsePorts(commandWithFlags(t, "-p", test.spec))
+		if err != nil {
+			t.Errorf("parsePorts(%s): %v", test.spec, err)
+			continue
+		}
+		if len(ports) != 1 {
+			t.Errorf("parsePorts(%s) returned %d ports", test.spec, len(ports))
+			continue
+		}
+		port := ports[0]
+		if port.HostIp != test.expected.HostIp || port.HostPort != test.expected.HostPort ||
+			port.ContainerPort != test.expected.ContainerPort || port.Protocol != test.expected.Protocol {
+			t.Errorf("parsePorts(%s) = %v, expected %v", test.spec, port, test.expected)
+		}
+	}
+
+	for _, spec := range []string{"0", "65536", "http", "80/sctp", "80/", "8080:0", "x:80", "localhost:8080:80",
+		"1.2.3:80:80", "127.0.0.1:1:2:3"} {
+		if ports, err := parsePorts(commandWithFlags(t, "-p", spec)); err == nil {
+			t.Errorf("parsePorts(%s) = %v, expected an error", spec, ports)
+		}
+	}
+}
+
+func TestParseShareNS(t *testing.T) {
+	id := uuid.New()
+	binaryID, err := id.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		spec  string
+		flags int64
+	}{
+		{id.String(), unix.CLONE_NEWNET | unix.CLONE_NEWIPC | unix.CLONE_NEWUTS},
+		{id.String() + ":pid", unix.CLONE_NEWPID},
+		{id.String() + ":user,mnt,cgroup", unix.CLONE_NEWUSER | unix.CLONE_NEWNS | unix.CLONE_NEWCGROUP},
+		{id.String() + ":net,net", unix.CLONE_NEWNET},
+		{id.String() + ":time", container.CloneNewTime},
+	}
+	for _, test := range tests {
+		flags, shareID, err := parseShareNS(commandWithFlags(t, "--share-ns", test.spec))
+		if err != nil {
+			t.Errorf("parseShareNS(%s): %v", test.spec, err)
+			continue
+		}
+		if flags != test.flags {
+			t.Errorf("parseShareNS(%s) flags = %#x, expected %#x", test.spec, flags, test.flags)
+		}
+		if !bytes.Equal(shareID, binaryID) {
+			t.Errorf("parseShareNS(%s) ID = %x, expected %x", test.spec, shareID, binaryID)
+		}
+	}
+
+	if flags, shareID, err := parseShareNS(commandWithFlags(t)); err != nil || flags != 0 || shareID != nil {
+		t.Errorf("parseShareNS without --share-ns = %#x, %x, %v, expected nothing", flags, shareID, err)
+	}
+	for _, spec := range []string{"container", id.String() + ":", id.String() + ":network", id.String() + ":net,",
+		":net"} {
+		if _, _, err := parseShareNS(commandWithFlags(t, "--share-ns", spec)); err == nil {
+			t.Errorf("parseShareNS(%s) didn't fail", spec)
+		}
+	}
+}
+
+func TestParseVolume(t *testing.T) {
+	tests := []struct {
+		spec     string
+		expected *api.Mount
+	}{
+		{"/src:/src", &api.Mount{Source: "/src", Destination: "/src"}},
+		{"/etc/hosts:/etc/hosts:ro", &api.Mount{Source: "/etc/hosts", Destination: "/etc/hosts", ReadOnly: true}},
+		{"cache:/root/.cache:rw", &api.Mount{Source: "cache", Destination: "/root/.cache"}},
+	}
+	for _, test := range tests {
+		mount, err := parseVolume(test.spec)
+		if err != nil {
+			t.Errorf("parseVolume(%s): %v", test.spec, err)
+			continue
+		}
+		if mount.Source != test.expected.Source || mount.Destination != test.expected.Destination ||
+			mount.ReadOnly != test.expected.ReadOnly {
+			t.Errorf("parseVolume(%s) = %v, expected %v", test.spec, mount, test.expected)
+		}
+	}
+
+	for _, spec := range []string{"/src", "/src:", ":/src", "/src:/src:rx", "/a:/b:ro:x", "./src:/src", "../src:/src"} {
+		if mount, err := parseVolume(spec); err == nil {
+			t.Errorf("parseVolume(%s) = %v, expected an error", spec, mount)
+		}
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for size, expected := range map[string]int64{
+		"0":     0,
+		"512":   512,
+		"512b":  512,
+		"64k":   64 << 10,
+		"64KB":  64 << 10,
+		"256m":  256 << 20,
+		"1G":    1 << 30,
+		"2gb":   2 << 30,
+		"1t":    1 << 40,
+		"4096B": 4096,
+	} {
+		if n, err := parseSize(size); err != nil || n != expected {
+			t.Errorf("parseSize(%s) = %d, %v, expected %d", size, n, err, expected)
+		}
+	}
+	for _, size := range []string{"", "b", "m", "-1m", "1.5g", "1p", "10 m", "1mm"} {
+		if n, err := parseSize(size); err == nil {
+			t.Errorf("parseSize(%s) = %d, expected an error", size, n)
+		}
+	}
+}
+
+func TestParseEnv(t *testing.T) {
+	file, err := ioutil.TempFile("", "env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.WriteString("# database\nDB_HOST=db\n\n  DB_PORT=5432  \nDEBUG=0\n"); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	if err := os.Setenv("CONT_TEST_TOKEN", "secret"); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Unsetenv("CONT_TEST_TOKEN")
+
+	// variables without a value take the local value if there is one, flags come after (and override) env files
+	env, err := parseEnv(commandWithFlags(t, "-e", "DEBUG=1", "--env-file", file.Name(), "-e", "CONT_TEST_TOKEN",
+		"-e", "CONT_TEST_UNSET"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"DB_HOST=db", "DB_PORT=5432", "DEBUG=0", "DEBUG=1", "CONT_TEST_TOKEN=secret"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("parseEnv = %v, expected %v", env, expected)
+	}
+
+	if env, err := parseEnv(commandWithFlags(t, "--env-file", file.Name()+".missing")); err == nil {
+		t.Errorf("parseEnv with a missing env file = %v, expected an error", env)
+	}
+}
+
+func TestParseTmpfs(t *testing.T) {
+	tmpfs, err := parseTmpfs(commandWithFlags(t, "--tmpfs", "/run", "--tmpfs", "/tmp:size=64m,exec", "--tmpfs",
+		"/var/cache:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []api.Tmpfs{{Destination: "/run"}, {Destination: "/tmp", Options: "size=64m,exec"},
+		{Destination: "/var/cache"}}
+	if len(tmpfs) != len(expected) {
+		t.Fatalf("parseTmpfs returned %d mounts, expected %d", len(tmpfs), len(expected))
+	}
+	for i, mount := range tmpfs {
+		if mount.Destination != expected[i].Destination || mount.Options != expected[i].Options {
+			t.Errorf("tmpfs %d = %v, expected %v", i, mount, &expected[i])
+		}
+	}
+
+	for _, spec := range []string{"tmp", ":size=1m", "run:/tmp"} {
+		if tmpfs, err := parseTmpfs(commandWithFlags(t, "--tmpfs", spec)); err == nil {
+			t.Errorf("parseTmpfs(%s) = %v, expected an error", spec, tmpfs)
+		}
+	}
+}
+
+func TestParseIDMaps(t *testing.T) {
+	idMaps, err := parseIDMaps(commandWithFlags(t, "--uidmap", "0:1000:1", "--uidmap", "1:100000:65536"), "uidmap")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(idMaps) != 2 || idMaps[0].ContainerId != 0 || idMaps[0].HostId != 1000 || idMaps[0].Size != 1 ||
+		idMaps[1].ContainerId != 1 || idMaps[1].HostId != 100000 || idMaps[1].Size != 65536 {
+		t.Errorf("parseIDMaps = %v, expected 0:1000:1 and 1:100000:65536", idMaps)
+	}
+
+	for _, spec := range []string{"0:1000", "0:1000:1:1", "0:-1:1", "a:b:c", "0:1000:"} {
+		if idMaps, err := parseIDMaps(commandWithFlags(t, "--uidmap", spec), "uidmap"); err == nil {
+			t.Errorf("parseIDMaps(%s) = %v, expected an error", spec, idMaps)
+		}
+	}
+}
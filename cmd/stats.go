@@ -0,0 +1,85 @@
+package cmd
+
+import (
+	"cont/api"
+	"context"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"io"
+	"os"
+	"time"
+)
+
+const clearScreen = "\033[H\033[2J"
+
+var statsCmd = &cobra.Command{
+	Use:   "stats [container_id...]",
+	Short: "show live resource usage of containers",
+	Run: func(cmd *cobra.Command, args []string) {
+		noStream, err := cmd.Flags().GetBool("no-stream")
+		must(err)
+		interval, err := cmd.Flags().GetDuration("interval")
+		must(err)
+
+		request := &api.StatsRequest{Interval: interval.Milliseconds()}
+		for _, id := range args {
+			request.Ids = append(request.Ids, []byte(id))
+		}
+
+		withClient(func(client api.ApiClient) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			stream, err := client.Stats(ctx, request)
+			must(err)
+			for first := true; ; first = false {
+				sample, err := stream.Recv()
+				if err == io.EOF {
+					return
+				}
+				must(err)
+
+				if noStream && first { // CPU usage is only known from the second sample
+					continue
+				}
+				if !noStream {
+					fmt.Print(clearScreen)
+				}
+				must(printStats(sample))
+				if noStream {
+					return
+				}
+			}
+		})
+	},
+}
+
+func printStats(sample *api.StatsSample) error {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"UUID", "NAME", "CPU %", "MEM USAGE / LIMIT", "IO READ / WRITE", "PIDS"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	for _, c := range sample.Containers {
+		limit := "unlimited"
+		if c.MemoryLimit != 0 {
+			limit = formatSize(c.MemoryLimit)
+		}
+		table.Append([]string{
+			c.Id,
+			c.Name,
+			fmt.Sprintf("%.2f%%", c.CpuPercent),
+			formatSize(c.Memory) + " / " + limit,
+			formatSize(c.IoRead) + " / " + formatSize(c.IoWrite),
+			fmt.Sprint(c.Pids),
+		})
+	}
+	table.Render()
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(statsCmd)
+	statsCmd.Flags().Bool("no-stream", false, "print a single sample and exit")
+	statsCmd.Flags().Duration("interval", time.Second, "sampling interval")
+}
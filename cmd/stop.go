@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"cont/api"
+	"context"
+	"github.com/spf13/cobra"
+)
+
+var stopCmd = &cobra.Command{
+	Use:   "stop <container_id>...",
+	Short: "stop containers gracefully",
+	Long:  "Send the container stop signal (SIGTERM by default) and kill the container if it doesn't exit within the grace period.",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		timeout, err := cmd.Flags().GetInt64("time")
+		must(err)
+
+		withClient(func(client api.ApiClient) {
+			for _, id := range args {
+				_, err := client.Stop(context.Background(), &api.StopRequest{Id: []byte(id), Timeout: timeout})
+				must(err)
+			}
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stopCmd)
+
+	stopCmd.Flags().Int64P("time", "t", 10, "seconds to wait for the container to stop before killing it")
+}
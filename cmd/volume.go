@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"cont/api"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"time"
+)
+
+var volumeCmd = &cobra.Command{
+	Use:   "volume",
+	Short: "manage named volumes",
+}
+
+var volumeCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "create a volume, with a random name if none is given",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var name string
+		if len(args) > 0 {
+			name = args[0]
+		}
+		withClient(func(client api.ApiClient) {
+			v, err := client.VolumeCreate(context.Background(), &api.VolumeRequest{Name: name})
+			must(err)
+			fmt.Println(v.Name)
+		})
+	},
+}
+
+var volumeLsCmd = &cobra.Command{
+	Use:   "ls",
+	Short: "list volumes",
+	Run: func(cmd *cobra.Command, args []string) {
+		withClient(func(client api.ApiClient) {
+			volumes, err := client.VolumeLs(context.Background(), &api.Empty{})
+			must(err)
+			must(printVolumes(volumes))
+		})
+	},
+}
+
+var volumeInspectCmd = &cobra.Command{
+	Use:   "inspect <name>",
+	Short: "show volume details",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withClient(func(client api.ApiClient) {
+			v, err := client.VolumeInspect(context.Background(), &api.VolumeRequest{Name: args[0]})
+			must(err)
+
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			must(encoder.Encode(v))
+		})
+	},
+}
+
+var volumeRmCmd = &cobra.Command{
+	Use:   "rm <name>...",
+	Short: "remove volumes not used by any container",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		withClient(func(client api.ApiClient) {
+			for _, name := range args {
+				_, err := client.VolumeRm(context.Background(), &api.VolumeRequest{Name: name})
+				must(err)
+			}
+		})
+	},
+}
+
+var volumePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "remove all volumes not used by any container",
+	Run: func(cmd *cobra.Command, args []string) {
+		withClient(func(client api.ApiClient) {
+			removed, err := client.VolumePrune(context.Background(), &api.Empty{})
+			must(err)
+			for _, v := range removed.Volumes {
+				fmt.Println(v.Name)
+			}
+		})
+	},
+}
+
+func printVolumes(volumes *api.Volumes) error {
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetHeader([]string{"NAME", "CREATED", "CONTAINERS"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	for _, v := range volumes.Volumes {
+		table.Append([]string{v.Name, time.Unix(v.Created, 0).Format(time.RFC3339), strings.Join(v.Containers, ", ")})
+	}
+	table.Render()
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(volumeCmd)
+	volumeCmd.AddCommand(volumeCreateCmd, volumeLsCmd, volumeInspectCmd, volumeRmCmd, volumePruneCmd)
+}
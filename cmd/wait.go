@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"cont/api"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var waitCmd = &cobra.Command{
+	Use:   "wait <container_id>",
+	Short: "wait for a container to exit and exit with its exit code",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		containerID, err := uuid.Parse(args[0])
+		must(err)
+
+		withClient(func(client api.ApiClient) {
+			exitWithContainer(client, containerID)
+		})
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(waitCmd)
+}
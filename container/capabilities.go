@@ -0,0 +1,246 @@
+package container
+
+import (
+	"cont/seccomp"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// capabilities maps capability names (without the CAP_ prefix) to their numbers.
+var capabilities = map[string]uintptr{
+	"CHOWN":              unix.CAP_CHOWN,
+	"DAC_OVERRIDE":       unix.CAP_DAC_OVERRIDE,
+	"DAC_READ_SEARCH":    unix.CAP_DAC_READ_SEARCH,
+	"FOWNER":             unix.CAP_FOWNER,
+	"FSETID":             unix.CAP_FSETID,
+	"KILL":               unix.CAP_KILL,
+	"SETGID":             unix.CAP_SETGID,
+	"SETUID":             unix.CAP_SETUID,
+	"SETPCAP":            unix.CAP_SETPCAP,
+	"LINUX_IMMUTABLE":    unix.CAP_LINUX_IMMUTABLE,
+	"NET_BIND_SERVICE":   unix.CAP_NET_BIND_SERVICE,
+	"NET_BROADCAST":      unix.CAP_NET_BROADCAST,
+	"NET_ADMIN":          unix.CAP_NET_ADMIN,
+	"NET_RAW":            unix.CAP_NET_RAW,
+	"IPC_LOCK":           unix.CAP_IPC_LOCK,
+	"IPC_OWNER":          unix.CAP_IPC_OWNER,
+	"SYS_MODULE":         unix.CAP_SYS_MODULE,
+	"SYS_RAWIO":          unix.CAP_SYS_RAWIO,
+	"SYS_CHROOT":         unix.CAP_SYS_CHROOT,
+	"SYS_PTRACE":         unix.CAP_SYS_PTRACE,
+	"SYS_PACCT":          unix.CAP_SYS_PACCT,
+	"SYS_ADMIN":          unix.CAP_SYS_ADMIN,
+	"SYS_BOOT":           unix.CAP_SYS_BOOT,
+	"SYS_NICE":           unix.CAP_SYS_NICE,
+	"SYS_RESOURCE":       unix.CAP_SYS_RESOURCE,
+	"SYS_TIME":           unix.CAP_SYS_TIME,
+	"SYS_TTY_CONFIG":     unix.CAP_SYS_TTY_CONFIG,
+	"MKNOD":              unix.CAP_MKNOD,
+	"LEASE":              unix.CAP_LEASE,
+	"AUDIT_WRITE":        unix.CAP_AUDIT_WRITE,
+	"AUDIT_CONTROL":      unix.CAP_AUDIT_CONTROL,
+	"SETFCAP":            unix.CAP_SETFCAP,
+	"MAC_OVERRIDE":       unix.CAP_MAC_OVERRIDE,
+	"MAC_ADMIN":          unix.CAP_MAC_ADMIN,
+	"SYSLOG":             unix.CAP_SYSLOG,
+	"WAKE_ALARM":         unix.CAP_WAKE_ALARM,
+	"BLOCK_SUSPEND":      unix.CAP_BLOCK_SUSPEND,
+	"AUDIT_READ":         unix.CAP_AUDIT_READ,
+	"PERFMON":            38,
+	"BPF":                39,
+	"CHECKPOINT_RESTORE": 40,
+}
+
+// DefaultCapabilities are the capabilities container commands get unless they're dropped.
+var DefaultCapabilities = []string{
+	"AUDIT_WRITE",
+	"CHOWN",
+	"DAC_OVERRIDE",
+	"FOWNER",
+	"FSETID",
+	"KILL",
+	"MKNOD",
+	"NET_BIND_SERVICE",
+	"NET_RAW",
+	"SETFCAP",
+	"SETGID",
+	"SETPCAP",
+	"SETUID",
+	"SYS_CHROOT",
+}
+
+// ResolveCapabilities returns the sorted default capabilities with drop dropped and add added. Names are case
+// insensitive, the CAP_ prefix is optional and ALL stands for all capabilities.
+func ResolveCapabilities(add, drop []string) ([]string, error) {
+	set := map[string]bool{}
+	for _, name := range DefaultCapabilities {
+		set[name] = true
+	}
+	for _, changes := range []struct {
+		names []string
+		value bool
+	}{{drop, false}, {add, true}} {
+		for _, name := range changes.names {
+			name = strings.TrimPrefix(strings.ToUpper(name), "CAP_")
+			if name == "ALL" {
+				for all := range capabilities {
+					set[all] = changes.value
+				}
+				continue
+			}
+			if _, ok := capabilities[name]; !ok {
+				return nil, fmt.Errorf("unknown capability %q", name)
+			}
+			set[name] = changes.value
+		}
+	}
+
+	result := make([]string, 0, len(set))
+	for name, ok := range set {
+		if ok {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// startLimited calls start from a thread limited to the capabilities and the seccomp profile. The limits are per thread
+// and inherited by the command, so the thread is locked and terminated once start returns.
+func startLimited(cmd *exec.Cmd, user *User, capabilityNames []string, noNewPrivileges bool, profile *seccomp.Profile,
+	start func() error) error {
+	caps, err := capabilityNumbers(capabilityNames)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.Uid == 0 {
+		cmd.SysProcAttr.AmbientCaps = caps
+	}
+	var filter []unix.SockFilter
+	if profile != nil {
+		if filter, err = seccomp.Compile(profile, capabilityNames); err != nil {
+			return err
+		}
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread() // never unlocked, the thread exits with the goroutine
+		if err := limitThread(caps, noNewPrivileges, filter); err != nil {
+			errs <- err
+			return
+		}
+		errs <- start()
+	}()
+	return <-errs
+}
+
+// limitThread limits the current thread. Without no_new_privs a seccomp filter needs CAP_SYS_ADMIN, so it's installed
+// first then.
+func limitThread(caps []uintptr, noNewPrivileges bool, filter []unix.SockFilter) error {
+	if filter != nil && !noNewPrivileges {
+		if err := seccomp.Install(filter); err != nil {
+			return err
+		}
+	}
+	if err := limitCapabilities(caps); err != nil {
+		return err
+	}
+	if !noNewPrivileges {
+		return nil
+	}
+	if err := unix.Prctl(unix.PR_SET_NO_NEW_PRIVS, 1, 0, 0, 0); err != nil {
+		return fmt.Errorf("cannot set no_new_privs: %w", err)
+	}
+	if filter != nil {
+		return seccomp.Install(filter)
+	}
+	return nil
+}
+
+// capabilityNumbers returns the numbers of the capabilities, skipping the ones the kernel doesn't know about.
+func capabilityNumbers(names []string) ([]uintptr, error) {
+	last, err := lastCapability()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]uintptr, 0, len(names))
+	for _, name := range names {
+		c, ok := capabilities[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown capability %q", name)
+		}
+		if c <= last {
+			result = append(result, c)
+		}
+	}
+	return result, nil
+}
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective, permitted, inheritable uint32
+}
+
+// limitCapabilities limits the bounding set and the capability sets of the current thread to caps. SETUID & SETGID stay
+// effective until the command switches users.
+func limitCapabilities(caps []uintptr) error {
+	keep := map[uintptr]bool{}
+	for _, c := range caps {
+		keep[c] = true
+	}
+	last, err := lastCapability()
+	if err != nil {
+		return err
+	}
+	for c := uintptr(0); c <= last; c++ {
+		if keep[c] {
+			continue
+		}
+		if err := unix.Prctl(unix.PR_CAPBSET_DROP, c, 0, 0, 0); err != nil {
+			return fmt.Errorf("cannot drop capability %d from the bounding set: %w", c, err)
+		}
+	}
+
+	header := capHeader{version: unix.LINUX_CAPABILITY_VERSION_3}
+	var data [2]capData
+	for c := range keep {
+		data[c/32].inheritable |= 1 << (c % 32)
+		data[c/32].permitted |= 1 << (c % 32)
+	}
+	for _, c := range []uintptr{unix.CAP_SETUID, unix.CAP_SETGID} {
+		data[c/32].permitted |= 1 << (c % 32)
+	}
+	for i := range data {
+		data[i].effective = data[i].permitted
+	}
+	_, _, errno := unix.RawSyscall(unix.SYS_CAPSET, uintptr(unsafe.Pointer(&header)), uintptr(unsafe.Pointer(&data[0])), 0)
+	if errno != 0 {
+		return fmt.Errorf("cannot set capabilities: %w", errno)
+	}
+	return nil
+}
+
+// lastCapability returns the highest capability number the kernel knows about.
+func lastCapability() (uintptr, error) {
+	content, err := ioutil.ReadFile("/proc/sys/kernel/cap_last_cap")
+	if err != nil {
+		return 0, err
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil {
+		return 0, err
+	}
+	return uintptr(last), nil
+}
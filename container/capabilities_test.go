@@ -0,0 +1,48 @@
+package container
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestResolveCapabilities(t *testing.T) {
+	defaults := append([]string(nil), DefaultCapabilities...)
+	sort.Strings(defaults)
+	if caps, err := ResolveCapabilities(nil, nil); err != nil || !reflect.DeepEqual(caps, defaults) {
+		t.Errorf("ResolveCapabilities without changes = %v, %v, expected %v", caps, err, defaults)
+	}
+
+	tests := []struct {
+		add, drop []string
+		expected  []string
+	}{
+		{[]string{"SYS_PTRACE"}, []string{"ALL"}, []string{"SYS_PTRACE"}},
+		{[]string{"cap_net_admin", "Kill"}, []string{"all"}, []string{"KILL", "NET_ADMIN"}},
+		{[]string{"NET_ADMIN"}, []string{"ALL", "NET_ADMIN"}, []string{"NET_ADMIN"}}, // add wins over drop
+		{nil, []string{"ALL"}, []string{}},
+	}
+	for _, test := range tests {
+		caps, err := ResolveCapabilities(test.add, test.drop)
+		if err != nil || !reflect.DeepEqual(caps, test.expected) {
+			t.Errorf("ResolveCapabilities(%v, %v) = %v, %v, expected %v", test.add, test.drop, caps, err, test.expected)
+		}
+	}
+
+	caps, err := ResolveCapabilities(nil, []string{"NET_RAW", "CAP_MKNOD"})
+	if err != nil || len(caps) != len(defaults)-2 {
+		t.Errorf("ResolveCapabilities dropping 2 capabilities = %v, %v", caps, err)
+	}
+	for _, c := range caps {
+		if c == "NET_RAW" || c == "MKNOD" {
+			t.Errorf("dropped capability %s wasn't removed", c)
+		}
+	}
+	if caps, err := ResolveCapabilities([]string{"ALL"}, nil); err != nil || len(caps) != len(capabilities) {
+		t.Errorf("ResolveCapabilities adding all = %d capabilities, %v, expected %d", len(caps), err,
+			len(capabilities))
+	}
+	if caps, err := ResolveCapabilities([]string{"SUPERUSER"}, nil); err == nil {
+		t.Errorf("ResolveCapabilities of an unknown capability = %v, expected an error", caps)
+	}
+}
@@ -1,19 +1,27 @@
 package container
 
 import (
+	"cont/cgroup"
 	_ "cont/nsenter"
+	"cont/seccomp"
 	"io"
 )
 
 const (
-	initPipeEnv = "_LIBCONTAINER_INITPIPE" // init pipe fd
-	nsStartEnv  = "_NS_START"              // first NS fd
-	nsEndEnv    = "_NS_END"                // last NS fd
+	initPipeEnv  = "_LIBCONTAINER_INITPIPE" // init pipe fd
+	nsStartEnv   = "_NS_START"              // first NS fd
+	nsEndEnv     = "_NS_END"                // last NS fd
+	nsUnshareEnv = "_NS_UNSHARE"            // CLONE_NEW* flags of namespaces created after joining the shared ones
 )
 
+// CloneNewTime is the CLONE_NEWTIME flag, which isn't defined by golang.org/x/sys yet.
+const CloneNewTime = 0x80
+
+// SharedNamespaceConfig selects namespaces of another container to join. Its user namespace is always joined, it owns
+// the other namespaces of the container, and the namespaces which aren't shared are created in it.
 type SharedNamespaceConfig struct {
-	Flags int
-	PID   int
+	Flags int // CLONE_NEW* flags of the shared namespaces
+	PID   int // init process of the container
 }
 
 type LoggingConfig struct {
@@ -25,15 +33,39 @@ type Config struct {
 	Stdout, Stderr        io.Writer
 	Hostname              string
 	Workdir               string
+	Rootfs                string
+	Mounts                []Mount
+	Tmpfs                 []Tmpfs
+	ReadOnly              bool // the root filesystem is mounted read-only
 	Cmd                   string
 	Args                  []string
+	Env                   []string         // KEY=VALUE, added to the default environment
+	User                  string           // uid[:gid], container root if empty
+	UidMappings           []IDMap          // user namespace uid maps, default maps if empty
+	GidMappings           []IDMap          // user namespace gid maps, default maps if empty
+	Capabilities          []string         // capabilities of the container command, see ResolveCapabilities
+	NoNewPrivileges       bool             // the container command cannot gain privileges (e.g. through setuid binaries)
+	Seccomp               *seccomp.Profile // syscall filter of the container command, nil disables filtering
 	Interactive           bool
 	SharedNamespaceConfig SharedNamespaceConfig
 	Logging               LoggingConfig
+	StdioDir              string         // directory for the stdio named pipes, which outlive the daemon
+	Cgroup                *cgroup.Cgroup // cgroup to run the container in, optional
 }
 
 type initPipeConfig struct {
 	Hostname, Workdir     string
+	Rootfs                string
+	Mounts                []Mount
+	Tmpfs                 []Tmpfs
+	ReadOnly              bool
+	Env                   []string
+	User                  string
+	Capabilities          []string
+	NoNewPrivileges       bool
+	Seccomp               *seccomp.Profile
 	Interactive           bool
+	CgroupNS              bool // unshare the cgroup namespace after being moved into the container cgroup
+	Reexec                bool // ID maps are written after the init process is executed, it re-executes to get capabilities
 	SharedNamespaceConfig SharedNamespaceConfig
 }
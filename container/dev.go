@@ -0,0 +1,84 @@
+package container
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+)
+
+// devices are bind mounted from the host, creating device nodes isn't allowed in user namespaces.
+var devices = []string{"null", "zero", "full", "random", "urandom", "tty"}
+
+var devSymlinks = map[string]string{
+	"fd":     "/proc/self/fd",
+	"stdin":  "/proc/self/fd/0",
+	"stdout": "/proc/self/fd/1",
+	"stderr": "/proc/self/fd/2",
+	"ptmx":   "pts/ptmx",
+}
+
+// setupDev mounts a tmpfs with the basic devices, devpts and /dev/shm on /dev of rootfs.
+func setupDev(rootfs string) error {
+	dev, err := mkdirAllInRoot(rootfs, "/dev", 0755)
+	if err != nil {
+		return err
+	}
+	if err := unix.Mount("tmpfs", dev, "tmpfs", unix.MS_NOSUID|unix.MS_STRICTATIME, "mode=755,size=65536k"); err != nil {
+		return fmt.Errorf("cannot mount /dev: %w", err)
+	}
+
+	for _, device := range devices {
+		dest, err := createMountpoint(dev, filepath.Join("/dev", device), device)
+		if err != nil {
+			return fmt.Errorf("cannot create /dev/%s: %w", device, err)
+		}
+		if err := unix.Mount(filepath.Join("/dev", device), dest, "", unix.MS_BIND, ""); err != nil {
+			return fmt.Errorf("cannot bind mount /dev/%s: %w", device, err)
+		}
+	}
+
+	pts := filepath.Join(dev, "pts")
+	if err := os.Mkdir(pts, 0755); err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_NOSUID | unix.MS_NOEXEC)
+	// the tty group (5) might not be mapped into the user namespace
+	if err := unix.Mount("devpts", pts, "devpts", flags, "newinstance,ptmxmode=0666,mode=0620,gid=5"); err != nil {
+		if err := unix.Mount("devpts", pts, "devpts", flags, "newinstance,ptmxmode=0666,mode=0620"); err != nil {
+			return fmt.Errorf("cannot mount /dev/pts: %w", err)
+		}
+	}
+
+	shm := filepath.Join(dev, "shm")
+	if err := os.Mkdir(shm, 01777); err != nil {
+		return err
+	}
+	flags = unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC
+	if err := unix.Mount("shm", shm, "tmpfs", flags, "mode=1777,size=65536k"); err != nil {
+		return fmt.Errorf("cannot mount /dev/shm: %w", err)
+	}
+
+	for name, target := range devSymlinks {
+		if err := os.Symlink(target, filepath.Join(dev, name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mountSys mounts a read-only sysfs into rootfs, the host /sys is bind mounted if the network namespace isn't ours.
+func mountSys(rootfs string) error {
+	sys, err := mkdirAllInRoot(rootfs, "/sys", 0555)
+	if err != nil {
+		return err
+	}
+	flags := uintptr(unix.MS_RDONLY | unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC)
+	if err := unix.Mount("sysfs", sys, "sysfs", flags, ""); err == nil {
+		return nil
+	}
+	if err := unix.Mount("/sys", sys, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("cannot bind mount /sys: %w", err)
+	}
+	return remountReadOnly(sys)
+}
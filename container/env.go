@@ -0,0 +1,198 @@
+package container
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const defaultPath = "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+
+// User is the user a container command runs as.
+type User struct {
+	Uid, Gid int
+}
+
+// ValidateUser checks users in the user[:group] format, names are resolved by ParseUser.
+func ValidateUser(user string) error {
+	if user == "" {
+		return nil
+	}
+	name, group, hasGroup := splitUser(user)
+	if name == "" || (hasGroup && group == "") || strings.ContainsRune(group, ':') {
+		return fmt.Errorf("invalid user %q, expected user[:group]", user)
+	}
+	for _, id := range []string{name, group} {
+		if number, err := strconv.Atoi(id); err == nil && number < 0 {
+			return fmt.Errorf("invalid ID %d in user %q", number, user)
+		}
+	}
+	return nil
+}
+
+// ParseUser parses users in the user[:group] format, it returns nil for container root. Names are looked up in the
+// container /etc/passwd and /etc/group, so it has to be called inside the container.
+func ParseUser(user string) (*User, error) {
+	if err := ValidateUser(user); err != nil || user == "" {
+		return nil, err
+	}
+	name, group, hasGroup := splitUser(user)
+	result := &User{}
+	if uid, err := strconv.Atoi(name); err == nil {
+		result.Uid, result.Gid = uid, uid
+	} else {
+		// name:password:uid:gid:gecos:home:shell
+		fields, err := lookupEntry("/etc/passwd", name, 4)
+		if err != nil {
+			return nil, fmt.Errorf("cannot find user %s: %w", name, err)
+		}
+		if result.Uid, err = strconv.Atoi(fields[2]); err != nil {
+			return nil, fmt.Errorf("invalid uid of user %s", name)
+		}
+		if result.Gid, err = strconv.Atoi(fields[3]); err != nil {
+			return nil, fmt.Errorf("invalid gid of user %s", name)
+		}
+	}
+	if !hasGroup {
+		return result, nil
+	}
+	if gid, err := strconv.Atoi(group); err == nil {
+		result.Gid = gid
+		return result, nil
+	}
+	// name:password:gid:members
+	fields, err := lookupEntry("/etc/group", group, 3)
+	if err != nil {
+		return nil, fmt.Errorf("cannot find group %s: %w", group, err)
+	}
+	if result.Gid, err = strconv.Atoi(fields[2]); err != nil {
+		return nil, fmt.Errorf("invalid gid of group %s", group)
+	}
+	return result, nil
+}
+
+func splitUser(user string) (name, group string, hasGroup bool) {
+	if i := strings.IndexByte(user, ':'); i != -1 {
+		return user[:i], user[i+1:], true
+	}
+	return user, "", false
+}
+
+// lookupEntry returns the fields of the entry named name in a passwd or group file, it has at least n fields.
+func lookupEntry(path, name string, n int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) >= n && fields[0] == name {
+			return fields, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("no entry in %s", path)
+}
+
+// ValidateEnv checks that environment variables are in the KEY=VALUE format.
+func ValidateEnv(env []string) error {
+	for _, variable := range env {
+		if strings.IndexByte(variable, '=') <= 0 {
+			return fmt.Errorf("invalid environment variable %q, expected KEY=VALUE", variable)
+		}
+	}
+	return nil
+}
+
+// commandEnv returns the default PATH, HOME, HOSTNAME and TERM variables overridden by env. It has to be called inside
+// the container.
+func commandEnv(env []string, user *User, interactive bool) []string {
+	uid := 0
+	if user != nil {
+		uid = user.Uid
+	}
+	defaults := []string{"PATH=" + defaultPath, "HOME=" + homeDir(uid)}
+	if hostname, err := os.Hostname(); err == nil {
+		defaults = append(defaults, "HOSTNAME="+hostname)
+	}
+	if interactive {
+		defaults = append(defaults, "TERM=xterm")
+	}
+
+	result := make([]string, 0, len(defaults)+len(env))
+	for _, variable := range defaults {
+		key := variable[:strings.IndexByte(variable, '=')+1]
+		if !hasEnv(env, key) {
+			result = append(result, variable)
+		}
+	}
+	return append(result, env...)
+}
+
+func hasEnv(env []string, key string) bool {
+	for _, variable := range env {
+		if strings.HasPrefix(variable, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// homeDir looks up the home directory of a user in the container /etc/passwd.
+func homeDir(uid int) string {
+	passwd, err := os.Open("/etc/passwd")
+	if err == nil {
+		defer passwd.Close()
+		scanner := bufio.NewScanner(passwd)
+		for scanner.Scan() {
+			// name:password:uid:gid:gecos:home:shell
+			fields := strings.Split(scanner.Text(), ":")
+			if len(fields) >= 6 && fields[2] == strconv.Itoa(uid) {
+				return fields[5]
+			}
+		}
+	}
+	if uid == 0 {
+		return "/root"
+	}
+	return "/"
+}
+
+// command creates a command running as user, it's looked up in the env PATH.
+func command(name string, args []string, env []string, user *User) *exec.Cmd {
+	for _, variable := range env {
+		if strings.HasPrefix(variable, "PATH=") {
+			_ = os.Setenv("PATH", strings.TrimPrefix(variable, "PATH="))
+		}
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Env = env
+	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	if user != nil {
+		cmd.SysProcAttr.Credential = &syscall.Credential{
+			Uid:         uint32(user.Uid),
+			Gid:         uint32(user.Gid),
+			Groups:      []uint32{},
+			NoSetGroups: !setgroupsAllowed(),
+		}
+	}
+	return cmd
+}
+
+// setgroupsAllowed reports whether setgroups isn't denied in our user namespace.
+func setgroupsAllowed() bool {
+	setgroups, err := ioutil.ReadFile("/proc/self/setgroups")
+	if err != nil {
+		return true // no user namespace support
+	}
+	return strings.TrimSpace(string(setgroups)) != "deny"
+}
@@ -0,0 +1,65 @@
+package container
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseUser(t *testing.T) {
+	tests := []struct {
+		user     string
+		expected *User
+	}{
+		{"", nil},
+		{"1000", &User{1000, 1000}},
+		{"1000:50", &User{1000, 50}},
+		{"0:1", &User{0, 1}},
+		{"root", &User{0, 0}}, // looked up in /etc/passwd, the test runs "inside the container"
+		{"root:root", &User{0, 0}},
+		{"65534:root", &User{65534, 0}},
+	}
+	for _, test := range tests {
+		user, err := ParseUser(test.user)
+		if err != nil {
+			t.Errorf("ParseUser(%q): %v", test.user, err)
+		} else if !reflect.DeepEqual(user, test.expected) {
+			t.Errorf("ParseUser(%q) = %+v, expected %+v", test.user, user, test.expected)
+		}
+	}
+
+	for _, user := range []string{":", "1000:", ":1000", "-1", "1000:-1", "1:2:3", "no-such-user", "0:no-such-group"} {
+		if parsed, err := ParseUser(user); err == nil {
+			t.Errorf("ParseUser(%q) = %+v, expected an error", user, parsed)
+		}
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := commandEnv([]string{"HOME=/data", "APP=1"}, nil, true)
+	expected := []string{"PATH=" + defaultPath, "HOSTNAME=" + hostname, "TERM=xterm", "HOME=/data", "APP=1"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("commandEnv = %v, expected %v", env, expected)
+	}
+
+	env = commandEnv([]string{"PATH=/app/bin", "TERM=dumb"}, &User{Uid: 0}, false)
+	expected = []string{"HOME=/root", "HOSTNAME=" + hostname, "PATH=/app/bin", "TERM=dumb"}
+	if !reflect.DeepEqual(env, expected) {
+		t.Errorf("commandEnv = %v, expected %v", env, expected)
+	}
+}
+
+func TestValidateEnv(t *testing.T) {
+	if err := ValidateEnv([]string{"A=1", "EMPTY=", "URL=a=b"}); err != nil {
+		t.Errorf("ValidateEnv: %v", err)
+	}
+	for _, variable := range []string{"A", "=1", ""} {
+		if err := ValidateEnv([]string{"A=1", variable}); err == nil {
+			t.Errorf("ValidateEnv accepted %q", variable)
+		}
+	}
+}
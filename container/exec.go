@@ -0,0 +1,172 @@
+package container
+
+import (
+	"cont/cgroup"
+	"cont/seccomp"
+	"cont/tty"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// ExecConfig configures a process started inside a running container.
+type ExecConfig struct {
+	Stdin           io.Reader
+	Stdout, Stderr  io.Writer
+	PID             int    // PID of the container init process
+	Workdir         string // container root if empty
+	Cmd             string
+	Args            []string
+	Env             []string // container environment variables, KEY=VALUE
+	User            string   // container user, uid[:gid]
+	Capabilities    []string // see ResolveCapabilities
+	NoNewPrivileges bool
+	Seccomp         *seccomp.Profile // nil disables filtering
+	Interactive     bool
+	Cgroup          *cgroup.Cgroup // container cgroup, optional
+}
+
+type execPipeConfig struct {
+	Workdir         string
+	Env             []string
+	User            string
+	Capabilities    []string
+	NoNewPrivileges bool
+	Seccomp         *seccomp.Profile
+	Interactive     bool
+}
+
+// Exec starts a process in all namespaces of a running container. nsenter joins the namespaces, the process then
+// runs the command as its child because joining a PID namespace only affects children.
+func Exec(ctx context.Context, config *ExecConfig) (*exec.Cmd, error) {
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", append([]string{"exec", config.Cmd}, config.Args...)...)
+	cmd.Stdout = config.Stdout
+	cmd.Stderr = config.Stderr
+	cmd.Env = []string{} // like in containers, the command gets its environment from the exec process
+
+	pipe, err := newInitPipe(cmd, execPipeConfig{
+		Workdir:         config.Workdir,
+		Env:             config.Env,
+		User:            config.User,
+		Capabilities:    config.Capabilities,
+		NoNewPrivileges: config.NoNewPrivileges,
+		Seccomp:         config.Seccomp,
+		Interactive:     config.Interactive,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer pipe.Close()
+
+	nses, err := containerNSes(config.PID)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFiles(nses)
+	addNSFiles(cmd, nses)
+
+	copyIO, err := setupExecIO(cmd, config)
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		copyIO(false)
+		return cmd, err
+	}
+	copyIO(true)
+
+	if config.Cgroup != nil {
+		if err := addToCgroup(config.Cgroup, cmd.Process.Pid); err != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return cmd, err
+		}
+	}
+	return cmd, pipe.start()
+}
+
+// setupExecIO connects the command to the exec streams, through a PTY for interactive sessions. The returned function
+// has to be called once the command started or failed to start.
+func setupExecIO(cmd *exec.Cmd, config *ExecConfig) (func(started bool), error) {
+	if config.Interactive {
+		pty, err := tty.OpenPTY()
+		if err != nil {
+			return nil, err
+		}
+		cmd.Stdin = pty.Slave
+		cmd.Stdout = pty.Slave
+		cmd.Stderr = pty.Slave
+		return func(started bool) {
+			_ = pty.Slave.Close()
+			if !started {
+				_ = pty.Master.Close()
+				return
+			}
+			go io.Copy(pty.Master, config.Stdin)
+			go func() {
+				_, _ = io.Copy(config.Stdout, pty.Master) // stops once the process exits and closes the slave
+				_ = pty.Master.Close()
+			}()
+		}, nil
+	}
+
+	stdin, stdinWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdin = stdin
+	return func(started bool) {
+		_ = stdin.Close()
+		if !started {
+			_ = stdinWriter.Close()
+			return
+		}
+		go func() {
+			_, _ = io.Copy(stdinWriter, config.Stdin)
+			_ = stdinWriter.Close()
+		}()
+	}, nil
+}
+
+// RunExec runs the exec command inside the container namespaces once Exec sends the start signal.
+func RunExec() error {
+	var config execPipeConfig
+	if err := readInitPipe(&config); err != nil {
+		return fmt.Errorf("cannot get exec config from init pipe: %w", err)
+	}
+	if config.Workdir != "" {
+		if err := os.Chdir(config.Workdir); err != nil {
+			return fmt.Errorf("cannot chdir to \"%s\": %w", config.Workdir, err)
+		}
+	}
+	user, err := ParseUser(config.User)
+	if err != nil {
+		return err
+	}
+
+	cmd := command(os.Args[2], os.Args[3:], commandEnv(config.Env, user, config.Interactive), user)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if config.Interactive {
+		var pty *tty.PTY
+		if err := startLimited(cmd, user, config.Capabilities, config.NoNewPrivileges, config.Seccomp, func() (err error) {
+			pty, err = tty.Start(cmd, os.Stdin, os.Stdout)
+			return err
+		}); err != nil {
+			return fmt.Errorf("cannot start TTY: %w", err)
+		}
+		defer pty.Close()
+		if err := cmd.Wait(); err != nil {
+			return fmt.Errorf("wait failed: %w", err)
+		}
+		return nil
+	}
+	if err := startLimited(cmd, user, config.Capabilities, config.NoNewPrivileges, config.Seccomp, cmd.Start); err != nil {
+		return err
+	}
+	return cmd.Wait()
+}
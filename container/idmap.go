@@ -0,0 +1,141 @@
+package container
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+const (
+	subuidFile = "/etc/subuid"
+	subgidFile = "/etc/subgid"
+)
+
+// IDMap maps a range of container user or group IDs to host IDs.
+type IDMap struct {
+	ContainerID, HostID, Size int
+}
+
+// resolveIDMaps returns the container ID maps and whether they have to be written by newuidmap & newgidmap. Container
+// root is mapped to the daemon user and the rest to its subordinate IDs by default.
+func resolveIDMaps(uidMaps, gidMaps []IDMap) ([]IDMap, []IDMap, bool, error) {
+	uid, gid := os.Getuid(), os.Getgid()
+	name := strconv.Itoa(uid)
+	if u, err := user.LookupId(name); err == nil {
+		name = u.Username
+	}
+	if len(uidMaps) == 0 {
+		uidMaps = defaultIDMaps(uid, name, subuidFile, "newuidmap")
+	}
+	if len(gidMaps) == 0 {
+		gidMaps = defaultIDMaps(gid, name, subgidFile, "newgidmap")
+	}
+	if uid == 0 || (isRootMap(uidMaps, uid) && isRootMap(gidMaps, gid)) {
+		return uidMaps, gidMaps, false, nil
+	}
+	// the maps are written after the init process is executed, it gets capabilities only if it's container root
+	if !mapsRoot(uidMaps, uid) || !mapsRoot(gidMaps, gid) {
+		return nil, nil, false, errors.New("container root has to be mapped to the daemon user and group")
+	}
+	return uidMaps, gidMaps, true, nil
+}
+
+func sysProcIDMaps(idMaps []IDMap) []syscall.SysProcIDMap {
+	result := make([]syscall.SysProcIDMap, 0, len(idMaps))
+	for _, m := range idMaps {
+		result = append(result, syscall.SysProcIDMap{ContainerID: m.ContainerID, HostID: m.HostID, Size: m.Size})
+	}
+	return result
+}
+
+// defaultIDMaps maps container root to id and the rest to the first subordinate ID range of the user.
+func defaultIDMaps(id int, name, subIDFile, helper string) []IDMap {
+	if id != 0 {
+		if _, err := exec.LookPath(helper); err != nil {
+			log.Printf("%s isn't available, mapping only container root", helper)
+			return []IDMap{{ContainerID: 0, HostID: id, Size: 1}}
+		}
+	}
+	start, size, err := subIDRange(subIDFile, name, id)
+	if err != nil {
+		log.Printf("cannot read subordinate IDs: %v", err)
+	}
+	if size > 0 {
+		return []IDMap{{ContainerID: 0, HostID: id, Size: 1}, {ContainerID: 1, HostID: start, Size: size}}
+	}
+	if id == 0 {
+		return []IDMap{{ContainerID: 0, HostID: 0, Size: 65536}}
+	}
+	return []IDMap{{ContainerID: 0, HostID: id, Size: 1}}
+}
+
+// subIDRange returns the first subordinate ID range of a user (by name or ID) from /etc/subuid or /etc/subgid.
+func subIDRange(path, name string, id int) (int, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// user:start:size
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), ":")
+		if len(fields) != 3 || (fields[0] != name && fields[0] != strconv.Itoa(id)) {
+			continue
+		}
+		start, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s entry %q", path, scanner.Text())
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid %s entry %q", path, scanner.Text())
+		}
+		return start, size, nil
+	}
+	return 0, 0, scanner.Err()
+}
+
+func isRootMap(idMaps []IDMap, id int) bool {
+	return len(idMaps) == 1 && idMaps[0] == IDMap{ContainerID: 0, HostID: id, Size: 1}
+}
+
+func mapsRoot(idMaps []IDMap, id int) bool {
+	for _, m := range idMaps {
+		if m.ContainerID == 0 {
+			return m.HostID == id
+		}
+	}
+	return false
+}
+
+// writeIDMaps writes the ID maps of a process user namespace with the newuidmap & newgidmap helpers.
+func writeIDMaps(pid int, uidMaps, gidMaps []IDMap) error {
+	if err := runIDMapHelper("newuidmap", pid, uidMaps); err != nil {
+		return err
+	}
+	return runIDMapHelper("newgidmap", pid, gidMaps)
+}
+
+// runIDMapHelper runs newuidmap or newgidmap, which check the maps against subordinate IDs of the user.
+func runIDMapHelper(helper string, pid int, idMaps []IDMap) error {
+	args := []string{strconv.Itoa(pid)}
+	for _, m := range idMaps {
+		args = append(args, strconv.Itoa(m.ContainerID), strconv.Itoa(m.HostID), strconv.Itoa(m.Size))
+	}
+	if out, err := exec.Command(helper, args...).CombinedOutput(); err != nil {
+		return fmt.Errorf("%s failed: %w: %s", helper, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSubIDRange(t *testing.T) {
+	dir, err := ioutil.TempDir("", "subid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "subuid")
+	subuid := "alice:100000:65536\n1001:200000:1000\nbob:300000:65536\nbob:400000:65536\nbroken:x:1\n"
+	if err := ioutil.WriteFile(path, []byte(subuid), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		id          int
+		start, size int
+	}{
+		{"alice", 1000, 100000, 65536},
+		{"carol", 1001, 200000, 1000}, // entries can name the user by ID
+		{"bob", 1002, 300000, 65536},  // only the first range is used
+		{"dave", 1003, 0, 0},
+	}
+	for _, test := range tests {
+		start, size, err := subIDRange(path, test.name, test.id)
+		if err != nil || start != test.start || size != test.size {
+			t.Errorf("subIDRange(%s) = %d, %d, %v, expected %d, %d", test.name, start, size, err, test.start,
+				test.size)
+		}
+	}
+	if _, _, err := subIDRange(path, "broken", 1004); err == nil {
+		t.Error("subIDRange of an invalid entry didn't fail")
+	}
+	if start, size, err := subIDRange(filepath.Join(dir, "missing"), "alice", 1000); err != nil || size != 0 {
+		t.Errorf("subIDRange without the file = %d, %d, %v, expected no range", start, size, err)
+	}
+
+	// root keeps its IDs without subordinate IDs, container root is mapped to it otherwise
+	expected := []IDMap{{ContainerID: 0, HostID: 0, Size: 65536}}
+	if idMaps := defaultIDMaps(0, "root", path, "newuidmap"); !reflect.DeepEqual(idMaps, expected) {
+		t.Errorf("defaultIDMaps of root = %v, expected %v", idMaps, expected)
+	}
+	if err := ioutil.WriteFile(path, []byte("root:100000:65536\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected = []IDMap{{ContainerID: 0, HostID: 0, Size: 1}, {ContainerID: 1, HostID: 100000, Size: 65536}}
+	if idMaps := defaultIDMaps(0, "root", path, "newuidmap"); !reflect.DeepEqual(idMaps, expected) {
+		t.Errorf("defaultIDMaps of root with subordinate IDs = %v, expected %v", idMaps, expected)
+	}
+}
@@ -0,0 +1,105 @@
+package container
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ignoredSignals aren't forwarded to the command: SIGCHLD is handled by the init process and the Go runtime uses
+// SIGURG to preempt goroutines.
+var ignoredSignals = map[os.Signal]bool{
+	syscall.SIGCHLD: true,
+	syscall.SIGURG:  true,
+}
+
+// ExitError is returned by RunChild if the container command doesn't exit successfully.
+type ExitError struct {
+	Status syscall.WaitStatus
+}
+
+func (e *ExitError) Error() string {
+	if e.Status.Signaled() {
+		return fmt.Sprintf("command killed by %s", unix.SignalName(e.Status.Signal()))
+	}
+	return fmt.Sprintf("command exited with %d", e.Status.ExitStatus())
+}
+
+// ExitCode returns the command exit code, 128 + signal number if the command was killed by a signal.
+func (e *ExitError) ExitCode() int {
+	if e.Status.Signaled() {
+		return 128 + int(e.Status.Signal())
+	}
+	return e.Status.ExitStatus()
+}
+
+// notifyInitSignals catches all signals to forward them to the command. It has to be called before the command starts,
+// so no SIGCHLD is missed.
+func notifyInitSignals() chan os.Signal {
+	signals := make(chan os.Signal, 32)
+	signal.Notify(signals)
+	return signals
+}
+
+// waitCommand forwards signals to the command process group and reaps all zombies until the command exits.
+func waitCommand(pid int, signals chan os.Signal) error {
+	defer signal.Stop(signals)
+
+	for sig := range signals {
+		if !ignoredSignals[sig] {
+			// the command is a process group leader, the group might already be gone
+			_ = syscall.Kill(-pid, sig.(syscall.Signal))
+			continue
+		}
+		if sig != syscall.SIGCHLD {
+			continue
+		}
+		status, exited, err := reap(pid)
+		if err != nil {
+			return err
+		}
+		if !exited {
+			continue
+		}
+		if status.Exited() && status.ExitStatus() == 0 {
+			return nil
+		}
+		return &ExitError{Status: status}
+	}
+	return nil
+}
+
+// reap reaps all exited children and reports whether the command process is one of them.
+func reap(pid int) (syscall.WaitStatus, bool, error) {
+	var commandStatus syscall.WaitStatus
+	exited := false
+	for {
+		var status syscall.WaitStatus
+		child, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err == syscall.ECHILD || (err == nil && child == 0) {
+			return commandStatus, exited, nil
+		}
+		if err != nil {
+			return commandStatus, exited, fmt.Errorf("cannot reap children: %w", err)
+		}
+		if child == pid {
+			commandStatus, exited = status, true
+		}
+	}
+}
+
+func setSubreaper() error {
+	return unix.Prctl(unix.PR_SET_CHILD_SUBREAPER, 1, 0, 0, 0)
+}
+
+// Pause is the command of pod infra containers, it keeps the pod namespaces alive until it's stopped.
+func Pause() {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+	<-signals
+}
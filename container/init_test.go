@@ -0,0 +1,104 @@
+package container
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+// startCommand starts a shell script like the init process starts the container command. The test process is the
+// init process, it reaps the command with waitCommand.
+func startCommand(t *testing.T, script string) (*exec.Cmd, chan os.Signal) {
+	t.Helper()
+	signals := notifyInitSignals()
+	cmd := exec.Command("sh", "-c", script)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd, signals
+}
+
+func TestWaitCommand(t *testing.T) {
+	if err := setSubreaper(); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, signals := startCommand(t, "exit 0")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitCommand(cmd.Process.Pid, signals); err != nil {
+		t.Errorf("waitCommand of a successful command: %v", err)
+	}
+
+	// the orphaned sleep is reparented to the init process and has to be reaped
+	cmd, signals = startCommand(t, "(sleep 0.1 &); sleep 0.3; exit 3")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var exitErr *ExitError
+	if err := waitCommand(cmd.Process.Pid, signals); !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Errorf("waitCommand = %v, expected exit code 3", err)
+	}
+	if zombies := zombieChildren(t); len(zombies) != 0 {
+		t.Errorf("zombie children %v weren't reaped", zombies)
+	}
+}
+
+func TestWaitCommandForwardsSignals(t *testing.T) {
+	cmd, signals := startCommand(t, `trap "exit 7" USR1; trap "" TERM; echo ready; while :; do sleep 0.01; done`)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bufio.NewReader(stdout).ReadString('\n'); err != nil {
+		t.Fatal(err)
+	}
+
+	// signals sent to the init process go to the command process group, the test process itself isn't affected
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	var exitErr *ExitError
+	if err := waitCommand(cmd.Process.Pid, signals); !errors.As(err, &exitErr) || exitErr.ExitCode() != 7 {
+		t.Errorf("waitCommand = %v, expected the trap exit code 7", err)
+	}
+}
+
+func zombieChildren(t *testing.T) []int {
+	t.Helper()
+	entries, err := ioutil.ReadDir("/proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var zombies []int
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		status, err := ioutil.ReadFile("/proc/" + entry.Name() + "/status")
+		if err != nil {
+			continue
+		}
+		var state, ppid string
+		for _, line := range strings.Split(string(status), "\n") {
+			if strings.HasPrefix(line, "State:") {
+				state = strings.TrimSpace(strings.TrimPrefix(line, "State:"))
+			} else if strings.HasPrefix(line, "PPid:") {
+				ppid = strings.TrimSpace(strings.TrimPrefix(line, "PPid:"))
+			}
+		}
+		if ppid == strconv.Itoa(os.Getpid()) && strings.HasPrefix(state, "Z") {
+			zombies = append(zombies, pid)
+		}
+	}
+	return zombies
+}
@@ -0,0 +1,152 @@
+package container
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Mount is a bind mount of a host path into the container.
+type Mount struct {
+	Source, Destination string
+	ReadOnly            bool
+}
+
+// bindMounts bind mounts all mounts into root, the (future) container root directory.
+func bindMounts(root string, mounts []Mount) error {
+	for _, m := range mounts {
+		dest, err := createMountpoint(root, m.Source, m.Destination)
+		if err != nil {
+			return fmt.Errorf("cannot create mount point %s: %w", m.Destination, err)
+		}
+		if err := unix.Mount(m.Source, dest, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("cannot bind mount %s to %s: %w", m.Source, m.Destination, err)
+		}
+		if !m.ReadOnly {
+			continue
+		}
+		if err := remountReadOnly(dest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remountReadOnly makes a bind mount read-only, keeping the locked flags of its source.
+func remountReadOnly(path string) error {
+	flags, err := mountFlags(path)
+	if err != nil {
+		return err
+	}
+	if err := unix.Mount("", path, "", flags|unix.MS_BIND|unix.MS_REMOUNT|unix.MS_RDONLY, ""); err != nil {
+		return fmt.Errorf("cannot remount %s read-only: %w", path, err)
+	}
+	return nil
+}
+
+// Tmpfs is a tmpfs mounted into the container.
+type Tmpfs struct {
+	Destination string
+	Options     string // comma separated mount flags (ro, noexec, ...) and tmpfs options (size=64m, mode=1777, ...)
+}
+
+// tmpfsFlags are the mount flags of tmpfs options, true sets and false clears the flag.
+var tmpfsFlags = map[string]struct {
+	flag uintptr
+	set  bool
+}{
+	"ro":     {unix.MS_RDONLY, true},
+	"rw":     {unix.MS_RDONLY, false},
+	"noexec": {unix.MS_NOEXEC, true},
+	"exec":   {unix.MS_NOEXEC, false},
+	"nosuid": {unix.MS_NOSUID, true},
+	"suid":   {unix.MS_NOSUID, false},
+	"nodev":  {unix.MS_NODEV, true},
+	"dev":    {unix.MS_NODEV, false},
+}
+
+// mountTmpfs mounts tmpfs filesystems into root, noexec, nosuid and nodev unless the options say otherwise.
+func mountTmpfs(root string, tmpfs []Tmpfs) error {
+	for _, t := range tmpfs {
+		dest, err := mkdirAllInRoot(root, t.Destination, 0755)
+		if err != nil {
+			return fmt.Errorf("cannot create mount point %s: %w", t.Destination, err)
+		}
+		flags, data := parseTmpfsOptions(t.Options)
+		if err := unix.Mount("tmpfs", dest, "tmpfs", flags, data); err != nil {
+			return fmt.Errorf("cannot mount tmpfs on %s: %w", t.Destination, err)
+		}
+	}
+	return nil
+}
+
+func parseTmpfsOptions(options string) (uintptr, string) {
+	flags := uintptr(unix.MS_NOEXEC | unix.MS_NOSUID | unix.MS_NODEV)
+	data := make([]string, 0)
+	for _, option := range strings.Split(options, ",") {
+		if option == "" {
+			continue
+		}
+		if f, ok := tmpfsFlags[option]; ok {
+			if f.set {
+				flags |= f.flag
+			} else {
+				flags &^= f.flag
+			}
+			continue
+		}
+		data = append(data, option)
+	}
+	return flags, strings.Join(data, ",")
+}
+
+// createMountpoint creates a directory or an empty file to mount source on at dest inside root and returns its host
+// path.
+func createMountpoint(root, source, dest string) (string, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return mkdirAllInRoot(root, dest, 0755)
+	}
+	path, err := resolveInRoot(root, dest)
+	if err != nil {
+		return "", err
+	}
+	if filepath.Clean(root) == "/" {
+		return path, existsOnHost(path)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|unix.O_NOFOLLOW, 0644)
+	if err != nil {
+		return "", err
+	}
+	return path, file.Close()
+}
+
+// mountFlags returns the flags path is mounted with that a bind remount has to preserve.
+func mountFlags(path string) (uintptr, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return 0, fmt.Errorf("cannot statfs %s: %w", path, err)
+	}
+	var flags uintptr
+	for st, ms := range map[int64]uintptr{
+		unix.ST_NOSUID:     unix.MS_NOSUID,
+		unix.ST_NODEV:      unix.MS_NODEV,
+		unix.ST_NOEXEC:     unix.MS_NOEXEC,
+		unix.ST_NOATIME:    unix.MS_NOATIME,
+		unix.ST_NODIRATIME: unix.MS_NODIRATIME,
+		unix.ST_RELATIME:   unix.MS_RELATIME,
+	} {
+		if stat.Flags&st != 0 {
+			flags |= ms
+		}
+	}
+	return flags, nil
+}
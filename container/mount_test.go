@@ -0,0 +1,29 @@
+package container
+
+import (
+	"golang.org/x/sys/unix"
+	"testing"
+)
+
+func TestParseTmpfsOptions(t *testing.T) {
+	const defaults = unix.MS_NOEXEC | unix.MS_NOSUID | unix.MS_NODEV
+	tests := []struct {
+		options string
+		flags   uintptr
+		data    string
+	}{
+		{"", defaults, ""},
+		{"size=64m", defaults, "size=64m"},
+		{"exec,size=64m,mode=1777", unix.MS_NOSUID | unix.MS_NODEV, "size=64m,mode=1777"},
+		{"ro,suid,dev", unix.MS_RDONLY | unix.MS_NOEXEC, ""},
+		{"ro,rw", defaults, ""},
+		{"exec,noexec,,uid=1000", defaults, "uid=1000"},
+	}
+	for _, test := range tests {
+		flags, data := parseTmpfsOptions(test.options)
+		if flags != test.flags || data != test.data {
+			t.Errorf("parseTmpfsOptions(%q) = %#x, %q, expected %#x, %q", test.options, flags, data, test.flags,
+				test.data)
+		}
+	}
+}
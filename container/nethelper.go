@@ -0,0 +1,214 @@
+package container
+
+import (
+	"bytes"
+	"cont/network"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	socketOK     = 0
+	socketFailed = 1
+)
+
+// NetHelper creates sockets in the network namespace of a container, e.g. to proxy published ports. Sockets keep
+// their namespace, so the daemon can connect them without entering it (which needs root).
+type NetHelper struct {
+	cmd    *exec.Cmd
+	socket *os.File
+	mutex  sync.Mutex
+}
+
+type netHelperConfig struct {
+	Socket int
+}
+
+// StartNetHelper starts a helper in the network namespace of a container init process, it runs until it's closed.
+func StartNetHelper(pid int) (*NetHelper, error) {
+	cmd, socket, _, err := startNSHelper(pid, "nethelper", func(socket int) interface{} {
+		return netHelperConfig{Socket: socket}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &NetHelper{cmd: cmd, socket: socket}, nil
+}
+
+// Dial connects to a port on the container loopback interface. network is "tcp" or "udp".
+func (h *NetHelper) Dial(network string, port int) (net.Conn, error) {
+	var socketType int
+	switch network {
+	case "tcp":
+		socketType = unix.SOCK_STREAM
+	case "udp":
+		socketType = unix.SOCK_DGRAM
+	default:
+		return nil, fmt.Errorf("unknown network %s", network)
+	}
+	fd, err := h.newSocket(socketType)
+	if err != nil {
+		return nil, err
+	}
+	file := os.NewFile(uintptr(fd), network)
+	defer file.Close()
+	if err := unix.Connect(fd, &unix.SockaddrInet4{Port: port, Addr: [4]byte{127, 0, 0, 1}}); err != nil {
+		return nil, err
+	}
+	return net.FileConn(file)
+}
+
+// ListenUDP binds a UDP socket to an address in the container network namespace.
+func (h *NetHelper) ListenUDP(ip net.IP, port int) (net.PacketConn, error) {
+	fd, err := h.newSocket(unix.SOCK_DGRAM)
+	if err != nil {
+		return nil, err
+	}
+	file := os.NewFile(uintptr(fd), "udp")
+	defer file.Close()
+	address := &unix.SockaddrInet4{Port: port}
+	copy(address.Addr[:], ip.To4())
+	if err := unix.Bind(fd, address); err != nil {
+		return nil, fmt.Errorf("cannot bind %s: %w", net.JoinHostPort(ip.String(), strconv.Itoa(port)), err)
+	}
+	return net.FilePacketConn(file)
+}
+
+// newSocket returns a new socket of the container network namespace.
+func (h *NetHelper) newSocket(socketType int) (int, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if _, err := h.socket.Write([]byte{byte(socketType)}); err != nil {
+		return -1, fmt.Errorf("cannot create socket in container network namespace: %w", err)
+	}
+	fd, err := receiveFD(h.socket)
+	if err != nil {
+		return -1, fmt.Errorf("cannot create socket in container network namespace: %w", err)
+	}
+	return fd, nil
+}
+
+// Close stops the helper.
+func (h *NetHelper) Close() error {
+	h.socket.Close()
+	return h.cmd.Wait()
+}
+
+// RunNetHelper creates a socket for every request until the daemon closes the connection.
+func RunNetHelper() error {
+	var config netHelperConfig
+	if err := readInitPipe(&config); err != nil {
+		return err
+	}
+	// the container init process sets it up once it's started, sockets bound to loopback addresses before that reply
+	// from the wrong address
+	if err := network.LoopbackUp(); err != nil {
+		return err
+	}
+	request := make([]byte, 1)
+	for {
+		n, err := unix.Read(config.Socket, request)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		fd, err := unix.Socket(unix.AF_INET, int(request[0])|unix.SOCK_CLOEXEC, 0)
+		if err != nil {
+			if err := unix.Sendmsg(config.Socket, []byte{socketFailed}, nil, nil, 0); err != nil {
+				return err
+			}
+			continue
+		}
+		err = unix.Sendmsg(config.Socket, []byte{socketOK}, unix.UnixRights(fd), nil, 0)
+		unix.Close(fd)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// startNSHelper starts a helper in the user & network namespaces of a process, connected through a socket pair.
+func startNSHelper(pid int, command string, config func(socket int) interface{}) (*exec.Cmd, *os.File, *bytes.Buffer, error) {
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	parent, child := os.NewFile(uintptr(fds[0]), command), os.NewFile(uintptr(fds[1]), command)
+	defer child.Close()
+
+	cmd := exec.Command("/proc/self/exe", command)
+	cmd.Env = []string{}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	cmd.ExtraFiles = []*os.File{child}
+
+	pipe, err := newInitPipe(cmd, config(3))
+	if err != nil {
+		parent.Close()
+		return nil, nil, nil, err
+	}
+	defer pipe.Close()
+
+	nses, err := containerNSes(pid, "user", "net")
+	if err != nil {
+		parent.Close()
+		return nil, nil, nil, err
+	}
+	defer closeFiles(nses)
+	addNSFiles(cmd, nses)
+
+	if err := cmd.Start(); err != nil {
+		parent.Close()
+		return nil, nil, nil, err
+	}
+	if err := pipe.start(); err != nil {
+		parent.Close()
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, nil, nil, err
+	}
+	return cmd, parent, &stderr, nil
+}
+
+// receiveFD receives a file descriptor sent by a helper after a status byte.
+func receiveFD(socket *os.File) (int, error) {
+	status, oob := make([]byte, 1), make([]byte, unix.CmsgSpace(4))
+	n, oobn, _, _, err := unix.Recvmsg(int(socket.Fd()), status, oob, unix.MSG_CMSG_CLOEXEC)
+	if err != nil {
+		return -1, err
+	}
+	if n == 0 {
+		return -1, fmt.Errorf("helper exited")
+	}
+	messages, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		return -1, err
+	}
+	if status[0] != socketOK || len(messages) == 0 {
+		return -1, fmt.Errorf("helper failed")
+	}
+	fds, err := unix.ParseUnixRights(&messages[0])
+	if err != nil {
+		return -1, err
+	}
+	if len(fds) == 0 {
+		return -1, fmt.Errorf("no file received")
+	}
+	return fds[0], nil
+}
+
+func firstLine(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '\n'); i != -1 {
+		return s[:i]
+	}
+	return s
+}
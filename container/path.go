@@ -0,0 +1,91 @@
+package container
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// maxSymlinks is the number of symlinks resolveInRoot follows before giving up, like the kernel ELOOP limit.
+const maxSymlinks = 40
+
+// resolveInRoot resolves path inside root the way the kernel would once root is the root directory, neither symlinks
+// nor .. can leave root. Missing components are kept, the result is a host path. Mounts into a rootfs happen before
+// pivoting, they have to use it so image symlinks can't point them to the host.
+func resolveInRoot(root, path string) (string, error) {
+	root = filepath.Clean(root)
+	resolved := "/"
+	remaining := filepath.Clean("/" + path)
+	links := 0
+	for remaining != "" {
+		var part string
+		remaining = strings.TrimLeft(remaining, "/")
+		if i := strings.IndexByte(remaining, '/'); i >= 0 {
+			part, remaining = remaining[:i], remaining[i:]
+		} else {
+			part, remaining = remaining, ""
+		}
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if os.IsNotExist(err) {
+			resolved = next
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			resolved = next
+			continue
+		}
+		if links++; links > maxSymlinks {
+			return "", fmt.Errorf("too many symlinks in %s", path)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = "/"
+		}
+		remaining = target + "/" + remaining
+	}
+
+	result := filepath.Join(root, resolved)
+	if root != "/" && result != root && !strings.HasPrefix(result, root+string(filepath.Separator)) {
+		return "", errors.New("path escapes the root directory")
+	}
+	return result, nil
+}
+
+// mkdirAllInRoot creates a directory with its parents inside root and returns its host path. Nothing is created in the
+// host root directory.
+func mkdirAllInRoot(root, path string, mode os.FileMode) (string, error) {
+	dir, err := resolveInRoot(root, path)
+	if err != nil {
+		return "", err
+	}
+	if filepath.Clean(root) == "/" {
+		return dir, existsOnHost(dir)
+	}
+	return dir, os.MkdirAll(dir, mode)
+}
+
+// existsOnHost checks a mount point of a container without a rootfs, they aren't created on the host filesystem.
+func existsOnHost(path string) error {
+	_, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("%s doesn't exist, containers without a rootfs only mount on existing paths", path)
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package container
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolveInRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	for _, dir := range []string{"usr/lib", "data"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for link, target := range map[string]string{
+		"etc":      "/host/etc",
+		"lib":      "usr/lib",
+		"up":       "../../..",
+		"abs":      "/data",
+		"loop":     "loop",
+		"data/rel": "../usr",
+	} {
+		if err := os.Symlink(target, filepath.Join(root, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path, expected string
+	}{
+		{"/", ""},
+		{"/data", "data"},
+		{"data/new/dir", "data/new/dir"},
+		{"/../../data", "data"},
+		{"/etc/passwd", "host/etc/passwd"},
+		{"/lib/x", "usr/lib/x"},
+		{"/up", ""},
+		{"/up/etc/shadow", "host/etc/shadow"},
+		{"/abs/file", "data/file"},
+		{"/data/rel/lib", "usr/lib"},
+		{"/missing/../data", "data"},
+	}
+	for _, test := range tests {
+		resolved, err := resolveInRoot(root, test.path)
+		if err != nil {
+			t.Errorf("resolveInRoot(%q): %v", test.path, err)
+			continue
+		}
+		if expected := filepath.Join(root, test.expected); resolved != expected {
+			t.Errorf("resolveInRoot(%q) = %s, expected %s", test.path, resolved, expected)
+		}
+	}
+
+	if _, err := resolveInRoot(root, "/loop/x"); err == nil {
+		t.Error("resolveInRoot didn't fail on a symlink loop")
+	}
+}
+
+func TestMkdirAllInRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	host, err := ioutil.TempDir("", "host")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(host)
+
+	if err := os.Symlink(host, filepath.Join(root, "etc")); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := mkdirAllInRoot(root, "/etc/app", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(root, host, "app"); dir != expected {
+		t.Errorf("mkdirAllInRoot = %s, expected %s", dir, expected)
+	}
+	if _, err := os.Stat(filepath.Join(host, "app")); !os.IsNotExist(err) {
+		t.Errorf("mkdirAllInRoot created a directory outside of the root: %v", err)
+	}
+
+	// containers without a rootfs only mount on existing host paths
+	if _, err := mkdirAllInRoot("/", host, 0755); err != nil {
+		t.Errorf("mkdirAllInRoot failed on an existing host directory: %v", err)
+	}
+	missing := filepath.Join(host, "missing")
+	if _, err := mkdirAllInRoot("/", missing, 0755); err == nil {
+		t.Error("mkdirAllInRoot didn't fail on a missing host directory")
+	}
+	if _, err := os.Stat(missing); !os.IsNotExist(err) {
+		t.Errorf("mkdirAllInRoot created a host directory: %v", err)
+	}
+}
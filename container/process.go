@@ -0,0 +1,62 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const exitPollInterval = 500 * time.Millisecond
+
+// ProcessStartTime returns the start time of a process in clock ticks after boot, together with the PID it identifies a
+// process.
+func ProcessStartTime(pid int) (uint64, error) {
+	fields, err := processStat(pid)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(fields[startTimeField], 10, 64)
+}
+
+// IsRunning reports whether the process with the PID and start time is running and isn't a zombie.
+func IsRunning(pid int, startTime uint64) bool {
+	fields, err := processStat(pid)
+	if err != nil || fields[stateField] == "Z" {
+		return false
+	}
+	current, err := strconv.ParseUint(fields[startTimeField], 10, 64)
+	return err == nil && current == startTime
+}
+
+// WaitForExit waits for a process which isn't our child to exit, e.g. a container started by a previous daemon.
+func WaitForExit(pid int, startTime uint64) {
+	for IsRunning(pid, startTime) {
+		time.Sleep(exitPollInterval)
+	}
+}
+
+// fields of /proc/<pid>/stat after the command name, the state is the 3rd field
+const (
+	stateField     = 0
+	startTimeField = 19
+)
+
+// processStat returns fields of /proc/<pid>/stat after the command name.
+func processStat(pid int) ([]string, error) {
+	stat, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return nil, err
+	}
+	// the command name can contain spaces and parentheses, fields after it are separated by spaces
+	end := strings.LastIndexByte(string(stat), ')')
+	if end == -1 {
+		return nil, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	fields := strings.Fields(string(stat[end+1:]))
+	if len(fields) <= startTimeField {
+		return nil, fmt.Errorf("invalid stat of process %d", pid)
+	}
+	return fields, nil
+}
@@ -0,0 +1,42 @@
+package container
+
+import (
+	"os"
+	"os/exec"
+	"testing"
+)
+
+func TestIsRunning(t *testing.T) {
+	startTime, err := ProcessStartTime(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsRunning(os.Getpid(), startTime) {
+		t.Error("the test process isn't running")
+	}
+	if IsRunning(os.Getpid(), startTime+1) {
+		t.Error("a reused PID is reported as running")
+	}
+
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if startTime, err = ProcessStartTime(cmd.Process.Pid); err != nil {
+		t.Fatal(err)
+	}
+	if !IsRunning(cmd.Process.Pid, startTime) {
+		t.Error("a started child isn't running")
+	}
+	if err := cmd.Process.Kill(); err != nil {
+		t.Fatal(err)
+	}
+	WaitForExit(cmd.Process.Pid, startTime) // returns for zombies, before the child is reaped
+	_ = cmd.Wait()
+	if IsRunning(cmd.Process.Pid, startTime) {
+		t.Error("a reaped child is running")
+	}
+	if _, err := ProcessStartTime(cmd.Process.Pid); err == nil {
+		t.Error("ProcessStartTime of a reaped child didn't fail")
+	}
+}
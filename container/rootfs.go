@@ -0,0 +1,145 @@
+package container
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"os"
+	"path/filepath"
+)
+
+const oldRootDir = ".old_root" // temporary old root mount point inside the new rootfs
+
+// maskedPaths are hidden from containers, files by /dev/null and directories by an empty read-only tmpfs.
+var maskedPaths = []string{
+	"/proc/acpi",
+	"/proc/asound",
+	"/proc/kcore",
+	"/proc/keys",
+	"/proc/latency_stats",
+	"/proc/timer_list",
+	"/proc/timer_stats",
+	"/proc/sched_debug",
+	"/proc/scsi",
+	"/sys/firmware",
+}
+
+// readOnlyPaths are kernel interfaces containers can read but not write.
+var readOnlyPaths = []string{
+	"/proc/bus",
+	"/proc/fs",
+	"/proc/irq",
+	"/proc/sys",
+	"/proc/sysrq-trigger",
+}
+
+// setupRootfs prepares the container mount namespace and pivots into rootfs if there is one.
+func setupRootfs(rootfs string, mounts []Mount, tmpfs []Tmpfs) error {
+	// make sure our mounts don't propagate back to the host
+	if err := unix.Mount("", "/", "", unix.MS_REC|unix.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("cannot make root mount private: %w", err)
+	}
+	if rootfs == "" {
+		if err := mountProc("/"); err != nil {
+			return err
+		}
+		if err := bindMounts("/", mounts); err != nil {
+			return err
+		}
+		return mountTmpfs("/", tmpfs)
+	}
+	rootfs, err := filepath.Abs(rootfs)
+	if err != nil {
+		return err
+	}
+	// pivot_root requires the new root to be a mount point
+	if err := unix.Mount(rootfs, rootfs, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+		return fmt.Errorf("cannot bind mount rootfs: %w", err)
+	}
+	// proc is mounted before pivoting, user namespaces can only mount proc while another proc mount is visible
+	if err := mountProc(rootfs); err != nil {
+		return err
+	}
+	if err := setupDev(rootfs); err != nil {
+		return err
+	}
+	if err := mountSys(rootfs); err != nil {
+		return err
+	}
+	if err := bindMounts(rootfs, mounts); err != nil {
+		return err
+	}
+	if err := mountTmpfs(rootfs, tmpfs); err != nil {
+		return err
+	}
+	if err := pivotRoot(rootfs); err != nil {
+		return fmt.Errorf("cannot pivot root to \"%s\": %w", rootfs, err)
+	}
+	return nil
+}
+
+// pivotRoot makes rootfs the root filesystem of the current mount namespace and detaches the old root.
+func pivotRoot(rootfs string) error {
+	oldRoot, err := mkdirAllInRoot(rootfs, oldRootDir, 0700)
+	if err != nil {
+		return fmt.Errorf("cannot create old root dir: %w", err)
+	}
+	if err := unix.PivotRoot(rootfs, oldRoot); err != nil {
+		return fmt.Errorf("pivot_root failed: %w", err)
+	}
+	if err := os.Chdir("/"); err != nil {
+		return fmt.Errorf("cannot chdir to new root: %w", err)
+	}
+	oldRoot = filepath.Join("/", oldRootDir)
+	if err := unix.Unmount(oldRoot, unix.MNT_DETACH); err != nil {
+		return fmt.Errorf("cannot detach old root: %w", err)
+	}
+	if err := os.Remove(oldRoot); err != nil {
+		return fmt.Errorf("cannot remove old root dir: %w", err)
+	}
+	return nil
+}
+
+// mountProc mounts a new proc filesystem of the container PID namespace into root.
+func mountProc(root string) error {
+	proc, err := mkdirAllInRoot(root, "/proc", 0555) // rootfs might not contain a proc directory
+	if err != nil {
+		return err
+	}
+	if err := unix.Mount("proc", proc, "proc", unix.MS_NOSUID|unix.MS_NODEV|unix.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("cannot mount new proc fs: %w", err)
+	}
+	return nil
+}
+
+// protectPaths masks and write protects kernel interfaces in /proc and /sys once the container root is set up.
+func protectPaths() error {
+	for _, path := range maskedPaths {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			err = unix.Mount("tmpfs", path, "tmpfs", unix.MS_RDONLY, "size=0")
+		} else {
+			err = unix.Mount("/dev/null", path, "", unix.MS_BIND, "")
+		}
+		if err != nil {
+			return fmt.Errorf("cannot mask %s: %w", path, err)
+		}
+	}
+	for _, path := range readOnlyPaths {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		if err := unix.Mount(path, path, "", unix.MS_BIND|unix.MS_REC, ""); err != nil {
+			return fmt.Errorf("cannot bind mount %s: %w", path, err)
+		}
+		if err := remountReadOnly(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
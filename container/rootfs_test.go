@@ -0,0 +1,72 @@
+package container
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestSetupRootfsHelper runs in the mount & PID namespaces created by TestSetupRootfs, it isn't a test of its own.
+func TestSetupRootfsHelper(t *testing.T) {
+	rootfs := os.Getenv("CONT_TEST_ROOTFS")
+	if rootfs == "" {
+		t.Skip("helper process of TestSetupRootfs")
+	}
+	fail := func(format string, args ...interface{}) {
+		fmt.Fprintf(os.Stderr, format+"\n", args...)
+		os.Exit(1)
+	}
+	if err := setupRootfs(rootfs, nil, []Tmpfs{{Destination: "/tmp"}}); err != nil {
+		fail("%v", err)
+	}
+	if content, err := ioutil.ReadFile("/etc/hostname"); err != nil || string(content) != "rootfs" {
+		fail("/etc/hostname = %q, %v, expected the rootfs file", content, err)
+	}
+	if _, err := os.Stat(rootfs); !os.IsNotExist(err) {
+		fail("the host path of the rootfs is reachable: %v", err)
+	}
+	if _, err := os.Stat("/" + oldRootDir); !os.IsNotExist(err) {
+		fail("the old root directory wasn't removed: %v", err)
+	}
+	if self, err := os.Readlink("/proc/self"); err != nil || self != "1" {
+		fail("/proc/self = %s, %v, expected the proc fs of the new PID namespace", self, err)
+	}
+	for _, path := range []string{"/dev/null", "/sys/kernel", "/tmp"} {
+		if _, err := os.Stat(path); err != nil {
+			fail("%s is missing: %v", path, err)
+		}
+	}
+	os.Exit(0)
+}
+
+func TestSetupRootfs(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("mounting a rootfs requires root")
+	}
+	rootfs, err := ioutil.TempDir("", "rootfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootfs)
+	if err := os.Mkdir(filepath.Join(rootfs, "etc"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(rootfs, "etc/hostname"), []byte("rootfs"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSetupRootfsHelper$")
+	cmd.Env = append(os.Environ(), "CONT_TEST_ROOTFS="+rootfs)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWNS | syscall.CLONE_NEWPID}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("container setup failed: %v: %s", err, out)
+	}
+	// the rootfs directory is left as it was, mount points aside
+	if _, err := os.Stat(filepath.Join(rootfs, oldRootDir)); !os.IsNotExist(err) {
+		t.Errorf("the old root directory is left in the rootfs: %v", err)
+	}
+}
@@ -1,64 +1,114 @@
 package container
 
 import (
-	"cont/tty"
 	"context"
-	"fmt"
-	"golang.org/x/sys/unix"
-	"io"
 	"os"
 	"os/exec"
 	"syscall"
 )
 
-func Start(ctx context.Context, config *Config) (*exec.Cmd, error) {
-	cmd := exec.CommandContext(ctx, "/proc/self/exe", append([]string{"init", config.Cmd}, config.Args...)...)
-	cmd.Stdin = config.Stdin
-	cmd.Stdout = config.Stdout
-	cmd.Stderr = config.Stderr
+// Process is a created container, its command runs once Start is called so clients can attach first.
+type Process struct {
+	Cmd  *exec.Cmd
+	pipe *initPipe
+}
+
+// Start signals the init process to run the container command.
+func (p *Process) Start() error {
+	defer p.pipe.Close()
+	return p.pipe.start()
+}
+
+// Kill kills the init process of a container whose command wasn't started.
+func (p *Process) Kill() {
+	p.pipe.Close()
+	_ = p.Cmd.Process.Kill()
+	_ = p.Cmd.Wait()
+}
 
-	if err := setupLogging(cmd, config); err != nil {
-		return nil, fmt.Errorf("cannot setup logging: %w", err)
+func Start(ctx context.Context, config *Config) (*exec.Cmd, error) {
+	process, err := Create(ctx, config)
+	if err != nil {
+		return nil, err
 	}
+	return process.Cmd, process.Start()
+}
+
+// Create starts the container init process, the container command runs once Process.Start is called.
+func Create(ctx context.Context, config *Config) (*Process, error) {
+	cmd := exec.CommandContext(ctx, "/proc/self/exe", append([]string{"init", config.Cmd}, config.Args...)...)
 
-	cmd.Env = os.Environ() // todo: shouldn't share environ?
-	if err := setupEnv(cmd, config); err != nil {
+	// the container only gets named pipes, in interactive mode the init process sets up a PTY on top of them
+	stdio, err := createFifos(config.StdioDir)
+	if err != nil {
 		return nil, err
 	}
+	defer closeFiles(stdio[:])
+	cmd.Stdin = stdio[0]
+	cmd.Stdout = stdio[1]
+	cmd.Stderr = stdio[2]
 
-	if config.Interactive {
-		pty, err := tty.OpenPTY() // todo: close PTY on container exit
-		if err != nil {
+	cmd.Env = []string{} // the init process sets up the command environment, nothing leaks from the daemon
+
+	var uidMaps, gidMaps []IDMap
+	useHelpers := false
+	if config.SharedNamespaceConfig.Flags == 0 {
+		if uidMaps, gidMaps, useHelpers, err = resolveIDMaps(config.UidMappings, config.GidMappings); err != nil {
 			return nil, err
 		}
-		stdout := cmd.Stdout // setupLogging set up stdout
-
-		// attach to PTY
-		cmd.Stdin = pty.Slave
-		cmd.Stdout = pty.Slave
-		cmd.Stderr = pty.Slave
-
-		go io.Copy(pty.Master, config.Stdin)
-		go io.Copy(stdout, pty.Master) // write output to previously set up logging
+	}
+	pipe, err := setupEnv(cmd, config, useHelpers)
+	if err != nil {
+		return nil, err
 	}
 
-	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	// own session, signals sent to the daemon process group (e.g. ^C) don't reach the container
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
 
 	if config.SharedNamespaceConfig.Flags == 0 { // we're not sharing anything
-		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUTS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET | syscall.CLONE_NEWUSER | syscall.CLONE_NEWIPC | unix.CLONE_NEWCGROUP
-		cmd.SysProcAttr.UidMappings = []syscall.SysProcIDMap{
-			{ContainerID: 0, HostID: os.Getuid(), Size: 1},
-		}
-		cmd.SysProcAttr.GidMappings = []syscall.SysProcIDMap{
-			{ContainerID: 0, HostID: os.Getgid(), Size: 1},
+		// cgroup NS is unshared by the init process once it's in the container cgroup
+		cmd.SysProcAttr.Cloneflags |= syscall.CLONE_NEWUTS | syscall.CLONE_NEWPID | syscall.CLONE_NEWNS | syscall.CLONE_NEWNET | syscall.CLONE_NEWUSER | syscall.CLONE_NEWIPC
+		if !useHelpers {
+			// the maps are written before the init process is executed, it runs as container root
+			cmd.SysProcAttr.UidMappings = sysProcIDMaps(uidMaps)
+			cmd.SysProcAttr.GidMappings = sysProcIDMaps(gidMaps)
+			cmd.SysProcAttr.GidMappingsEnableSetgroups = os.Getuid() == 0
+			cmd.SysProcAttr.Credential = &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: os.Getuid() != 0}
 		}
 	} else {
 		if err := setupSharedNSes(cmd, config); err != nil {
+			pipe.Close()
 			return nil, err
 		}
 	}
 
-	return cmd, cmd.Start()
+	if err := cmd.Start(); err != nil {
+		pipe.Close()
+		return nil, err
+	}
+	if useHelpers { // the init process waits for the maps on the init pipe
+		if err := writeIDMaps(cmd.Process.Pid, uidMaps, gidMaps); err != nil {
+			pipe.Close()
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return nil, err
+		}
+	}
+	if config.Cgroup != nil {
+		if err := addToCgroup(config.Cgroup, cmd.Process.Pid); err != nil {
+			pipe.Close()
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return nil, err
+		}
+	}
+	if err := Attach(config); err != nil {
+		pipe.Close()
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, err
+	}
+	return &Process{Cmd: cmd, pipe: pipe}, nil
 }
 
 func Run(ctx context.Context, config *Config) (*exec.Cmd, error) {
@@ -1,76 +1,107 @@
 package container
 
 import (
+	"cont/network"
 	"cont/tty"
 	"fmt"
+	"golang.org/x/sys/unix"
 	"os"
-	"os/exec"
-	"strings"
 	"syscall"
 )
 
-func countOpenFiles() int64 {
-	out, err := exec.Command("/bin/sh", "-c", fmt.Sprintf("lsof -p %v", os.Getpid())).Output()
-	if err != nil {
-		fmt.Println(err.Error())
-	}
-	lines := strings.Split(string(out), "\n")
-	return int64(len(lines) - 1)
-}
-
 func RunChild() error {
-	cmd := exec.Command(os.Args[2], os.Args[3:]...)
-
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-
 	env, err := getEnv()
 	if err != nil {
 		return fmt.Errorf("cannot get environment from init pipe: %w", err)
 	}
+	if env.Reexec {
+		return reexecMapped(env)
+	}
+
+	if env.CgroupNS {
+		if err := unix.Unshare(unix.CLONE_NEWCGROUP); err != nil {
+			return fmt.Errorf("cannot unshare cgroup namespace: %w", err)
+		}
+	}
 
 	//if env.SharedNamespaceConfig.Share {
 	//	attachToNSes()
 	//}
 
-	if err := syscall.Sethostname([]byte(env.Hostname)); err != nil {
-		return fmt.Errorf("cannot set hostname \"%s\": %w", env.Hostname, err)
+	if !isNSSelected("uts", env.SharedNamespaceConfig.Flags) { // the hostname belongs to the other container
+		if err := syscall.Sethostname([]byte(env.Hostname)); err != nil {
+			return fmt.Errorf("cannot set hostname \"%s\": %w", env.Hostname, err)
+		}
 	}
-	if err := os.Chdir("/"); err != nil {
-		return fmt.Errorf("cannot chdir to root: %w", err)
+	if !isNSSelected("net", env.SharedNamespaceConfig.Flags) {
+		if err := network.LoopbackUp(); err != nil {
+			return fmt.Errorf("cannot set up loopback interface: %w", err)
+		}
 	}
-	if err := syscall.Mount("proc", "proc", "proc", 0, ""); err != nil {
-		return fmt.Errorf("cannot mount new proc fs: %w", err)
+	if !isNSSelected("mnt", env.SharedNamespaceConfig.Flags) { // the filesystem belongs to the other container
+		if err := setupRootfs(env.Rootfs, env.Mounts, env.Tmpfs); err != nil {
+			return fmt.Errorf("cannot setup rootfs: %w", err)
+		}
+		if err := os.Chdir("/"); err != nil {
+			return fmt.Errorf("cannot chdir to root: %w", err)
+		}
+		if err := protectPaths(); err != nil {
+			return fmt.Errorf("cannot protect kernel interfaces: %w", err)
+		}
+		if env.ReadOnly {
+			if err := remountReadOnly("/"); err != nil {
+				return err
+			}
+		}
 	}
 
 	if err := os.Chdir(env.Workdir); err != nil {
 		return fmt.Errorf("cannot chdir to \"%s\": %w", env.Workdir, err)
 	}
 
+	user, err := ParseUser(env.User)
+	if err != nil {
+		return err
+	}
+	cmd := command(os.Args[2], os.Args[3:], commandEnv(env.Env, user, env.Interactive), user)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := setSubreaper(); err != nil {
+		return fmt.Errorf("cannot become a subreaper: %w", err)
+	}
+	signals := notifyInitSignals()
+
 	isInteractive := env.Interactive
 
 	if isInteractive {
-		pty, err := tty.Start(cmd, os.Stdin, os.Stdout)
-		if err != nil {
+		var pty *tty.PTY
+		if err := startLimited(cmd, user, env.Capabilities, env.NoNewPrivileges, env.Seccomp, func() (err error) {
+			pty, err = tty.Start(cmd, os.Stdin, os.Stdout) // the command is a session leader
+			return err
+		}); err != nil {
 			return fmt.Errorf("cannot start TTY: %w", err)
 		}
 		defer pty.Close()
-		err = cmd.Wait()
-		if err != nil {
-			return fmt.Errorf("wait failed: %w", err)
-		}
-		return nil
+		return waitCommand(cmd.Process.Pid, signals)
 	} else {
-		if err := cmd.Run(); err != nil {
+		cmd.SysProcAttr.Setpgid = true
+		if err := startLimited(cmd, user, env.Capabilities, env.NoNewPrivileges, env.Seccomp, cmd.Start); err != nil {
+			return err
+		}
+		if err := waitCommand(cmd.Process.Pid, signals); err != nil {
 			return err
 		}
 	}
 
+	if isNSSelected("mnt", env.SharedNamespaceConfig.Flags) {
+		return nil
+	}
 	if err := os.Chdir("/"); err != nil {
 		return err
 	}
-	if err := syscall.Unmount("proc", 0); err != nil {
+	if err := syscall.Unmount("proc", syscall.MNT_DETACH); err != nil { // masked paths are mounted on top of it
 		return err
 	}
 	return nil
@@ -1,6 +1,7 @@
 package container
 
 import (
+	"cont/cgroup"
 	"encoding/gob"
 	"errors"
 	"fmt"
@@ -11,124 +12,264 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"syscall"
 )
 
-func setupEnv(cmd *exec.Cmd, config *Config) error {
+// initPipe is the parent side of the init pipe, the init process reads its config and waits for the start signal on it.
+type initPipe struct {
+	child, parent *os.File
+	encoder       *gob.Encoder
+}
+
+func setupEnv(cmd *exec.Cmd, config *Config, reexec bool) (*initPipe, error) {
+	return newInitPipe(cmd, initPipeConfig{
+		Hostname:              config.Hostname,
+		Workdir:               config.Workdir,
+		Rootfs:                config.Rootfs,
+		Mounts:                config.Mounts,
+		Tmpfs:                 config.Tmpfs,
+		ReadOnly:              config.ReadOnly,
+		Env:                   config.Env,
+		User:                  config.User,
+		Capabilities:          config.Capabilities,
+		NoNewPrivileges:       config.NoNewPrivileges,
+		Seccomp:               config.Seccomp,
+		Interactive:           config.Interactive,
+		CgroupNS:              config.SharedNamespaceConfig.Flags&unix.CLONE_NEWCGROUP == 0,
+		Reexec:                reexec,
+		SharedNamespaceConfig: config.SharedNamespaceConfig,
+	})
+}
+
+// newInitPipe passes the init pipe to the command and sends it the config.
+func newInitPipe(cmd *exec.Cmd, config interface{}) (*initPipe, error) {
 	r, w, err := os.Pipe()
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer w.Close()
+	pipe := &initPipe{child: r, parent: w, encoder: gob.NewEncoder(w)}
 	if cmd.ExtraFiles == nil {
 		cmd.ExtraFiles = make([]*os.File, 0, 1)
 	}
 	cmd.ExtraFiles = append(cmd.ExtraFiles, r)
 	cmd.Env = append(cmd.Env, fmt.Sprintf(initPipeEnv+"=%d", 2+len(cmd.ExtraFiles)))
 
-	return gob.NewEncoder(w).Encode(initPipeConfig{
-		Hostname:              config.Hostname,
-		Workdir:               config.Workdir,
-		Interactive:           config.Interactive,
-		SharedNamespaceConfig: config.SharedNamespaceConfig,
-	})
+	if err := pipe.encoder.Encode(config); err != nil {
+		pipe.Close()
+		return nil, err
+	}
+	return pipe, nil
+}
+
+// start signals the container init process to continue.
+func (p *initPipe) start() error {
+	return p.encoder.Encode(true)
+}
+
+func (p *initPipe) Close() error {
+	_ = p.child.Close()
+	return p.parent.Close()
+}
+
+// reexecMapped re-executes the init process once its ID maps are written, it had no capabilities when it was executed
+// unmapped.
+func reexecMapped(config initPipeConfig) error {
+	var fds [2]int
+	if err := syscall.Pipe(fds[:]); err != nil { // without O_CLOEXEC, the read end is inherited
+		return err
+	}
+	w := os.NewFile(uintptr(fds[1]), "pipe")
+	config.Reexec = false
+	encoder := gob.NewEncoder(w)
+	if err := encoder.Encode(config); err != nil {
+		return err
+	}
+	if err := encoder.Encode(true); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	env := []string{fmt.Sprintf(initPipeEnv+"=%d", fds[0])}
+	return syscall.Exec("/proc/self/exe", os.Args, env)
 }
 
 func getEnv() (result initPipeConfig, err error) {
+	return result, readInitPipe(&result)
+}
+
+// readInitPipe decodes the config from the init pipe and waits for the start signal.
+func readInitPipe(config interface{}) error {
 	fdString, ok := os.LookupEnv(initPipeEnv)
 	if !ok {
-		return result, fmt.Errorf("cannot get init pipe FD from environment")
+		return fmt.Errorf("cannot get init pipe FD from environment")
 	}
 	fd, err := strconv.Atoi(fdString)
 	if err != nil {
-		return result, err
+		return err
 	}
 	file := os.NewFile(uintptr(fd), "pipe")
 	if file == nil {
-		return result, fmt.Errorf("cannot use an init pipe fd %d: opened file is nil", fd)
+		return fmt.Errorf("cannot use an init pipe fd %d: opened file is nil", fd)
 	}
 	defer file.Close()
-	if err = gob.NewDecoder(file).Decode(&result); err != nil {
-		return result, fmt.Errorf("cannot decode from init pipe: %w", err)
+	decoder := gob.NewDecoder(file)
+	if err = decoder.Decode(config); err != nil {
+		return fmt.Errorf("cannot decode from init pipe: %w", err)
+	}
+	var start bool
+	if err = decoder.Decode(&start); err != nil {
+		return fmt.Errorf("didn't receive a start signal: %w", err)
 	}
-	return result, nil
+	return nil
 }
 
+// isNSSelected reports whether a namespace (a /proc/<pid>/ns name) is selected by CLONE_NEW* flags.
 func isNSSelected(ns string, flags int) bool {
-	switch ns {
-	case "cgroup":
-		return flags&unix.CLONE_NEWCGROUP != 0
-	case "ipc":
-		return flags&syscall.CLONE_NEWIPC != 0
-	case "mnt":
-		return flags&syscall.CLONE_NEWNS != 0
-	case "net":
-		return flags&syscall.CLONE_NEWNET != 0
-	case "pid":
-		return flags&syscall.CLONE_NEWPID != 0
-	case "pid_for_children":
-		log.Println("pid_for_children not implemented")
-		return false
-	case "user":
-		return flags&syscall.CLONE_NEWUSER != 0
-	case "uts", "time":
-		return flags&syscall.CLONE_NEWUTS != 0
-	default:
+	flag, ok := nsFlags[strings.TrimSuffix(ns, "_for_children")]
+	if !ok {
 		log.Println(errors.New("invalid ns " + ns))
 		return false
 	}
+	return flags&flag != 0
+}
+
+// setupSharedNSes passes the namespaces of the other container to nsenter, which joins them before creating the others.
+func setupSharedNSes(cmd *exec.Cmd, config *Config) error {
+	shared := config.SharedNamespaceConfig
+	nses, err := containerNSes(shared.PID, nsNames(shared.Flags|unix.CLONE_NEWUSER)...)
+	if err != nil {
+		return err
+	}
+	addNSFiles(cmd, nses)
+	unshare := (unix.CLONE_NEWUTS | unix.CLONE_NEWPID | unix.CLONE_NEWNS | unix.CLONE_NEWNET | unix.CLONE_NEWIPC) &^ shared.Flags
+	cmd.Env = append(cmd.Env, fmt.Sprintf(nsUnshareEnv+"=%d", unshare))
+	return nil
+}
+
+// nsFlags are the CLONE_NEW* flags of namespaces by their /proc/<pid>/ns names.
+var nsFlags = map[string]int{
+	"cgroup": unix.CLONE_NEWCGROUP,
+	"ipc":    unix.CLONE_NEWIPC,
+	"mnt":    unix.CLONE_NEWNS,
+	"net":    unix.CLONE_NEWNET,
+	"pid":    unix.CLONE_NEWPID,
+	"time":   CloneNewTime,
+	"user":   unix.CLONE_NEWUSER,
+	"uts":    unix.CLONE_NEWUTS,
+}
+
+// nsNames returns the names of namespaces selected by CLONE_NEW* flags.
+func nsNames(flags int) []string {
+	names := make([]string, 0, len(nsFlags))
+	for name, flag := range nsFlags {
+		if flags&flag != 0 {
+			names = append(names, name)
+		}
+	}
+	return names
 }
 
-func getNses(config SharedNamespaceConfig) ([]*os.File, error) {
-	nses := make([]*os.File, 0, 4)
+// addNSFiles passes namespace files to the command, nsenter joins them before the Go runtime starts.
+func addNSFiles(cmd *exec.Cmd, nses []*os.File) {
+	nsStartFd := 3 + len(cmd.ExtraFiles)
+	nsEndFd := nsStartFd + len(nses)
+
+	cmd.Env = append(cmd.Env,
+		fmt.Sprintf(nsStartEnv+"=%d", nsStartFd),
+		fmt.Sprintf(nsEndEnv+"=%d", nsEndFd),
+	)
+	if cmd.ExtraFiles == nil {
+		cmd.ExtraFiles = make([]*os.File, 0, len(nses))
+	}
+	cmd.ExtraFiles = append(cmd.ExtraFiles, nses...)
+}
 
-	nsPath := fmt.Sprintf("/proc/%d/ns", config.PID)
+// containerNSes opens the namespaces (all or the named ones) of a process which we aren't in, the user namespace first.
+// The PID namespace is the one of the process children.
+func containerNSes(pid int, names ...string) ([]*os.File, error) {
+	nsPath := fmt.Sprintf("/proc/%d/ns", pid)
 	dir, err := ioutil.ReadDir(nsPath)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read ns-es: %w", err)
 	}
+	nses := make([]*os.File, 0, len(dir))
 	for _, f := range dir {
-		if !isNSSelected(f.Name(), config.Flags) {
-			continue // NS not selected
+		name, file := f.Name(), f.Name()
+		if name == "pid" {
+			continue
+		}
+		if name == "pid_for_children" {
+			name = "pid"
+		} else if strings.HasSuffix(name, "_for_children") {
+			continue // the same as the process namespace once the process is running
+		}
+		if len(names) > 0 && !containsString(names, name) {
+			continue
 		}
-		log.Printf("will try to share %s namespace", f.Name())
-		ns, err := os.Open(filepath.Join(nsPath, f.Name()))
+		current, err := os.Stat(filepath.Join("/proc/self/ns", name))
 		if err != nil {
-			for _, n := range nses {
-				n.Close()
-			}
+			closeFiles(nses)
+			return nil, fmt.Errorf("cannot stat own %s ns: %w", name, err)
+		}
+		ns, err := os.Open(filepath.Join(nsPath, file))
+		if err != nil {
+			closeFiles(nses)
 			return nil, fmt.Errorf("cannot open ns: %w", err)
 		}
-		nses = append(nses, ns)
-	}
-	for i, ns := range nses {
-		if ns.Name() == "user" { // make sure user NS is the first available NS in the list (if user is shared)
-			tmp := nses[0]
-			nses[0] = nses[i]
-			nses[i] = tmp
-			break
+		info, err := ns.Stat()
+		if err != nil {
+			ns.Close()
+			closeFiles(nses)
+			return nil, fmt.Errorf("cannot stat %s ns: %w", name, err)
+		}
+		if os.SameFile(current, info) { // joining our own namespace might not be permitted (e.g. time for non-root)
+			ns.Close()
+			continue
+		}
+		if name == "user" {
+			nses = append([]*os.File{ns}, nses...)
+		} else {
+			nses = append(nses, ns)
 		}
 	}
 	return nses, nil
 }
 
-func setupSharedNSes(cmd *exec.Cmd, config *Config) error {
-	cmd.SysProcAttr.Cloneflags ^= uintptr(config.SharedNamespaceConfig.Flags) // unset cloning NS-es we share
-	nses, err := getNses(config.SharedNamespaceConfig)
-	if err != nil {
-		return err
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
-	nsStartFd := 3 + len(cmd.ExtraFiles)
-	nsEndFd := nsStartFd + len(nses)
+	return false
+}
 
-	cmd.Env = append(cmd.Env,
-		fmt.Sprintf(nsStartEnv+"=%d", nsStartFd),
-		fmt.Sprintf(nsEndEnv+"=%d", nsEndFd),
-	)
-	log.Printf("start %d end %d\n", nsStartFd, nsEndFd)
-	if cmd.ExtraFiles == nil {
-		cmd.ExtraFiles = make([]*os.File, 0, len(nses))
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// addToCgroup moves a process and its children into a cgroup, processes joining a PID namespace fork (see nsenter).
+func addToCgroup(c *cgroup.Cgroup, pid int) error {
+	if err := c.AddProcess(pid); err != nil {
+		return err
+	}
+	children, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/task/%d/children", pid, pid))
+	if err != nil {
+		return fmt.Errorf("cannot read children of %d: %w", pid, err)
+	}
+	for _, child := range strings.Fields(string(children)) {
+		childPid, err := strconv.Atoi(child)
+		if err != nil {
+			return err
+		}
+		if err := c.AddProcess(childPid); err != nil {
+			return err
+		}
 	}
-	cmd.ExtraFiles = append(cmd.ExtraFiles, nses...)
 	return nil
 }
@@ -0,0 +1,60 @@
+package container
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestContainerNSes(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("creating namespaces requires root")
+	}
+	cmd := exec.Command("sleep", "60")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Cloneflags: syscall.CLONE_NEWUTS | syscall.CLONE_NEWIPC | syscall.CLONE_NEWPID}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	// only namespaces the process doesn't share with us are joined, its PID namespace through pid_for_children
+	nses, err := containerNSes(cmd.Process.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"ipc", "pid", "uts"}
+	if names := nsTypes(t, nses); strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Errorf("containerNSes = %v, expected %v", names, expected)
+	}
+	closeFiles(nses)
+
+	if nses, err = containerNSes(cmd.Process.Pid, "uts", "net"); err != nil {
+		t.Fatal(err)
+	}
+	if names := nsTypes(t, nses); len(names) != 1 || names[0] != "uts" {
+		t.Errorf("containerNSes of uts & net = %v, expected only the unshared uts", names)
+	}
+	closeFiles(nses)
+}
+
+// nsTypes returns the sorted namespace types of namespace files.
+func nsTypes(t *testing.T, nses []*os.File) []string {
+	t.Helper()
+	names := make([]string, 0, len(nses))
+	for _, ns := range nses {
+		link, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", ns.Fd()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, link[:strings.IndexByte(link, ':')])
+	}
+	sort.Strings(names)
+	return names
+}
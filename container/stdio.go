@@ -0,0 +1,77 @@
+package container
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+var fifoNames = [3]string{"stdin", "stdout", "stderr"}
+
+// createFifos creates the container stdio named pipes in dir and opens the container side of them. The container opens
+// them read-write, so it doesn't get SIGPIPE or EOF while the daemon is down.
+func createFifos(dir string) ([3]*os.File, error) {
+	var files [3]*os.File
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return files, fmt.Errorf("cannot create stdio directory: %w", err)
+	}
+	for i, name := range fifoNames {
+		path := filepath.Join(dir, name)
+		if err := syscall.Mkfifo(path, 0600); err != nil && !os.IsExist(err) {
+			closeFiles(files[:i])
+			return files, fmt.Errorf("cannot create %s pipe: %w", name, err)
+		}
+		file, err := os.OpenFile(path, os.O_RDWR, 0)
+		if err != nil {
+			closeFiles(files[:i])
+			return files, fmt.Errorf("cannot open %s pipe: %w", name, err)
+		}
+		files[i] = file
+	}
+	return files, nil
+}
+
+// Attach connects the stdio named pipes of a container started by a previous daemon to the config streams and the log.
+func Attach(config *Config) error {
+	stdin, err := os.OpenFile(filepath.Join(config.StdioDir, fifoNames[0]), os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("cannot open stdin pipe: %w", err)
+	}
+	stdout, err := os.OpenFile(filepath.Join(config.StdioDir, fifoNames[1]), os.O_RDONLY, 0)
+	if err != nil {
+		stdin.Close()
+		return fmt.Errorf("cannot open stdout pipe: %w", err)
+	}
+	stderr, err := os.OpenFile(filepath.Join(config.StdioDir, fifoNames[2]), os.O_RDONLY, 0)
+	if err != nil {
+		stdin.Close()
+		stdout.Close()
+		return fmt.Errorf("cannot open stderr pipe: %w", err)
+	}
+	output, errOutput, err := setupLogging(config)
+	if err != nil {
+		stdin.Close()
+		stdout.Close()
+		stderr.Close()
+		return fmt.Errorf("cannot setup logging: %w", err)
+	}
+
+	go func() {
+		_, _ = io.Copy(stdin, config.Stdin)
+		stdin.Close()
+	}()
+	go copyOutput(output, stdout)
+	go copyOutput(errOutput, stderr)
+	return nil
+}
+
+// copyOutput copies container output until the container exits, which closes the last writer of the pipe.
+func copyOutput(w io.Writer, pipe *os.File) {
+	defer pipe.Close()
+	if _, err := io.Copy(w, pipe); err != nil {
+		log.Printf("cannot copy container output: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package container
+
+import (
+	"cont/network"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"net"
+	"os"
+)
+
+// TapConfig configures a TAP device in a container network namespace.
+type TapConfig struct {
+	Name    string
+	MAC     net.HardwareAddr
+	Address *net.IPNet
+	Gateway net.IP // default route
+}
+
+type tapPipeConfig struct {
+	Name    string
+	MAC     string
+	Address string
+	Gateway string
+	Socket  int // the device is sent back over this socket
+}
+
+// CreateTap creates a TAP device in the network namespace of a container through a helper in its namespaces.
+func CreateTap(pid int, config TapConfig) (*os.File, error) {
+	cmd, socket, stderr, err := startNSHelper(pid, "tap", func(socket int) interface{} {
+		return tapPipeConfig{
+			Name:    config.Name,
+			MAC:     config.MAC.String(),
+			Address: config.Address.String(),
+			Gateway: config.Gateway.String(),
+			Socket:  socket,
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer socket.Close()
+
+	fd, receiveErr := receiveFD(socket)
+	if err := cmd.Wait(); err != nil {
+		if receiveErr == nil {
+			unix.Close(fd)
+		}
+		return nil, fmt.Errorf("cannot create TAP device: %w: %s", err, firstLine(stderr.String()))
+	}
+	if receiveErr != nil {
+		return nil, receiveErr
+	}
+	// non-blocking, so closing it interrupts reads
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, err
+	}
+	return os.NewFile(uintptr(fd), "tap"), nil
+}
+
+// RunTap is the TAP helper process, it runs in the container user & network namespaces.
+func RunTap() error {
+	var config tapPipeConfig
+	if err := readInitPipe(&config); err != nil {
+		return err
+	}
+	mac, err := net.ParseMAC(config.MAC)
+	if err != nil {
+		return err
+	}
+	ip, address, err := net.ParseCIDR(config.Address)
+	if err != nil {
+		return err
+	}
+	address.IP = ip
+	tap, err := network.SetupTap(config.Name, mac, address, net.ParseIP(config.Gateway))
+	if err != nil {
+		return err
+	}
+	defer tap.Close()
+	return unix.Sendmsg(config.Socket, []byte{socketOK}, unix.UnixRights(int(tap.Fd())), nil, 0)
+}
@@ -0,0 +1,35 @@
+package daemon
+
+import (
+	"cont/api"
+	"cont/cgroup"
+	"errors"
+	"github.com/google/uuid"
+)
+
+// setupCgroup creates the cgroup of a container if a cgroup subtree is delegated or limits are requested.
+func (s *server) setupCgroup(id uuid.UUID, request *api.ContainerRequest) (*cgroup.Cgroup, error) {
+	var resources cgroup.Resources
+	if r := request.Resources; r != nil {
+		resources = cgroup.Resources{
+			Memory: r.Memory,
+			CPUs:   r.Cpus,
+			Pids:   r.Pids,
+			IO:     r.IoMax,
+		}
+	}
+	if s.cgroupParent == "" {
+		if resources.Memory != 0 || resources.CPUs != 0 || resources.Pids != 0 || len(resources.IO) != 0 {
+			return nil, errors.New("resource limits require a delegated cgroup v2 subtree")
+		}
+		return nil, nil
+	}
+	return cgroup.New(s.cgroupParent, id.String(), resources)
+}
+
+// containerCgroup returns the cgroup of a container, nil once it's released.
+func (s *server) containerCgroup(c *Container) *cgroup.Cgroup {
+	s.currentlyRunningMutex.RLock()
+	defer s.currentlyRunningMutex.RUnlock()
+	return c.cgroup
+}
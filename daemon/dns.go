@@ -0,0 +1,147 @@
+package daemon
+
+import (
+	"cont/api"
+	"cont/container"
+	"cont/network"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"log"
+	"net"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	hostsFile      = "hosts"
+	resolvConfFile = "resolv.conf"
+)
+
+// validateDNS checks the DNS options of a container request.
+func validateDNS(request *api.ContainerRequest) error {
+	for _, nameserver := range request.Dns {
+		if net.ParseIP(nameserver) == nil {
+			return fmt.Errorf("invalid nameserver %s", nameserver)
+		}
+	}
+	for _, domain := range request.DnsSearch {
+		if domain == "" || strings.ContainsAny(domain, " \t\n") {
+			return fmt.Errorf("invalid search domain %q", domain)
+		}
+	}
+	for _, host := range request.ExtraHosts {
+		if _, _, err := network.ParseExtraHost(host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dnsMounts returns the bind mounts of the generated /etc/hosts & /etc/resolv.conf, unless the user mounts them.
+func (s *server) dnsMounts(c *Container, mounts []container.Mount) []container.Mount {
+	if sharesNS(c.Spec, unix.CLONE_NEWNS) {
+		return nil
+	}
+	result := make([]container.Mount, 0, 2)
+	for _, file := range []string{hostsFile, resolvConfFile} {
+		destination := filepath.Join("/etc", file)
+		if !isMounted(mounts, destination) {
+			result = append(result, container.Mount{Source: filepath.Join(s.containerDir(c.Id), file), Destination: destination})
+		}
+	}
+	return result
+}
+
+func isMounted(mounts []container.Mount, destination string) bool {
+	for _, m := range mounts {
+		if filepath.Clean("/"+m.Destination) == destination {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDNSFiles writes the /etc/hosts & /etc/resolv.conf of a connected container.
+func (s *server) writeDNSFiles(c *Container) error {
+	if sharesNS(c.Spec, unix.CLONE_NEWNS) {
+		return nil
+	}
+	var ip net.IP
+	if endpoint := networkEndpoint(c); endpoint != nil {
+		ip, _, _ = net.ParseCIDR(endpoint.IP)
+	}
+	names := make([]string, 0, 2)
+	if c.Spec.Hostname != "" {
+		names = append(names, c.Spec.Hostname)
+	}
+	if c.Name != "" && c.Name != c.Spec.Hostname {
+		names = append(names, c.Name)
+	}
+	dir := s.containerDir(c.Id)
+	if err := ioutil.WriteFile(filepath.Join(dir, hostsFile), network.HostsFile(ip, names, c.Spec.ExtraHosts), 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, resolvConfFile), network.ResolvConf(c.Spec.DnsSearch), 0644)
+}
+
+// startDNS starts the DNS server of a container in its network namespace.
+func (s *server) startDNS(c *Container) error {
+	if sharesNS(c.Spec, unix.CLONE_NEWNET) {
+		return nil
+	}
+	helper, err := s.startNetHelper(c)
+	if err != nil {
+		return err
+	}
+	conn, err := helper.ListenUDP(network.DNSIP, network.DNSPort)
+	if err != nil {
+		return fmt.Errorf("cannot start DNS server: %w", err)
+	}
+	upstream := c.Spec.Dns
+	if len(upstream) == 0 {
+		upstream = network.HostNameservers()
+	}
+	c.dns = network.NewDNSServer(conn, s.resolver(c), upstream)
+	go func(dns *network.DNSServer) {
+		if err := dns.Run(); err != nil {
+			log.Printf("DNS server of container %s failed: %v", c.Id.String(), err)
+		}
+	}(c.dns)
+	return nil
+}
+
+func (s *server) stopDNS(c *Container) {
+	if c.dns == nil {
+		return
+	}
+	if err := c.dns.Close(); err != nil {
+		log.Printf("cannot stop DNS server of container %s: %v", c.Id.String(), err)
+	}
+	c.dns = nil
+}
+
+// resolver resolves the names of running containers on the network of a container.
+func (s *server) resolver(c *Container) network.Resolver {
+	return func(name string) net.IP {
+		s.currentlyRunningMutex.RLock()
+		defer s.currentlyRunningMutex.RUnlock()
+
+		endpoint := networkEndpoint(c)
+		if name == "" || endpoint == nil || endpoint.Network == network.Slirp {
+			return nil
+		}
+		for _, other := range s.currentlyRunning {
+			otherEndpoint := networkEndpoint(other)
+			if otherEndpoint == nil || otherEndpoint.Network != endpoint.Network || !strings.EqualFold(other.Name, name) {
+				continue
+			}
+			ip, _, err := net.ParseCIDR(otherEndpoint.IP)
+			if err != nil {
+				return nil
+			}
+			return ip
+		}
+		return nil
+	}
+}
@@ -0,0 +1,180 @@
+package daemon
+
+import (
+	"cont/api"
+	"cont/cmd"
+	"cont/container"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"sync"
+	"time"
+)
+
+const attachTimeout = 5 * time.Second
+
+// execSession is a process started inside a running container. It has its own events and streams.
+type execSession struct {
+	Id       uuid.UUID
+	cancel   context.CancelFunc
+	attached chan struct{} // closed once a client requests the session streams
+	attach   sync.Once
+	clients  []uuid.UUID // streaming clients, disconnected once the session is done
+}
+
+func (s *server) Exec(ctx context.Context, request *api.ExecRequest) (*api.ContainerResponse, error) {
+	containerID, err := uuid.FromBytes(request.Id)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := s.getContainer(containerID)
+	if !ok {
+		return nil, fmt.Errorf("container %s is not currently running", containerID.String())
+	}
+
+	id := uuid.New()
+	idBytes, err := id.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	eventChan := s.createEventChan(id)
+
+	go s.runExec(c, request, id, eventChan)
+	return &api.ContainerResponse{Uuid: idBytes}, nil
+}
+
+func (s *server) runExec(c *Container, request *api.ExecRequest, id uuid.UUID, eventChan chan *api.Event) {
+	defer s.closeEventChan(eventChan, id)
+
+	binaryId, err := id.MarshalBinary()
+	if err != nil {
+		log.Printf("cannot marshal UUID to binary: %v", err)
+		s.sendFailedEvent(eventChan, id, err)
+		return
+	}
+
+	s.sendEvent(eventChan, &api.Event{
+		Id:      binaryId,
+		Type:    cmd.Created,
+		Message: "",
+		Source:  "",
+		Data:    nil,
+	})
+
+	sin, sout, serr := s.ContainerStreamIDs(id)
+	stdin, stdout, stderr := s.setupStd(sin, sout, serr)
+	defer s.closeStd(stdin, id, stdout, stderr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	session := &execSession{Id: id, cancel: cancel, attached: make(chan struct{})}
+	if err := s.updateContainer(c.Id, func(c *Container) error {
+		c.execs[id] = session
+		return nil
+	}); err != nil {
+		log.Printf("cannot add exec session %s: %v", id.String(), err)
+		s.sendFailedEvent(eventChan, id, err)
+		return
+	}
+	defer s.removeExec(c.Id, id)
+
+	// the client attaches once the session is started, the process is started afterwards so no output is lost
+	s.sendEvent(eventChan, &api.Event{
+		Id:      binaryId,
+		Type:    cmd.Started,
+		Message: "",
+		Source:  "",
+		Data:    nil,
+	})
+	select {
+	case <-session.attached:
+	case <-time.After(attachTimeout):
+		log.Printf("no client attached to exec session %s", id.String())
+	}
+
+	capabilities, err := container.ResolveCapabilities(c.Spec.CapAdd, c.Spec.CapDrop)
+	if err != nil {
+		log.Printf("cannot resolve capabilities: %v", err)
+		s.sendFailedEvent(eventChan, id, err)
+		return
+	}
+	profile, err := seccompProfile(c.Spec)
+	if err != nil {
+		log.Printf("cannot parse seccomp profile: %v", err)
+		s.sendFailedEvent(eventChan, id, err)
+		return
+	}
+	execCommand, err := container.Exec(ctx, &container.ExecConfig{
+		Stdin:           stdin,
+		Stdout:          stdout,
+		Stderr:          stderr,
+		PID:             c.Pid,
+		Workdir:         request.Workdir,
+		Cmd:             request.Cmd,
+		Args:            request.Args,
+		Env:             c.Spec.Env,
+		User:            c.Spec.User,
+		Capabilities:    capabilities,
+		NoNewPrivileges: !c.Spec.AllowNewPrivileges,
+		Seccomp:         profile,
+		Interactive:     request.Interactive,
+		Cgroup:          s.containerCgroup(c),
+	})
+	if err != nil {
+		log.Printf("exec start error: %v\n", err)
+		s.sendFailedEvent(eventChan, id, err)
+		return
+	}
+	log.Printf("exec session %s started in container %s\n", id.String(), c.Id.String())
+
+	if err = execCommand.Wait(); err != nil {
+		log.Printf("exec session %s wait error: %v\n", id.String(), err)
+	}
+	s.sendEvent(eventChan, &api.Event{
+		Id:      binaryId,
+		Type:    cmd.Done,
+		Message: "",
+		Source:  "",
+		Data:    nil,
+	})
+	log.Printf("exec session %s done\n", id.String())
+}
+
+func (s *server) removeExec(containerID, id uuid.UUID) {
+	_ = s.updateContainer(containerID, func(c *Container) error {
+		session, ok := c.execs[id]
+		if !ok {
+			return nil
+		}
+		for _, clientID := range session.clients {
+			streamer, ok := c.Streamers[clientID]
+			if !ok {
+				continue
+			}
+			if err := streamer.Close(); err != nil {
+				log.Printf("cannot close streamer %s: %v", clientID.String(), err)
+			}
+			delete(c.Streamers, clientID)
+		}
+		delete(c.execs, id)
+		return nil
+	})
+}
+
+// getStreamOwner returns the container owning streams with the ID, itself or one of its exec sessions.
+func (s *server) getStreamOwner(id uuid.UUID) (uuid.UUID, bool) {
+	s.currentlyRunningMutex.RLock()
+	defer s.currentlyRunningMutex.RUnlock()
+
+	if _, ok := s.currentlyRunning[id]; ok {
+		return id, true
+	}
+	for _, c := range s.currentlyRunning {
+		if _, ok := c.execs[id]; ok {
+			return c.Id, true
+		}
+	}
+	return id, false
+}
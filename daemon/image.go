@@ -0,0 +1,169 @@
+package daemon
+
+import (
+	"cont/api"
+	"cont/image"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func (s *server) ImageImport(importServer api.Api_ImageImportServer) error {
+	archive, err := s.images.TempFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+
+	var name string
+	first := true
+	for {
+		chunk, err := importServer.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if first {
+			name = chunk.Name
+			first = false
+		}
+		if _, err := archive.Write(chunk.Data); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return err
+	}
+
+	img, err := s.images.Import(archive.Name(), name)
+	if err != nil {
+		return err
+	}
+	return importServer.SendAndClose(imageToApi(img))
+}
+
+func (s *server) ImageLs(ctx context.Context, empty *api.Empty) (*api.Images, error) {
+	images, err := s.images.List()
+	if err != nil {
+		return nil, err
+	}
+	result := &api.Images{Images: make([]*api.Image, 0, len(images))}
+	for _, img := range images {
+		result.Images = append(result.Images, imageToApi(img))
+	}
+	return result, nil
+}
+
+func (s *server) ImageRm(ctx context.Context, request *api.ImageRemoveRequest) (*api.Empty, error) {
+	s.imagesMutex.Lock()
+	defer s.imagesMutex.Unlock()
+
+	img, err := s.images.Get(request.Ref)
+	if err != nil {
+		return nil, err
+	}
+	for id, imageID := range s.imageMounts { // exited containers keep their rootfs until removed
+		if imageID == img.ID {
+			return nil, fmt.Errorf("image %s is used by container %s", img.ShortID(), id.String())
+		}
+	}
+	return &api.Empty{}, s.images.Remove(img.ID)
+}
+
+// setupRootfs returns the root filesystem of a container and its workdir. Image root filesystems are unmounted when the
+// container is removed.
+func (s *server) setupRootfs(id uuid.UUID, request *api.ContainerRequest) (rootfs string, mount *image.Rootfs, workdir string, err error) {
+	rootfs, workdir = request.Rootfs, request.Workdir
+	if request.Image != "" {
+		s.imagesMutex.Lock()
+		defer s.imagesMutex.Unlock()
+
+		img, err := s.images.Get(request.Image)
+		if err != nil {
+			return "", nil, "", err
+		}
+		mount, err = s.images.Mount(img, filepath.Join(s.containerDir(id), "rootfs"))
+		if err != nil {
+			return "", nil, "", fmt.Errorf("cannot mount image %s: %w", img.ShortID(), err)
+		}
+		rootfs = mount.Path
+		s.imageMounts[id] = img.ID
+	}
+	if workdir == "" {
+		workdir = "/"
+	}
+	return rootfs, mount, workdir, nil
+}
+
+// applyImageConfig completes a container request with the entrypoint, command, user, workdir and environment of its
+// image.
+func (s *server) applyImageConfig(request *api.ContainerRequest) error {
+	if request.Image == "" {
+		if request.Cmd == "" {
+			return errors.New("a container without an image needs a command")
+		}
+		return nil
+	}
+	img, err := s.images.Get(request.Image)
+	if err != nil {
+		return err
+	}
+	config := img.Config
+
+	command := config.Cmd
+	if request.Cmd != "" {
+		command = append([]string{request.Cmd}, request.Args...)
+	}
+	argv := append(append([]string(nil), config.Entrypoint...), command...)
+	if len(argv) == 0 {
+		return fmt.Errorf("image %s doesn't have a command, the container needs one", img.ShortID())
+	}
+	request.Cmd, request.Args = argv[0], argv[1:]
+	if request.User == "" {
+		request.User = config.User
+	}
+	if request.Workdir == "" {
+		request.Workdir = config.WorkingDir
+	}
+	request.Env = mergeEnv(config.Env, request.Env)
+	return nil
+}
+
+// mergeEnv returns the image environment variables overridden by env.
+func mergeEnv(imageEnv, env []string) []string {
+	result := make([]string, 0, len(imageEnv)+len(env))
+	for _, variable := range imageEnv {
+		i := strings.IndexByte(variable, '=')
+		if i <= 0 {
+			continue
+		}
+		overridden := false
+		for _, v := range env {
+			if strings.HasPrefix(v, variable[:i+1]) {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			result = append(result, variable)
+		}
+	}
+	return append(result, env...)
+}
+
+func imageToApi(img *image.Image) *api.Image {
+	return &api.Image{
+		Id:      img.ID,
+		Names:   img.Names,
+		Size:    img.Size,
+		Created: img.Created.Unix(),
+	}
+}
@@ -0,0 +1,22 @@
+package daemon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeEnv(t *testing.T) {
+	imageEnv := []string{"PATH=/usr/local/bin:/usr/bin", "LANG=C.UTF-8", "HOME=/root", "invalid", "=empty"}
+	env := []string{"HOME=/home/user", "DEBUG=1", "LANGUAGE=en"}
+
+	expected := []string{"PATH=/usr/local/bin:/usr/bin", "LANG=C.UTF-8", "HOME=/home/user", "DEBUG=1", "LANGUAGE=en"}
+	if merged := mergeEnv(imageEnv, env); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("mergeEnv = %v, expected %v", merged, expected)
+	}
+	if merged := mergeEnv(nil, env); !reflect.DeepEqual(merged, env) {
+		t.Errorf("mergeEnv without an image environment = %v, expected %v", merged, env)
+	}
+	if merged := mergeEnv(imageEnv[:2], nil); !reflect.DeepEqual(merged, imageEnv[:2]) {
+		t.Errorf("mergeEnv without container variables = %v, expected %v", merged, imageEnv[:2])
+	}
+}
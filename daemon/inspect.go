@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"cont/api"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+)
+
+func (s *server) Inspect(ctx context.Context, request *api.InspectRequest) (*api.ContainerInspect, error) {
+	id, err := uuid.ParseBytes(request.Id)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := s.findContainer(id)
+	if !ok {
+		return nil, errors.New("container doesn't exist")
+	}
+
+	s.currentlyRunningMutex.RLock()
+	state := c.state()
+	s.currentlyRunningMutex.RUnlock()
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+	return &api.ContainerInspect{State: string(data)}, nil
+}
@@ -5,7 +5,12 @@ import (
 	"cont/cmd"
 	"context"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
+	"golang.org/x/sys/unix"
+	"strconv"
+	"strings"
+	"syscall"
 )
 
 func (s *server) Kill(ctx context.Context, killCommand *api.KillCommand) (*api.ContainerResponse, error) {
@@ -18,6 +23,19 @@ func (s *server) Kill(ctx context.Context, killCommand *api.KillCommand) (*api.C
 		return nil, errors.New("container doesn't exist")
 	}
 
+	if killCommand.Signal != "" {
+		signal, err := parseSignal(killCommand.Signal)
+		if err != nil {
+			return nil, err
+		}
+		if signal != syscall.SIGKILL { // the container handles the signal on its own
+			if err := syscall.Kill(c.Pid, signal); err != nil {
+				return nil, fmt.Errorf("cannot send %s to container %s: %w", unix.SignalName(signal), id.String(), err)
+			}
+			return &api.ContainerResponse{Uuid: killCommand.Id}, nil
+		}
+	}
+
 	eventChan, ok := s.getEventChan(id)
 	if !ok {
 		return nil, errors.New("cannot find container events")
@@ -29,6 +47,7 @@ func (s *server) Kill(ctx context.Context, killCommand *api.KillCommand) (*api.C
 }
 
 func (s *server) killContainer(c *Container, eventChan chan *api.Event, containerID []byte) {
+	s.markStopped(c)
 	// close all container streams
 	_ = c.Stdin.Close()
 	_ = c.Stdout.Close()
@@ -45,3 +64,22 @@ func (s *server) killContainer(c *Container, eventChan chan *api.Event, containe
 		Data:    nil,
 	})
 }
+
+// parseSignal parses signal names (SIGTERM or TERM) and numbers.
+func parseSignal(name string) (syscall.Signal, error) {
+	if number, err := strconv.Atoi(name); err == nil {
+		if number <= 0 || number > 64 {
+			return 0, fmt.Errorf("invalid signal %d", number)
+		}
+		return syscall.Signal(number), nil
+	}
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	signal := unix.SignalNum(name)
+	if signal == 0 {
+		return 0, fmt.Errorf("invalid signal %s", name)
+	}
+	return signal, nil
+}
@@ -0,0 +1,26 @@
+package daemon
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	for name, expected := range map[string]syscall.Signal{
+		"SIGTERM": syscall.SIGTERM,
+		"TERM":    syscall.SIGTERM,
+		"sigint":  syscall.SIGINT,
+		"hup":     syscall.SIGHUP,
+		"9":       syscall.SIGKILL,
+		"64":      syscall.Signal(64),
+	} {
+		if signal, err := parseSignal(name); err != nil || signal != expected {
+			t.Errorf("parseSignal(%s) = %v, %v, expected %v", name, signal, err, expected)
+		}
+	}
+	for _, name := range []string{"", "SIG", "SIGFOO", "0", "-9", "65", "KILL9"} {
+		if signal, err := parseSignal(name); err == nil {
+			t.Errorf("parseSignal(%q) = %v, expected an error", name, signal)
+		}
+	}
+}
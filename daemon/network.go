@@ -0,0 +1,198 @@
+package daemon
+
+import (
+	"cont/api"
+	"cont/container"
+	"cont/network"
+	"cont/slirp"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+)
+
+func (s *server) NetworkCreate(ctx context.Context, request *api.NetworkRequest) (*api.Network, error) {
+	n, err := s.networks.Create(request.Name, request.Subnet)
+	if err != nil {
+		return nil, err
+	}
+	return networkToApi(n), nil
+}
+
+func (s *server) NetworkLs(ctx context.Context, empty *api.Empty) (*api.Networks, error) {
+	networks, err := s.networks.List()
+	if err != nil {
+		return nil, err
+	}
+	result := &api.Networks{Networks: make([]*api.Network, 0, len(networks))}
+	for _, n := range networks {
+		result.Networks = append(result.Networks, networkToApi(n))
+	}
+	return result, nil
+}
+
+func (s *server) NetworkRm(ctx context.Context, request *api.NetworkRequest) (*api.Empty, error) {
+	return &api.Empty{}, s.networks.Remove(request.Name)
+}
+
+// setupNetworking connects a created container to its network, sets up its DNS and publishes its ports.
+func (s *server) setupNetworking(c *Container, request *api.ContainerRequest) error {
+	if err := s.connectNetwork(c, request); err != nil {
+		return err
+	}
+	if err := s.writeDNSFiles(c); err != nil {
+		return fmt.Errorf("cannot write DNS files: %w", err)
+	}
+	if err := s.startDNS(c); err != nil {
+		return err
+	}
+	return s.publishPorts(c, portsFromApi(request.Ports))
+}
+
+// releaseNetworking stops everything set up by setupNetworking.
+func (s *server) releaseNetworking(c *Container) {
+	s.stopDNS(c)
+	s.unpublishPorts(c)
+	s.stopNetHelper(c)
+	s.disconnectNetwork(c)
+}
+
+// connectNetwork connects a created container to its network, if it has one.
+func (s *server) connectNetwork(c *Container, request *api.ContainerRequest) error {
+	switch request.Network {
+	case "", network.None:
+		return nil
+	}
+	options, ok, err := slirpOptions(request.Network)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return s.connectSlirp(c, options)
+	}
+	endpoint, err := s.networks.Connect(request.Network, c.Id.String(), c.Pid)
+	if err != nil {
+		return err
+	}
+	c.endpoint = endpoint
+	return nil
+}
+
+// connectSlirp gives a container a TAP device served by a userspace network stack, which works without root.
+func (s *server) connectSlirp(c *Container, options slirp.Options) error {
+	address := &net.IPNet{IP: slirp.GuestIP, Mask: slirp.Subnet.Mask}
+	tap, err := container.CreateTap(c.Pid, container.TapConfig{
+		Name:    network.ContainerInterface,
+		MAC:     slirp.GuestMAC,
+		Address: address,
+		Gateway: slirp.GatewayIP,
+	})
+	if err != nil {
+		return err
+	}
+	stack := slirp.New(tap, options)
+	go func() {
+		if err := stack.Run(); err != nil {
+			log.Printf("network stack of container %s failed: %v", c.Id.String(), err)
+		}
+	}()
+	c.slirp = stack
+	c.endpoint = &network.Endpoint{Network: network.Slirp, IP: address.String(), Gateway: slirp.GatewayIP.String()}
+	return nil
+}
+
+// reconnectSlirp gives an adopted slirp container a new network stack, the previous one went away with its daemon.
+func (s *server) reconnectSlirp(c *Container) error {
+	if c.endpoint == nil || c.endpoint.Network != network.Slirp {
+		return nil
+	}
+	options, _, err := slirpOptions(c.Spec.Network)
+	if err != nil {
+		return err
+	}
+	return s.connectSlirp(c, options)
+}
+
+// slirpOptions parses slirp[:option,...] networks, it returns false for other networks.
+func slirpOptions(name string) (slirp.Options, bool, error) {
+	var options slirp.Options
+	if name == network.Slirp {
+		return options, true, nil
+	}
+	if !strings.HasPrefix(name, network.Slirp+":") {
+		return options, false, nil
+	}
+	for _, option := range strings.Split(strings.TrimPrefix(name, network.Slirp+":"), ",") {
+		key, value := option, "true"
+		if i := strings.IndexByte(option, '='); i >= 0 {
+			key, value = option[:i], option[i+1:]
+		}
+		switch key {
+		case "allow_host_loopback":
+			allow, err := strconv.ParseBool(value)
+			if err != nil {
+				return options, true, fmt.Errorf("invalid slirp option %s, expected a boolean", option)
+			}
+			options.AllowHostLoopback = allow
+		default:
+			return options, true, fmt.Errorf("unknown slirp option %s", option)
+		}
+	}
+	return options, true, nil
+}
+
+func (s *server) disconnectNetwork(c *Container) {
+	if c.slirp != nil {
+		if err := c.slirp.Close(); err != nil {
+			log.Printf("cannot close network stack of container %s: %v", c.Id.String(), err)
+		}
+		c.slirp = nil
+	}
+	if c.endpoint == nil {
+		return
+	}
+	if c.endpoint.Network == network.Slirp {
+		c.endpoint = nil
+		return
+	}
+	if err := s.networks.Disconnect(c.endpoint.Network, c.Id.String()); err != nil {
+		log.Printf("cannot disconnect container %s from network %s: %v", c.Id.String(), c.endpoint.Network, err)
+	}
+	c.endpoint = nil
+}
+
+// startNetHelper returns the socket helper in the container network namespace, shared by published ports and DNS.
+func (s *server) startNetHelper(c *Container) (*container.NetHelper, error) {
+	if c.netHelper != nil {
+		return c.netHelper, nil
+	}
+	helper, err := container.StartNetHelper(c.Pid)
+	if err != nil {
+		return nil, err
+	}
+	c.netHelper = helper
+	return helper, nil
+}
+
+func (s *server) stopNetHelper(c *Container) {
+	if c.netHelper == nil {
+		return
+	}
+	if err := c.netHelper.Close(); err != nil {
+		log.Printf("cannot stop network helper of container %s: %v", c.Id.String(), err)
+	}
+	c.netHelper = nil
+}
+
+func networkToApi(n *network.Network) *api.Network {
+	return &api.Network{
+		Name:       n.Name,
+		Bridge:     n.Bridge,
+		Subnet:     n.Subnet,
+		Gateway:    n.Gateway,
+		Created:    n.Created.Unix(),
+		Containers: n.Endpoints,
+	}
+}
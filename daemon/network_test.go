@@ -0,0 +1,35 @@
+package daemon
+
+import (
+	"cont/network"
+	"testing"
+)
+
+func TestSlirpOptions(t *testing.T) {
+	tests := []struct {
+		name              string
+		isSlirp           bool
+		allowHostLoopback bool
+	}{
+		{network.Slirp, true, false},
+		{network.Slirp + ":allow_host_loopback", true, true},
+		{network.Slirp + ":allow_host_loopback=false", true, false},
+		{network.Slirp + ":allow_host_loopback=true,allow_host_loopback=0", true, false},
+		{"cont", false, false},
+		{network.Slirp + "net", false, false}, // a bridge network named like it
+	}
+	for _, test := range tests {
+		options, isSlirp, err := slirpOptions(test.name)
+		if err != nil || isSlirp != test.isSlirp || options.AllowHostLoopback != test.allowHostLoopback {
+			t.Errorf("slirpOptions(%s) = %+v, %v, %v, expected slirp %v with host loopback %v", test.name, options,
+				isSlirp, err, test.isSlirp, test.allowHostLoopback)
+		}
+	}
+
+	for _, name := range []string{network.Slirp + ":", network.Slirp + ":mtu=1400",
+		network.Slirp + ":allow_host_loopback=maybe"} {
+		if _, isSlirp, err := slirpOptions(name); err == nil || !isSlirp {
+			t.Errorf("slirpOptions(%s) = %v, %v, expected an invalid slirp network", name, isSlirp, err)
+		}
+	}
+}
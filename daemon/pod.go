@@ -0,0 +1,300 @@
+package daemon
+
+import (
+	"cont/api"
+	"cont/network"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/sys/unix"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+)
+
+const (
+	infraSuffix       = "-infra" // the infra container is named after its pod
+	pauseCommand      = "pause"  // command of the daemon binary run by infra containers
+	infraStartTimeout = 30 * time.Second
+)
+
+var podNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// defaultPodNamespaces are the infra container namespaces pod containers join by default.
+var defaultPodNamespaces = []string{"net", "ipc", "uts"}
+
+func (s *server) PodCreate(ctx context.Context, request *api.PodRequest) (*api.Pod, error) {
+	if !podNameRegexp.MatchString(request.Name) {
+		return nil, fmt.Errorf("invalid pod name %q", request.Name)
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	hostname := request.Hostname
+	if hostname == "" {
+		hostname = request.Name
+	}
+	// the infra container runs the daemon binary from the host root filesystem, which is all it needs
+	containerRequest := &api.ContainerRequest{
+		Name:       request.Name + infraSuffix,
+		Hostname:   hostname,
+		Workdir:    "/",
+		Cmd:        executable,
+		Args:       []string{pauseCommand},
+		ReadOnly:   true,
+		Network:    request.Network,
+		Ports:      request.Ports,
+		Dns:        request.Dns,
+		DnsSearch:  request.DnsSearch,
+		ExtraHosts: request.ExtraHosts,
+		Opts:       &api.ContainerOpts{ShareOpts: &api.ShareNSOpts{}},
+		Restart:    restartUnlessStopped, // pod containers can't start again without it
+	}
+	if err := s.validateRequest(containerRequest); err != nil {
+		return nil, err
+	}
+
+	if err := s.reservePod(request.Name); err != nil {
+		return nil, err
+	}
+	id := uuid.New()
+	started := make(chan error, 1)
+	go s.runContainer(containerRequest, id, runOptions{infraPod: request.Name, started: started})
+	select {
+	case err := <-started:
+		s.releasePod(request.Name) // a started infra container keeps the name taken
+		if err != nil {
+			return nil, fmt.Errorf("cannot start pod infra container: %w", err)
+		}
+	case <-time.After(infraStartTimeout):
+		go func() {
+			<-started
+			s.releasePod(request.Name)
+		}()
+		return nil, errors.New("pod infra container didn't start in time")
+	}
+	infra, ok := s.findContainer(id)
+	if !ok {
+		return nil, errors.New("pod infra container exited")
+	}
+	return s.podToApi(infra), nil
+}
+
+func (s *server) PodLs(ctx context.Context, empty *api.Empty) (*api.Pods, error) {
+	infras := make([]*Container, 0)
+	for _, c := range s.getAllContainers() {
+		if c.infra {
+			infras = append(infras, c)
+		}
+	}
+	sort.Slice(infras, func(i, j int) bool { return infras[i].Pod < infras[j].Pod })
+
+	result := &api.Pods{Pods: make([]*api.Pod, 0, len(infras))}
+	for _, infra := range infras {
+		result.Pods = append(result.Pods, s.podToApi(infra))
+	}
+	return result, nil
+}
+
+func (s *server) PodStop(ctx context.Context, request *api.PodStopRequest) (*api.Empty, error) {
+	infra, ok := s.findPodInfra(request.Name)
+	if !ok {
+		return nil, fmt.Errorf("pod %s doesn't exist", request.Name)
+	}
+	timeout := time.Duration(request.Timeout) * time.Second
+	for _, c := range s.podContainers(request.Name) {
+		if _, running := s.getContainer(c.Id); running {
+			if err := s.stopContainer(ctx, c, timeout); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if _, running := s.getContainer(infra.Id); running {
+		if err := s.stopContainer(ctx, infra, timeout); err != nil {
+			return nil, err
+		}
+	}
+	return &api.Empty{}, nil
+}
+
+func (s *server) PodRm(ctx context.Context, request *api.PodRemoveRequest) (*api.Empty, error) {
+	infra, ok := s.findPodInfra(request.Name)
+	if !ok {
+		return nil, fmt.Errorf("pod %s doesn't exist", request.Name)
+	}
+	containers := append(s.podContainers(request.Name), infra) // the infra container goes last
+	for _, c := range containers {
+		if _, running := s.getContainer(c.Id); running && !request.Force {
+			return nil, fmt.Errorf("pod %s is running, stop it first or force the removal", request.Name)
+		}
+	}
+	for _, c := range containers {
+		done := s.doneChan(c)
+		if _, running := s.getContainer(c.Id); running {
+			eventChan, ok := s.getEventChan(c.Id)
+			if !ok {
+				return nil, errors.New("cannot find container events")
+			}
+			s.killContainer(c, eventChan, []byte(c.Id.String()))
+		}
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if err := s.removeContainer(c.Id); err != nil {
+			return nil, err
+		}
+	}
+	return &api.Empty{}, nil
+}
+
+// validatePod checks that the pod of a request is running and only its namespaces are shared.
+func (s *server) validatePod(request *api.ContainerRequest) error {
+	if request.Pod == "" {
+		if len(request.PodNamespaces) > 0 {
+			return errors.New("pod namespaces are set without a pod")
+		}
+		return nil
+	}
+	if request.GetOpts().GetShareOpts().GetFlags() != 0 {
+		return errors.New("pod containers can't share namespaces with other containers")
+	}
+	if len(request.UidMaps) > 0 || len(request.GidMaps) > 0 {
+		return errors.New("pod containers use the ID maps of the pod")
+	}
+	flags, err := podNSFlags(request.PodNamespaces)
+	if err != nil {
+		return err
+	}
+	infra, ok := s.podInfra(request.Pod)
+	if !ok {
+		return fmt.Errorf("pod %s doesn't exist or isn't running", request.Pod)
+	}
+	if flags&unix.CLONE_NEWUTS != 0 {
+		request.Hostname = infra.Spec.Hostname // the container gets the pod hostname
+	}
+	if flags&unix.CLONE_NEWNET != 0 { // the pod DNS options apply to the container /etc files
+		request.ExtraHosts = append(append([]string(nil), infra.Spec.ExtraHosts...), request.ExtraHosts...)
+		if len(request.DnsSearch) == 0 {
+			request.DnsSearch = infra.Spec.DnsSearch
+		}
+	}
+	return nil
+}
+
+// podNSFlags returns the CLONE_NEW* flags of the pod namespaces a container joins, the user namespace included.
+func podNSFlags(namespaces []string) (int64, error) {
+	if len(namespaces) == 0 {
+		namespaces = defaultPodNamespaces
+	}
+	flags := int64(unix.CLONE_NEWUSER)
+	for _, ns := range namespaces {
+		switch ns {
+		case "net":
+			flags |= unix.CLONE_NEWNET
+		case "ipc":
+			flags |= unix.CLONE_NEWIPC
+		case "uts":
+			flags |= unix.CLONE_NEWUTS
+		case "pid":
+			flags |= unix.CLONE_NEWPID
+		default:
+			return 0, fmt.Errorf("invalid pod namespace %s, expected net, ipc, uts or pid", ns)
+		}
+	}
+	return flags, nil
+}
+
+// podInfra returns the running infra container of a pod.
+func (s *server) podInfra(name string) (*Container, bool) {
+	for _, c := range s.getCurrentlyRunning() {
+		if c.infra && c.Pod == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// reservePod reserves a pod name while its infra container is started.
+func (s *server) reservePod(name string) error {
+	s.podsMutex.Lock()
+	defer s.podsMutex.Unlock()
+
+	if _, ok := s.findPodInfra(name); ok || s.creatingPods[name] {
+		return fmt.Errorf("pod %s already exists", name)
+	}
+	s.creatingPods[name] = true
+	return nil
+}
+
+// releasePod releases a pod name reserved by reservePod.
+func (s *server) releasePod(name string) {
+	s.podsMutex.Lock()
+	defer s.podsMutex.Unlock()
+
+	delete(s.creatingPods, name)
+}
+
+// findPodInfra returns the running or exited infra container of a pod.
+func (s *server) findPodInfra(name string) (*Container, bool) {
+	for _, c := range s.getAllContainers() {
+		if c.infra && c.Pod == name {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// podContainers returns the running and exited containers of a pod, without the infra container.
+func (s *server) podContainers(name string) []*Container {
+	containers := make([]*Container, 0)
+	for _, c := range s.getAllContainers() {
+		if !c.infra && c.Pod == name {
+			containers = append(containers, c)
+		}
+	}
+	return containers
+}
+
+// networkEndpoint returns the network endpoint of a container, the one of the infra container for pod containers.
+func networkEndpoint(c *Container) *network.Endpoint {
+	if c.podInfra != nil && sharesNS(c.Spec, unix.CLONE_NEWNET) {
+		return c.podInfra.endpoint
+	}
+	return c.endpoint
+}
+
+// linkPods links adopted pod containers to their infra containers.
+func (s *server) linkPods() {
+	for _, c := range s.getCurrentlyRunning() {
+		if c.infra || c.Pod == "" {
+			continue
+		}
+		if infra, ok := s.podInfra(c.Pod); ok {
+			c.podInfra = infra
+		}
+	}
+}
+
+func (s *server) podToApi(infra *Container) *api.Pod {
+	containers := s.podContainers(infra.Pod)
+
+	s.currentlyRunningMutex.RLock()
+	defer s.currentlyRunningMutex.RUnlock()
+	pod := &api.Pod{
+		Name:       infra.Pod,
+		InfraId:    infra.Id.String(),
+		Status:     infra.Status,
+		Network:    infra.Spec.Network,
+		Created:    infra.Created.Unix(),
+		Containers: make([]string, 0, len(containers)),
+	}
+	for _, c := range containers {
+		pod.Containers = append(pod.Containers, c.Id.String())
+	}
+	return pod
+}
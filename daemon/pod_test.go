@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"golang.org/x/sys/unix"
+	"testing"
+)
+
+func TestPodNSFlags(t *testing.T) {
+	tests := []struct {
+		namespaces []string
+		flags      int64
+	}{
+		{nil, unix.CLONE_NEWUSER | unix.CLONE_NEWNET | unix.CLONE_NEWIPC | unix.CLONE_NEWUTS},
+		{[]string{"net"}, unix.CLONE_NEWUSER | unix.CLONE_NEWNET},
+		{[]string{"pid", "uts"}, unix.CLONE_NEWUSER | unix.CLONE_NEWPID | unix.CLONE_NEWUTS},
+		{[]string{"ipc", "ipc"}, unix.CLONE_NEWUSER | unix.CLONE_NEWIPC},
+	}
+	for _, test := range tests {
+		// pod containers always join the infra user namespace, the shared namespaces belong to it
+		if flags, err := podNSFlags(test.namespaces); err != nil || flags != test.flags {
+			t.Errorf("podNSFlags(%v) = %#x, %v, expected %#x", test.namespaces, flags, err, test.flags)
+		}
+	}
+
+	for _, namespaces := range [][]string{{"mnt"}, {"net", "user"}, {""}, {"cgroup"}} {
+		if flags, err := podNSFlags(namespaces); err == nil {
+			t.Errorf("podNSFlags(%v) = %#x, expected an error", namespaces, flags)
+		}
+	}
+}
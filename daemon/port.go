@@ -0,0 +1,98 @@
+package daemon
+
+import (
+	"cont/api"
+	"cont/network"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+func (s *server) Port(ctx context.Context, request *api.PortRequest) (*api.PortMappings, error) {
+	id, err := uuid.ParseBytes(request.Id)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := s.findContainer(id)
+	if !ok {
+		return nil, errors.New("container doesn't exist")
+	}
+
+	s.currentlyRunningMutex.RLock()
+	defer s.currentlyRunningMutex.RUnlock()
+	return &api.PortMappings{Ports: portsToApi(c.ports)}, nil
+}
+
+// validatePorts checks the published ports of a container request, host port 0 picks a random port.
+func validatePorts(ports []*api.PortMapping) error {
+	used := make(map[string]bool)
+	for _, p := range ports {
+		if p.ContainerPort < 1 || p.ContainerPort > 65535 || p.HostPort < 0 || p.HostPort > 65535 {
+			return fmt.Errorf("invalid port mapping %d:%d", p.HostPort, p.ContainerPort)
+		}
+		if p.Protocol != "tcp" && p.Protocol != "udp" {
+			return fmt.Errorf("invalid protocol %s", p.Protocol)
+		}
+		if p.HostPort == 0 {
+			continue
+		}
+		key := fmt.Sprintf("%d/%s", p.HostPort, p.Protocol)
+		if used[key] {
+			return fmt.Errorf("host port %s is published twice", key)
+		}
+		used[key] = true
+	}
+	return nil
+}
+
+// publishPorts proxies host ports to a created container.
+func (s *server) publishPorts(c *Container, ports []network.PortMapping) error {
+	if len(ports) == 0 {
+		return nil
+	}
+	helper, err := s.startNetHelper(c)
+	if err != nil {
+		return err
+	}
+	proxy, err := network.NewProxy(ports, helper.Dial)
+	if err != nil {
+		return err
+	}
+	c.proxy, c.ports = proxy, proxy.Mappings
+	return nil
+}
+
+func (s *server) unpublishPorts(c *Container) {
+	if c.proxy != nil {
+		c.proxy.Close()
+		c.proxy = nil
+	}
+	c.ports = nil
+}
+
+func portsFromApi(ports []*api.PortMapping) []network.PortMapping {
+	result := make([]network.PortMapping, 0, len(ports))
+	for _, p := range ports {
+		result = append(result, network.PortMapping{
+			HostIP:        p.HostIp,
+			HostPort:      int(p.HostPort),
+			ContainerPort: int(p.ContainerPort),
+			Protocol:      p.Protocol,
+		})
+	}
+	return result
+}
+
+func portsToApi(ports []network.PortMapping) []*api.PortMapping {
+	result := make([]*api.PortMapping, 0, len(ports))
+	for _, p := range ports {
+		result = append(result, &api.PortMapping{
+			HostIp:        p.HostIP,
+			HostPort:      int32(p.HostPort),
+			ContainerPort: int32(p.ContainerPort),
+			Protocol:      p.Protocol,
+		})
+	}
+	return result
+}
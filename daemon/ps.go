@@ -5,20 +5,27 @@ import (
 	"context"
 )
 
-func (s *server) Ps(ctx context.Context, empty *api.Empty) (*api.ActiveProcesses, error) {
-	processes := s.listProcesses()
+func (s *server) Ps(ctx context.Context, request *api.PsRequest) (*api.ActiveProcesses, error) {
+	processes := s.listProcesses(request.All)
 	result := &api.ActiveProcesses{Processes: processes}
 	return result, nil
 }
 
-func (s *server) listProcesses() []*api.Process {
-	processes := make([]*api.Process, 0, len(s.currentlyRunning))
-	for _, c := range s.getCurrentlyRunning() {
+func (s *server) listProcesses(all bool) []*api.Process {
+	containers := s.getCurrentlyRunning()
+	if all {
+		containers = s.getAllContainers()
+	}
+	processes := make([]*api.Process, 0, len(containers))
+	for _, c := range containers {
 		processes = append(processes, &api.Process{
-			Id:   c.Id.String(),
-			Name: c.Name,
-			Cmd:  c.Command,
-			Pid:  int64(c.Cmd.Process.Pid),
+			Id:       c.Id.String(),
+			Name:     c.Name,
+			Cmd:      c.Command,
+			Pid:      int64(c.Pid),
+			Status:   c.Status,
+			ExitCode: int32(c.ExitCode),
+			Restarts: int32(c.RestartCount),
 		})
 	}
 	return processes
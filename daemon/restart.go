@@ -0,0 +1,245 @@
+package daemon
+
+import (
+	"cont/api"
+	"cont/cmd"
+	"cont/container"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	restartNo            = "no"
+	restartOnFailure     = "on-failure"
+	restartAlways        = "always"
+	restartUnlessStopped = "unless-stopped"
+)
+
+const (
+	restartInitialDelay = 100 * time.Millisecond
+	restartMaxDelay     = time.Minute
+	restartResetAfter   = 10 * time.Second // containers running longer are restarted with the initial delay again
+)
+
+// errRestartCanceled is returned when a container was stopped or removed while it waited to be restarted.
+var errRestartCanceled = errors.New("restart canceled")
+
+// restartPolicy tells when the daemon restarts an exited container.
+type restartPolicy struct {
+	name       string
+	maxRetries int // on-failure restarts, unlimited if 0
+}
+
+// parseRestartPolicy parses no, on-failure[:max_retries], always and unless-stopped, an empty policy is no.
+func parseRestartPolicy(policy string) (restartPolicy, error) {
+	name, retries := policy, ""
+	if i := strings.IndexByte(policy, ':'); i >= 0 {
+		name, retries = policy[:i], policy[i+1:]
+	}
+	switch name {
+	case "", restartNo:
+		name = restartNo
+	case restartOnFailure, restartAlways, restartUnlessStopped:
+	default:
+		return restartPolicy{}, fmt.Errorf("invalid restart policy %s, expected no, on-failure[:max_retries], always or unless-stopped", policy)
+	}
+	result := restartPolicy{name: name}
+	if retries == "" {
+		if strings.HasSuffix(policy, ":") {
+			return restartPolicy{}, fmt.Errorf("invalid restart policy %s, the maximum retry count is missing", policy)
+		}
+		return result, nil
+	}
+	if name != restartOnFailure {
+		return restartPolicy{}, fmt.Errorf("invalid restart policy %s, only on-failure takes a maximum retry count", policy)
+	}
+	maxRetries, err := strconv.Atoi(retries)
+	if err != nil || maxRetries < 0 {
+		return restartPolicy{}, fmt.Errorf("invalid maximum retry count %s", retries)
+	}
+	result.maxRetries = maxRetries
+	return result, nil
+}
+
+// shouldRestart reports whether the restart policy of an exited container asks for a restart. Containers stopped on
+// purpose are only restarted by always when the daemon starts.
+func shouldRestart(c *Container, daemonStart bool) bool {
+	policy, err := parseRestartPolicy(c.Spec.Restart)
+	if err != nil {
+		return false
+	}
+	switch policy.name {
+	case restartAlways:
+		return !c.stopped || daemonStart
+	case restartUnlessStopped:
+		return !c.stopped
+	case restartOnFailure:
+		return !c.stopped && c.ExitCode != 0 && (policy.maxRetries == 0 || c.RestartCount < policy.maxRetries)
+	}
+	return false
+}
+
+// keepRestarting restarts an exited container for as long as its restart policy asks for it.
+func (s *server) keepRestarting(c *Container, eventChan chan *api.Event) {
+	for {
+		delay, ok := s.scheduleRestart(c)
+		if !ok {
+			return
+		}
+		time.Sleep(delay)
+		process, err := s.restartContainer(c, eventChan, false)
+		if err != nil {
+			if err != errRestartCanceled {
+				log.Printf("cannot restart container %s: %v", c.Id.String(), err)
+			}
+			return
+		}
+		s.waitContainer(c, eventChan, process.Cmd.Wait)
+	}
+}
+
+// scheduleRestart marks an exited container as restarting and returns the delay before the restart, false if there's
+// none.
+func (s *server) scheduleRestart(c *Container) (time.Duration, bool) {
+	s.currentlyRunningMutex.Lock()
+	if _, ok := s.exited[c.Id]; !ok || !shouldRestart(c, false) {
+		s.currentlyRunningMutex.Unlock()
+		return 0, false
+	}
+	c.restartDelay = restartDelay(c.restartDelay, c.Finished.Sub(c.Started))
+	c.Status = statusRestarting
+	delay, state := c.restartDelay, c.state()
+	s.currentlyRunningMutex.Unlock()
+
+	s.saveState(state)
+	log.Printf("restarting container %s in %s", c.Id.String(), delay)
+	return delay, true
+}
+
+// restartDelay doubles the previous restart delay up to the maximum, unless the container ran long enough to reset it.
+func restartDelay(previous, ran time.Duration) time.Duration {
+	if previous == 0 || ran >= restartResetAfter {
+		return restartInitialDelay
+	}
+	if previous *= 2; previous > restartMaxDelay {
+		return restartMaxDelay
+	}
+	return previous
+}
+
+// restartContainer starts an exited container again, it's put back if the start fails.
+func (s *server) restartContainer(c *Container, eventChan chan *api.Event, daemonStart bool) (*container.Process, error) {
+	s.currentlyRunningMutex.Lock()
+	if _, ok := s.exited[c.Id]; !ok || (!daemonStart && c.Status != statusRestarting) {
+		s.currentlyRunningMutex.Unlock()
+		return nil, errRestartCanceled
+	}
+	delete(s.exited, c.Id)
+	c.stopped = false
+	c.RestartCount++
+	c.done = make(chan struct{})
+	s.currentlyRunningMutex.Unlock()
+
+	process, err := s.startExited(c)
+	if err != nil {
+		s.currentlyRunningMutex.Lock()
+		c.Status = statusExited
+		state := c.state()
+		s.exited[c.Id] = c
+		close(c.done)
+		s.currentlyRunningMutex.Unlock()
+		s.saveState(state)
+		return nil, err
+	}
+	s.addContainer(c)
+
+	binaryId, err := c.Id.MarshalBinary()
+	if err != nil {
+		log.Printf("cannot marshal UUID to binary: %v", err)
+	}
+	s.sendEvent(eventChan, &api.Event{
+		Id:      binaryId,
+		Type:    cmd.Restarted,
+		Message: strconv.Itoa(c.RestartCount),
+		Source:  "",
+		Data:    nil,
+	})
+	if err := process.Start(); err != nil {
+		log.Printf("cannot start container %s command: %v", c.Id.String(), err)
+	}
+	log.Printf("container %s restarted (restart %d)\n", c.Id.String(), c.RestartCount)
+	return process, nil
+}
+
+// startExited starts an exited container with its original mounts and root filesystem.
+func (s *server) startExited(c *Container) (*container.Process, error) {
+	mounts, _, err := s.setupMounts(c.Id, c.Spec) // volumes are acquired once per container
+	if err != nil {
+		return nil, fmt.Errorf("cannot setup mounts: %w", err)
+	}
+	mounts = append(mounts, s.dnsMounts(c, mounts)...)
+
+	rootfs, workdir := c.Spec.Rootfs, c.Spec.Workdir
+	if c.rootfs != nil {
+		rootfs = c.rootfs.Path
+	}
+	if workdir == "" {
+		workdir = "/"
+	}
+	return s.startContainer(c, mounts, rootfs, workdir)
+}
+
+// restartContainers restarts exited containers whose policy asks for it when the daemon starts, pod infra containers
+// first.
+func (s *server) restartContainers() {
+	containers := make([]*Container, 0)
+	s.currentlyRunningMutex.RLock()
+	for _, c := range s.exited {
+		if shouldRestart(c, true) {
+			containers = append(containers, c)
+		}
+	}
+	s.currentlyRunningMutex.RUnlock()
+	sort.SliceStable(containers, func(i, j int) bool { return containers[i].infra && !containers[j].infra })
+
+	for _, c := range containers {
+		eventChan := s.createEventChan(c.Id)
+		sin, sout, serr := s.ContainerStreamIDs(c.Id)
+		stdin, stdout, stderr := s.setupStd(sin, sout, serr)
+		c.Stdin, c.Stdout, c.Stderr = stdin, stdout, stderr
+
+		process, err := s.restartContainer(c, eventChan, true)
+		if err != nil {
+			log.Printf("cannot restart container %s: %v", c.Id.String(), err)
+			s.closeStd(stdin, c.Id, stdout, stderr)
+			s.closeEventChan(eventChan, c.Id)
+			continue
+		}
+		go func(c *Container) {
+			defer s.closeEventChan(eventChan, c.Id)
+			defer s.closeStd(stdin, c.Id, stdout, stderr)
+
+			s.waitContainer(c, eventChan, process.Cmd.Wait)
+			s.keepRestarting(c, eventChan)
+		}(c)
+	}
+}
+
+// markStopped records that a container is stopped on purpose, so its restart policy doesn't restart it.
+func (s *server) markStopped(c *Container) {
+	s.currentlyRunningMutex.Lock()
+	c.stopped = true
+	if c.Status != statusRestarting {
+		s.currentlyRunningMutex.Unlock()
+		return
+	}
+	c.Status = statusExited
+	state := c.state()
+	s.currentlyRunningMutex.Unlock()
+	s.saveState(state)
+}
@@ -0,0 +1,90 @@
+package daemon
+
+import (
+	"cont/api"
+	"testing"
+	"time"
+)
+
+func TestParseRestartPolicy(t *testing.T) {
+	tests := []struct {
+		policy     string
+		name       string
+		maxRetries int
+	}{
+		{"", restartNo, 0},
+		{"no", restartNo, 0},
+		{"always", restartAlways, 0},
+		{"unless-stopped", restartUnlessStopped, 0},
+		{"on-failure", restartOnFailure, 0},
+		{"on-failure:0", restartOnFailure, 0},
+		{"on-failure:5", restartOnFailure, 5},
+	}
+	for _, test := range tests {
+		policy, err := parseRestartPolicy(test.policy)
+		if err != nil {
+			t.Errorf("parseRestartPolicy(%q): %v", test.policy, err)
+			continue
+		}
+		if policy.name != test.name || policy.maxRetries != test.maxRetries {
+			t.Errorf("parseRestartPolicy(%q) = %+v, expected %s with %d retries", test.policy, policy, test.name,
+				test.maxRetries)
+		}
+	}
+
+	for _, policy := range []string{"sometimes", "on-failure:", "on-failure:-1", "on-failure:x", "always:3", "no:1", ":"} {
+		if _, err := parseRestartPolicy(policy); err == nil {
+			t.Errorf("parseRestartPolicy(%q) didn't fail", policy)
+		}
+	}
+}
+
+func TestShouldRestart(t *testing.T) {
+	tests := []struct {
+		policy      string
+		exitCode    int
+		restarts    int
+		stopped     bool
+		daemonStart bool
+		expected    bool
+	}{
+		{"no", 1, 0, false, false, false},
+		{"always", 0, 0, false, false, true},
+		{"always", 0, 0, true, false, false},
+		{"always", 0, 0, true, true, true},
+		{"unless-stopped", 1, 0, false, false, true},
+		{"unless-stopped", 1, 0, true, true, false},
+		{"on-failure", 0, 0, false, false, false},
+		{"on-failure", 1, 100, false, false, true},
+		{"on-failure:3", 1, 2, false, false, true},
+		{"on-failure:3", 1, 3, false, false, false},
+		{"on-failure", 1, 0, true, false, false},
+		{"invalid", 1, 0, false, false, false},
+	}
+	for _, test := range tests {
+		c := &Container{ExitCode: test.exitCode, RestartCount: test.restarts, stopped: test.stopped}
+		c.Spec = &api.ContainerRequest{Restart: test.policy}
+		if restart := shouldRestart(c, test.daemonStart); restart != test.expected {
+			t.Errorf("shouldRestart = %v for %+v", restart, test)
+		}
+	}
+}
+
+func TestRestartDelay(t *testing.T) {
+	tests := []struct {
+		previous, ran, expected time.Duration
+	}{
+		{0, 0, restartInitialDelay},
+		{0, time.Hour, restartInitialDelay},
+		{restartInitialDelay, time.Second, 2 * restartInitialDelay},
+		{2 * restartInitialDelay, 0, 4 * restartInitialDelay},
+		{40 * time.Second, time.Second, restartMaxDelay},
+		{restartMaxDelay, time.Second, restartMaxDelay},
+		{restartMaxDelay, restartResetAfter, restartInitialDelay},
+	}
+	for _, test := range tests {
+		if delay := restartDelay(test.previous, test.ran); delay != test.expected {
+			t.Errorf("restartDelay(%s, %s) = %s, expected %s", test.previous, test.ran, delay, test.expected)
+		}
+	}
+}
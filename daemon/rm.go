@@ -0,0 +1,39 @@
+package daemon
+
+import (
+	"cont/api"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+)
+
+func (s *server) Rm(ctx context.Context, request *api.RemoveRequest) (*api.Empty, error) {
+	id, err := uuid.ParseBytes(request.Id)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := s.findContainer(id)
+	if !ok {
+		return nil, errors.New("container doesn't exist")
+	}
+
+	done := s.doneChan(c)
+	if c, ok := s.getContainer(id); ok {
+		if !request.Force {
+			return nil, fmt.Errorf("container %s is running, kill it first or force the removal", id.String())
+		}
+		eventChan, ok := s.getEventChan(id)
+		if !ok {
+			return nil, errors.New("cannot find container events")
+		}
+		s.killContainer(c, eventChan, request.Id)
+	}
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return &api.Empty{}, s.removeContainer(id)
+}
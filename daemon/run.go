@@ -5,12 +5,20 @@ import (
 	"cont/cmd"
 	"cont/container"
 	"cont/multiplex"
+	"cont/network"
+	"cont/seccomp"
 	"context"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"golang.org/x/sys/unix"
 	"log"
+	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"syscall"
+	"time"
 )
 
 func (s *server) Run(ctx context.Context, request *api.ContainerRequest) (*api.ContainerResponse, error) {
@@ -19,19 +27,101 @@ func (s *server) Run(ctx context.Context, request *api.ContainerRequest) (*api.C
 	if err != nil {
 		return nil, err
 	}
+	if err := s.applyImageConfig(request); err != nil {
+		return nil, err
+	}
+	if err := s.validateRequest(request); err != nil {
+		return nil, err
+	}
 
-	go s.runContainer(request, id)
+	go s.runContainer(request, id, runOptions{})
 	return &api.ContainerResponse{Uuid: idBytes}, nil
 }
 
-func (s *server) runContainer(request *api.ContainerRequest, id uuid.UUID) {
+// runOptions configure containers the daemon starts on its own, e.g. pod infra containers.
+type runOptions struct {
+	infraPod string       // pod the container is the infra container of
+	started  chan<- error // receives nil once the container is started or the error it failed with, optional
+}
+
+// validateRequest checks a container request before the container is created, so clients get errors right away.
+func (s *server) validateRequest(request *api.ContainerRequest) error {
+	if request.StopSignal != "" {
+		if _, err := parseSignal(request.StopSignal); err != nil {
+			return fmt.Errorf("invalid stop signal: %w", err)
+		}
+	}
+	if _, err := parseRestartPolicy(request.Restart); err != nil {
+		return err
+	}
+	if err := container.ValidateEnv(request.Env); err != nil {
+		return err
+	}
+	if err := container.ValidateUser(request.User); err != nil {
+		return err
+	}
+	if _, err := container.ResolveCapabilities(request.CapAdd, request.CapDrop); err != nil {
+		return err
+	}
+	if _, err := seccompProfile(request); err != nil {
+		return err
+	}
+	if err := s.validatePod(request); err != nil {
+		return err
+	}
+	if err := s.validateShareNS(request); err != nil {
+		return err
+	}
+	for _, t := range request.Tmpfs {
+		if !filepath.IsAbs(t.Destination) {
+			return fmt.Errorf("tmpfs destination %s is not an absolute path", t.Destination)
+		}
+	}
+	if request.Network != "" && request.Network != network.None {
+		if _, ok, err := slirpOptions(request.Network); err != nil {
+			return err
+		} else if !ok {
+			if _, err := s.networks.Get(request.Network); err != nil {
+				return err
+			}
+		}
+		if sharesNS(request, unix.CLONE_NEWNET) {
+			return errors.New("a container sharing a network namespace can't be connected to a network")
+		}
+	}
+	if err := validatePorts(request.Ports); err != nil {
+		return err
+	}
+	if len(request.Ports) > 0 && sharesNS(request, unix.CLONE_NEWNET) {
+		return errors.New("a container sharing a network namespace can't publish ports")
+	}
+	if err := validateDNS(request); err != nil {
+		return err
+	}
+	for _, maps := range [][]*api.IDMap{request.UidMaps, request.GidMaps} {
+		for _, m := range maps {
+			if m.ContainerId < 0 || m.HostId < 0 || m.Size <= 0 {
+				return fmt.Errorf("invalid ID map %d:%d:%d", m.ContainerId, m.HostId, m.Size)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *server) runContainer(request *api.ContainerRequest, id uuid.UUID, options runOptions) {
 	eventChan := s.createEventChan(id)
 	defer s.closeEventChan(eventChan, id)
+	fail := func(err error) {
+		s.sendFailedEvent(eventChan, id, err)
+		if options.started != nil {
+			options.started <- err
+		}
+	}
 
 	binaryId, err := id.MarshalBinary()
 	if err != nil {
 		log.Printf("cannot marshal UUID to binary: %v", err)
-		s.sendFailedEvent(eventChan, id, err)
+		fail(err)
 		return
 	}
 
@@ -48,35 +138,54 @@ func (s *server) runContainer(request *api.ContainerRequest, id uuid.UUID) {
 
 	defer s.closeStd(stdin, id, stdout, stderr)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	newContainer := &Container{
+		Name:      request.Name,
+		Id:        id,
+		Command:   strings.Join(append([]string{request.Cmd}, request.Args...), " "),
+		Spec:      request,
+		Created:   time.Now(),
+		Stdin:     stdin,
+		Stdout:    stdout,
+		Stderr:    stderr,
+		Streamers: make(map[uuid.UUID]*streamConn),
+		execs:     make(map[uuid.UUID]*execSession),
+		done:      make(chan struct{}),
+		attached:  make(chan struct{}),
+		Pod:       request.Pod,
+	}
+	if options.infraPod != "" { // nobody attaches to infra containers
+		newContainer.Pod, newContainer.infra = options.infraPod, true
+		newContainer.attach.Do(func() { close(newContainer.attached) })
+	}
 
-	shareConfig, err := s.setupShareConfig(request)
+	mounts, volumes, err := s.setupMounts(id, request)
 	if err != nil {
-		log.Printf("cannot setup share config: %v", err)
-		s.sendFailedEvent(eventChan, id, err)
+		log.Printf("cannot setup mounts: %v", err)
+		fail(err)
 		return
 	}
+	newContainer.volumes = volumes
+	mounts = append(mounts, s.dnsMounts(newContainer, mounts)...)
 
-	containerCommand, err := container.Start(ctx, &container.Config{
-		Stdin:                 stdin,
-		Stdout:                stdout,
-		Stderr:                stderr,
-		Hostname:              request.Hostname,
-		Workdir:               request.Workdir,
-		Cmd:                   request.Cmd,
-		Args:                  request.Args,
-		Interactive:           request.Opts.Interactive,
-		SharedNamespaceConfig: shareConfig,
-		Logging: container.LoggingConfig{
-			Path: filepath.Join("./logs", id.String()), // todo: use /var/log/cont/<container_id> for logs
-		},
-	})
+	rootfs, mount, workdir, err := s.setupRootfs(id, request)
 	if err != nil {
-		log.Printf("container start error: %v\n", err)
-		s.sendFailedEvent(eventChan, id, err)
+		log.Printf("cannot setup rootfs: %v", err)
+		fail(err)
+		s.releaseContainer(newContainer)
+		return
+	}
+	newContainer.rootfs = mount
+
+	process, err := s.startContainer(newContainer, mounts, rootfs, workdir)
+	if err != nil {
+		log.Printf("cannot start container %s: %v", id.String(), err)
+		fail(err)
+		s.releaseContainer(newContainer)
 		return
 	}
+	s.addContainer(newContainer)
+
+	// the client attaches once the container is started, the command is started afterwards so no output is lost
 	s.sendEvent(eventChan, &api.Event{
 		Id:      binaryId,
 		Type:    cmd.Started,
@@ -84,25 +193,108 @@ func (s *server) runContainer(request *api.ContainerRequest, id uuid.UUID) {
 		Source:  "", // todo: fill source
 		Data:    nil,
 	})
+	if options.started != nil {
+		options.started <- nil
+	}
+	select {
+	case <-newContainer.attached:
+	case <-time.After(attachTimeout):
+		log.Printf("no client attached to container %s", id.String())
+	}
+	if err := process.Start(); err != nil {
+		log.Printf("cannot start container %s command: %v", id.String(), err)
+	}
 	log.Printf("container %s started\n", id.String())
 
-	newContainer := &Container{
-		Cmd:       containerCommand,
-		Name:      request.Name,
-		Id:        id,
-		Command:   strings.Join(append([]string{request.Cmd}, request.Args...), " "),
-		Stdin:     stdin,
-		Stdout:    stdout,
-		Stderr:    stderr,
-		cancel:    cancel,
-		Streamers: make(map[uuid.UUID]*streamConn),
+	s.waitContainer(newContainer, eventChan, process.Cmd.Wait)
+	s.keepRestarting(newContainer, eventChan)
+}
+
+// startContainer creates the container process and starts the container command, for the first start and restarts.
+func (s *server) startContainer(c *Container, mounts []container.Mount, rootfs, workdir string) (*container.Process, error) {
+	request := c.Spec
+	shareConfig, podInfra, err := s.setupShareConfig(request)
+	if err != nil {
+		return nil, fmt.Errorf("cannot setup share config: %w", err)
+	}
+	c.podInfra = podInfra
+	capabilities, err := container.ResolveCapabilities(request.CapAdd, request.CapDrop)
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve capabilities: %w", err)
+	}
+	profile, err := seccompProfile(request)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse seccomp profile: %w", err)
 	}
-	s.addContainer(newContainer)
-	defer s.removeContainer(id)
 
-	if err = containerCommand.Wait(); err != nil {
-		log.Printf("wait error (container is dead): %v\n", err)
-		log.Printf("container %s killed \n", id.String())
+	if c.cgroup, err = s.setupCgroup(c.Id, request); err != nil {
+		return nil, fmt.Errorf("cannot setup cgroup: %w", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	process, err := container.Create(ctx, &container.Config{
+		Stdin:                 c.Stdin,
+		Stdout:                c.Stdout,
+		Stderr:                c.Stderr,
+		Hostname:              request.Hostname,
+		Workdir:               workdir,
+		Rootfs:                rootfs,
+		Mounts:                mounts,
+		Tmpfs:                 tmpfs(request.Tmpfs),
+		ReadOnly:              request.ReadOnly,
+		Cmd:                   request.Cmd,
+		Args:                  request.Args,
+		Env:                   request.Env,
+		User:                  request.User,
+		UidMappings:           idMaps(request.UidMaps),
+		GidMappings:           idMaps(request.GidMaps),
+		Capabilities:          capabilities,
+		NoNewPrivileges:       !request.AllowNewPrivileges,
+		Seccomp:               profile,
+		Interactive:           request.Opts.Interactive,
+		SharedNamespaceConfig: shareConfig,
+		Logging:               s.loggingConfig(c.Id),
+		StdioDir:              s.containerDir(c.Id),
+		Cgroup:                c.cgroup,
+	})
+	if err != nil {
+		cancel()
+		s.releaseCgroup(c)
+		return nil, err
+	}
+	c.Pid = process.Cmd.Process.Pid
+	if err := s.setupNetworking(c, request); err != nil {
+		process.Kill()
+		cancel()
+		s.releaseNetworking(c)
+		s.releaseCgroup(c)
+		return nil, fmt.Errorf("cannot set up networking: %w", err)
+	}
+	startTime, err := container.ProcessStartTime(c.Pid)
+	if err != nil {
+		log.Printf("cannot get container %s start time: %v", c.Id.String(), err)
+	}
+	s.currentlyRunningMutex.Lock()
+	c.cancel = cancel
+	c.Started = time.Now()
+	c.Status = statusRunning
+	c.StartTime = startTime
+	state := c.state()
+	s.currentlyRunningMutex.Unlock()
+	s.saveState(state)
+	return process, nil
+}
+
+// waitContainer waits for the container to exit, records its exit status and sends the done event.
+func (s *server) waitContainer(c *Container, eventChan chan *api.Event, wait func() error) {
+	err := wait()
+	if err != nil {
+		log.Printf("wait error: %v\n", err)
+	}
+	s.exitContainer(c.Id, err)
+
+	binaryId, err := c.Id.MarshalBinary()
+	if err != nil {
+		log.Printf("cannot marshal UUID to binary: %v", err)
 		return
 	}
 	log.Println("sending done event")
@@ -113,37 +305,146 @@ func (s *server) runContainer(request *api.ContainerRequest, id uuid.UUID) {
 		Source:  "", // todo: fill source
 		Data:    nil,
 	})
-	log.Printf("container %s done\n", id.String())
+	log.Printf("container %s done (exit code %d)\n", c.Id.String(), c.ExitCode)
+}
+
+func (s *server) loggingConfig(id uuid.UUID) container.LoggingConfig {
+	return container.LoggingConfig{
+		Path: filepath.Join("./logs", id.String()), // todo: use /var/log/cont/<container_id> for logs
+	}
+}
+
+// seccompProfile returns the seccomp profile of a container, nil if it's unconfined.
+func seccompProfile(request *api.ContainerRequest) (*seccomp.Profile, error) {
+	switch request.Seccomp {
+	case "":
+		return seccomp.DefaultProfile(), nil
+	case seccomp.Unconfined:
+		return nil, nil
+	}
+	return seccomp.Parse([]byte(request.Seccomp))
 }
 
-func (s *server) setupShareConfig(request *api.ContainerRequest) (container.SharedNamespaceConfig, error) {
+func tmpfs(mounts []*api.Tmpfs) []container.Tmpfs {
+	result := make([]container.Tmpfs, 0, len(mounts))
+	for _, t := range mounts {
+		result = append(result, container.Tmpfs{Destination: t.Destination, Options: t.Options})
+	}
+	return result
+}
+
+func idMaps(maps []*api.IDMap) []container.IDMap {
+	result := make([]container.IDMap, 0, len(maps))
+	for _, m := range maps {
+		result = append(result, container.IDMap{ContainerID: int(m.ContainerId), HostID: int(m.HostId), Size: int(m.Size)})
+	}
+	return result
+}
+
+// shareableNamespaces are the CLONE_NEW* flags of the namespaces a container can share with another one.
+const shareableNamespaces = unix.CLONE_NEWUSER | unix.CLONE_NEWNS | unix.CLONE_NEWNET | unix.CLONE_NEWIPC |
+	unix.CLONE_NEWUTS | unix.CLONE_NEWPID | unix.CLONE_NEWCGROUP | container.CloneNewTime
+
+// validateShareNS checks the namespaces a container shares with another container, which has to be running.
+func (s *server) validateShareNS(request *api.ContainerRequest) error {
+	shareOpts := request.GetOpts().GetShareOpts()
+	if shareOpts.GetFlags() == 0 {
+		return nil
+	}
+	if shareOpts.Flags&^shareableNamespaces != 0 {
+		return fmt.Errorf("invalid shared namespace flags %#x", shareOpts.Flags)
+	}
+	id, err := uuid.FromBytes(shareOpts.ShareID)
+	if err != nil {
+		return fmt.Errorf("invalid shared container ID: %w", err)
+	}
+	other, ok := s.getContainer(id)
+	if !ok {
+		return fmt.Errorf("container %s is not currently running", id.String())
+	}
+	if len(request.UidMaps) > 0 || len(request.GidMaps) > 0 {
+		if !sameIDMaps(request.UidMaps, other.Spec.UidMaps) || !sameIDMaps(request.GidMaps, other.Spec.GidMaps) {
+			return fmt.Errorf("joining the user namespace of container %s requires its ID maps", id.String())
+		}
+	}
+	if shareOpts.Flags&unix.CLONE_NEWNS != 0 {
+		if shareOpts.Flags&unix.CLONE_NEWPID == 0 {
+			return errors.New("a container sharing a mount namespace has to share the PID namespace, /proc belongs to it")
+		}
+		if request.Rootfs != "" || request.Image != "" || len(request.Mounts) > 0 || len(request.Tmpfs) > 0 || request.ReadOnly {
+			return errors.New("a container sharing a mount namespace uses the filesystem of the other container")
+		}
+	}
+	return nil
+}
+
+func sameIDMaps(a, b []*api.IDMap) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ContainerId != b[i].ContainerId || a[i].HostId != b[i].HostId || a[i].Size != b[i].Size {
+			return false
+		}
+	}
+	return true
+}
+
+// setupShareConfig returns the namespaces a container shares, with the infra container of its pod if it's in one.
+func (s *server) setupShareConfig(request *api.ContainerRequest) (container.SharedNamespaceConfig, *Container, error) {
 	var result container.SharedNamespaceConfig
-	doShare := request.Opts.ShareOpts.Flags != 0
-	if !doShare {
-		return result, nil
+	if request.Pod != "" {
+		infra, ok := s.podInfra(request.Pod)
+		if !ok {
+			return result, nil, fmt.Errorf("pod %s is not running", request.Pod)
+		}
+		result.Flags = int(sharedNSFlags(request))
+		result.PID = infra.Pid
+		return result, infra, nil
+	}
+	if request.GetOpts().GetShareOpts().GetFlags() == 0 {
+		return result, nil, nil
 	}
-	result.Flags = int(request.Opts.ShareOpts.Flags) // might have problems with truncating
+	result.Flags = int(sharedNSFlags(request))
 
 	containerID, err := uuid.FromBytes(request.Opts.ShareOpts.ShareID)
 	if err != nil {
-		return result, err
+		return result, nil, err
 	}
 
 	c, ok := s.getContainer(containerID)
 	if !ok {
-		return result, fmt.Errorf("container %s is not currently running", containerID.String())
+		return result, nil, fmt.Errorf("container %s is not currently running", containerID.String())
 	}
 
-	result.PID = c.Cmd.Process.Pid
-	return result, nil
+	result.PID = c.Pid
+	return result, nil, nil
 }
 
-func (s *server) removeContainer(id uuid.UUID) {
-	s.currentlyRunningMutex.Lock()
-	defer s.currentlyRunningMutex.Unlock()
+// sharesNS reports whether a container shares a namespace (a CLONE_NEW* flag) with another container.
+func sharesNS(request *api.ContainerRequest, flag int64) bool {
+	return sharedNSFlags(request)&flag != 0
+}
+
+// sharedNSFlags returns the CLONE_NEW* flags of the namespaces a container shares, the user namespace included.
+func sharedNSFlags(request *api.ContainerRequest) int64 {
+	if request.Pod != "" {
+		flags, _ := podNSFlags(request.PodNamespaces)
+		return flags
+	}
+	flags := request.GetOpts().GetShareOpts().GetFlags()
+	if flags == 0 {
+		return 0
+	}
+	return flags | unix.CLONE_NEWUSER
+}
 
+// exitContainer records the container exit status, the container is kept until it's removed.
+func (s *server) exitContainer(id uuid.UUID, waitErr error) {
+	s.currentlyRunningMutex.Lock()
 	c, ok := s.currentlyRunning[id]
 	if !ok {
+		s.currentlyRunningMutex.Unlock()
 		return
 	}
 	for streamId, streamer := range c.Streamers {
@@ -151,8 +452,77 @@ func (s *server) removeContainer(id uuid.UUID) {
 			log.Printf("cannot close streamer %s: %v", streamId.String(), err)
 		}
 	}
-
+	for _, session := range c.execs {
+		session.cancel()
+	}
 	delete(s.currentlyRunning, id)
+
+	c.Status = statusExited
+	c.Finished = time.Now()
+	c.ExitCode, c.Signal = exitStatus(waitErr)
+	s.releaseCgroup(c)
+	s.releaseNetworking(c)
+	state := c.state()
+	s.exited[id] = c
+	close(c.done)
+	s.currentlyRunningMutex.Unlock()
+	s.saveState(state)
+}
+
+// exitStatus returns the exit code and the signal which killed a container, the code is 128 + signal number then.
+func exitStatus(err error) (int, string) {
+	if err == nil {
+		return 0, ""
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return -1, ""
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal()), unix.SignalName(status.Signal())
+	}
+	return exitErr.ExitCode(), ""
+}
+
+// removeContainer removes an exited container and releases its resources.
+func (s *server) removeContainer(id uuid.UUID) error {
+	s.currentlyRunningMutex.Lock()
+	c, ok := s.exited[id]
+	delete(s.exited, id)
+	s.currentlyRunningMutex.Unlock()
+
+	if !ok {
+		return fmt.Errorf("container %s doesn't exist or is running", id.String())
+	}
+	s.releaseContainer(c)
+	return nil
+}
+
+// releaseContainer releases everything set up for a container: its rootfs, volumes, cgroup and state.
+func (s *server) releaseContainer(c *Container) {
+	if c.rootfs != nil {
+		if err := c.rootfs.Unmount(); err != nil {
+			log.Printf("cannot unmount rootfs for container %s: %v", c.Id.String(), err)
+		}
+		s.imagesMutex.Lock()
+		delete(s.imageMounts, c.Id)
+		s.imagesMutex.Unlock()
+	}
+	s.releaseVolumes(c.Id, c.volumes)
+	s.releaseCgroup(c)
+	if err := os.RemoveAll(s.containerDir(c.Id)); err != nil {
+		log.Printf("cannot remove state for container %s: %v", c.Id.String(), err)
+	}
+}
+
+func (s *server) releaseCgroup(c *Container) {
+	if c.cgroup == nil {
+		return
+	}
+	if err := c.cgroup.Remove(); err != nil {
+		log.Printf("cannot remove cgroup for container %s: %v", c.Id.String(), err)
+	}
+	c.cgroup = nil
 }
 
 func (s *server) addContainer(newContainer *Container) {
@@ -170,6 +540,18 @@ func (s *server) getContainer(id uuid.UUID) (*Container, bool) {
 	return c, ok
 }
 
+// findContainer returns a running or an exited container.
+func (s *server) findContainer(id uuid.UUID) (*Container, bool) {
+	s.currentlyRunningMutex.RLock()
+	defer s.currentlyRunningMutex.RUnlock()
+
+	if c, ok := s.currentlyRunning[id]; ok {
+		return c, true
+	}
+	c, ok := s.exited[id]
+	return c, ok
+}
+
 func (s *server) updateContainer(id uuid.UUID, updateFunc func(c *Container) error) error {
 	c, ok := s.getContainer(id)
 	if !ok {
@@ -182,7 +564,7 @@ func (s *server) updateContainer(id uuid.UUID, updateFunc func(c *Container) err
 
 func (s *server) getCurrentlyRunning() []*Container {
 	s.currentlyRunningMutex.RLock()
-	s.currentlyRunningMutex.RUnlock()
+	defer s.currentlyRunningMutex.RUnlock()
 
 	containers := make([]*Container, 0, len(s.currentlyRunning))
 	for _, c := range s.currentlyRunning {
@@ -191,6 +573,21 @@ func (s *server) getCurrentlyRunning() []*Container {
 	return containers
 }
 
+// getAllContainers returns running and exited containers.
+func (s *server) getAllContainers() []*Container {
+	s.currentlyRunningMutex.RLock()
+	defer s.currentlyRunningMutex.RUnlock()
+
+	containers := make([]*Container, 0, len(s.currentlyRunning)+len(s.exited))
+	for _, c := range s.currentlyRunning {
+		containers = append(containers, c)
+	}
+	for _, c := range s.exited {
+		containers = append(containers, c)
+	}
+	return containers
+}
+
 func (s *server) setupStd(sin string, sout string, serr string) (*multiplex.Receiver, *multiplex.Sender, *multiplex.Sender) {
 	stdin := s.muxClient.NewReceiver(sin)
 	stdout := s.muxClient.NewSender(sout)
@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		script string
+		code   int
+		signal string
+	}{
+		{"exit 0", 0, ""},
+		{"exit 3", 3, ""},
+		{"kill -KILL $$", 137, "SIGKILL"},
+		{"kill -TERM $$", 143, "SIGTERM"},
+	}
+	for _, test := range tests {
+		code, signal := exitStatus(exec.Command("sh", "-c", test.script).Run())
+		if code != test.code || signal != test.signal {
+			t.Errorf("exit status of %q = %d, %q, expected %d, %q", test.script, code, signal, test.code, test.signal)
+		}
+	}
+
+	if code, signal := exitStatus(errors.New("cannot wait")); code != -1 || signal != "" {
+		t.Errorf("exitStatus of an unknown error = %d, %q, expected -1 without a signal", code, signal)
+	}
+}
@@ -2,36 +2,83 @@ package daemon
 
 import (
 	"cont/api"
+	"cont/cgroup"
+	"cont/container"
+	"cont/image"
 	"cont/multiplex"
+	"cont/network"
+	"cont/slirp"
+	"cont/volume"
 	"context"
 	"errors"
+	"fmt"
 	"github.com/google/uuid"
 	"io"
+	"log"
 	"net"
-	"os/exec"
+	"os"
+	"path/filepath"
 	"sync"
+	"time"
 )
 
 type Container struct {
-	Cmd            *exec.Cmd
 	Name           string
 	Id             uuid.UUID
 	Command        string
+	Spec           *api.ContainerRequest // request the container was created from
+	Status         string
+	Pid            int
+	StartTime      uint64 // process start time, the PID and the start time identify the container process
+	Created        time.Time
+	Started        time.Time
+	Finished       time.Time
+	ExitCode       int           // -1 if unknown, e.g. the container exited while the daemon was down
+	Signal         string        // signal which killed the container, if any
+	done           chan struct{} // closed once the container exits
+	attached       chan struct{} // closed once a client requests the container streams
+	attach         sync.Once
 	Stdin          io.ReadCloser
 	Stdout, Stderr io.WriteCloser
 	cancel         context.CancelFunc
 	Streamers      map[uuid.UUID]*streamConn
+	rootfs         *image.Rootfs // copy-on-write rootfs, nil if the container doesn't use an image
+	volumes        []string      // names of volumes the container uses
+	endpoint       *network.Endpoint
+	slirp          *slirp.Stack          // userspace network stack of slirp containers
+	ports          []network.PortMapping // published ports, with the host ports picked for random ones
+	proxy          *network.Proxy
+	netHelper      *container.NetHelper // creates proxy & DNS sockets in the container network namespace
+	dns            *network.DNSServer   // embedded DNS server, nil if the container shares a network namespace
+	cgroup         *cgroup.Cgroup
+	Pod            string                     // pod the container is in, empty if it isn't
+	infra          bool                       // the container owns the pod namespaces
+	podInfra       *Container                 // infra container of the pod, set for the other pod containers
+	RestartCount   int                        // restarts by the restart policy
+	restartDelay   time.Duration              // delay before the next restart, doubled while the container keeps crashing
+	stopped        bool                       // stopped on purpose, the restart policy doesn't restart it
+	execs          map[uuid.UUID]*execSession // processes started with exec
 }
 
 type server struct {
 	api.UnimplementedApiServer
 	muxClient             *multiplex.Client
+	stateDir              string
+	images                *image.Store
+	volumes               *volume.Store
+	networks              *network.Store
+	cgroupParent          string // delegated cgroup v2 subtree, empty if cgroups aren't available
 	connections           map[uuid.UUID]*streamConn
 	currentlyRunning      map[uuid.UUID]*Container
+	exited                map[uuid.UUID]*Container // kept until removed with rm
+	imageMounts           map[uuid.UUID]string     // image ID of each container rootfs, including containers being created
+	creatingPods          map[string]bool          // pods whose infra container is being started
 	events                map[uuid.UUID]chan *api.Event
 	connectionsMutex      sync.RWMutex
 	currentlyRunningMutex sync.RWMutex
 	eventMutex            sync.RWMutex
+	imagesMutex           sync.Mutex // image removals exclude image mounts
+	podsMutex             sync.Mutex // pod creations reserve the pod name
 }
 
 type streamConn struct {
@@ -40,19 +87,83 @@ type streamConn struct {
 	mux *multiplex.Mux
 }
 
-func NewServer(muxClient *multiplex.Client, connectionListener net.Listener) (*server, error) {
+// DefaultStateDir returns the directory the daemon keeps its state in (images, volumes, container state...).
+func DefaultStateDir() string {
+	if os.Geteuid() == 0 {
+		return "/var/lib/cont"
+	}
+	if dataHome, ok := os.LookupEnv("XDG_DATA_HOME"); ok && dataHome != "" {
+		return filepath.Join(dataHome, "cont")
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), "cont")
+	}
+	return filepath.Join(homeDir, ".local", "share", "cont")
+}
+
+func NewServer(muxClient *multiplex.Client, connectionListener net.Listener, stateDir string) (*server, error) {
 	if muxClient == nil {
 		return nil, errors.New("muxClient is nil")
 	}
 	if connectionListener == nil {
 		return nil, errors.New("connectionListener is nil")
 	}
+	images, err := image.NewStore(filepath.Join(stateDir, "images"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open image store: %w", err)
+	}
+	volumes, err := volume.NewStore(filepath.Join(stateDir, "volumes"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open volume store: %w", err)
+	}
+	networks, err := network.NewStore(filepath.Join(stateDir, "networks"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot open network store: %w", err)
+	}
 	s := &server{
 		muxClient:        muxClient,
+		stateDir:         stateDir,
+		images:           images,
+		volumes:          volumes,
+		networks:         networks,
 		connections:      make(map[uuid.UUID]*streamConn),
 		currentlyRunning: make(map[uuid.UUID]*Container),
+		exited:           make(map[uuid.UUID]*Container),
+		imageMounts:      make(map[uuid.UUID]string),
+		creatingPods:     make(map[string]bool),
 		events:           make(map[uuid.UUID]chan *api.Event),
 	}
+	if s.cgroupParent, err = cgroup.Parent(); err != nil {
+		log.Printf("cgroups are disabled: %v", err)
+	}
+	if err := s.restoreContainers(); err != nil {
+		return nil, fmt.Errorf("cannot restore containers: %w", err)
+	}
+	// drop volume references of containers the daemon doesn't know about (e.g. after a restart)
+	if err := volumes.Reconcile(func(containerID string) bool {
+		id, err := uuid.Parse(containerID)
+		if err != nil {
+			return false
+		}
+		_, ok := s.findContainer(id)
+		return ok
+	}); err != nil {
+		return nil, fmt.Errorf("cannot reconcile volumes: %w", err)
+	}
+	// release IPs of containers which exited while the daemon was down
+	if err := networks.Reconcile(func(containerID string) bool {
+		id, err := uuid.Parse(containerID)
+		if err != nil {
+			return false
+		}
+		_, ok := s.getContainer(id)
+		return ok
+	}); err != nil {
+		return nil, fmt.Errorf("cannot reconcile networks: %w", err)
+	}
+	// restart containers which exited while the daemon was down, once their IPs and volumes are reconciled
+	s.restartContainers()
 	go s.acceptStreamConnections(connectionListener)
 
 	return s, nil
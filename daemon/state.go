@@ -0,0 +1,252 @@
+package daemon
+
+import (
+	"cont/api"
+	"cont/cgroup"
+	"cont/container"
+	"cont/image"
+	"cont/network"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+const (
+	containersDir = "containers"
+	stateFile     = "state.json"
+)
+
+const (
+	statusRunning    = "running"
+	statusRestarting = "restarting" // exited, waiting for the restart policy to restart it
+	statusExited     = "exited"
+)
+
+// errUnknownExitStatus is returned when waiting for adopted containers, they aren't our children.
+var errUnknownExitStatus = errors.New("exit status is unknown")
+
+// containerState is the persisted container state, which lets a restarted daemon adopt running containers.
+type containerState struct {
+	Id        uuid.UUID             `json:"id"`
+	Name      string                `json:"name"`
+	Command   string                `json:"command"`
+	Spec      *api.ContainerRequest `json:"spec"`
+	Status    string                `json:"status"`
+	Pid       int                   `json:"pid"`
+	StartTime uint64                `json:"startTime"` // process start time in clock ticks after boot
+	Created   time.Time             `json:"created"`
+	Started   time.Time             `json:"started"`
+	Finished  time.Time             `json:"finished"`
+	ExitCode  int                   `json:"exitCode"` // -1 if unknown
+	Signal    string                `json:"signal,omitempty"`
+	Rootfs    *image.Rootfs         `json:"rootfs,omitempty"`
+	Volumes   []string              `json:"volumes"`
+	Network   *network.Endpoint     `json:"network,omitempty"`
+	Ports     []network.PortMapping `json:"ports,omitempty"`
+	Pod       string                `json:"pod,omitempty"`
+	Infra     bool                  `json:"infra,omitempty"`  // pod infra container
+	Cgroup    string                `json:"cgroup,omitempty"` // cgroup path
+	Restarts  int                   `json:"restartCount"`
+	Stopped   bool                  `json:"stopped,omitempty"` // stopped on purpose, the restart policy doesn't apply
+}
+
+func (s *server) containerDir(id uuid.UUID) string {
+	return filepath.Join(s.stateDir, containersDir, id.String())
+}
+
+// state returns the persisted state of a container.
+func (c *Container) state() containerState {
+	state := containerState{
+		Id:        c.Id,
+		Name:      c.Name,
+		Command:   c.Command,
+		Spec:      c.Spec,
+		Status:    c.Status,
+		Pid:       c.Pid,
+		StartTime: c.StartTime,
+		Created:   c.Created,
+		Started:   c.Started,
+		Finished:  c.Finished,
+		ExitCode:  c.ExitCode,
+		Signal:    c.Signal,
+		Rootfs:    c.rootfs,
+		Volumes:   c.volumes,
+		Network:   c.endpoint,
+		Ports:     c.ports,
+		Pod:       c.Pod,
+		Infra:     c.infra,
+		Restarts:  c.RestartCount,
+		Stopped:   c.stopped,
+	}
+	if c.cgroup != nil {
+		state.Cgroup = c.cgroup.Path
+	}
+	return state
+}
+
+// saveState persists a container state taken under the containers lock, a missing directory means the container was
+// removed.
+func (s *server) saveState(state containerState) {
+	if err := s.writeState(state); err != nil && !os.IsNotExist(err) {
+		log.Printf("cannot save container %s state: %v", state.Id.String(), err)
+	}
+}
+
+func (s *server) writeState(state containerState) error {
+	dir := s.containerDir(state.Id)
+	tmp, err := ioutil.TempFile(dir, stateFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(state); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, stateFile))
+}
+
+func (s *server) loadContainer(id string) (*Container, error) {
+	file, err := os.Open(filepath.Join(s.stateDir, containersDir, id, stateFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var state containerState
+	if err := json.NewDecoder(file).Decode(&state); err != nil {
+		return nil, fmt.Errorf("cannot decode container %s state: %w", id, err)
+	}
+	c := &Container{
+		Name:      state.Name,
+		Id:        state.Id,
+		Command:   state.Command,
+		Spec:      state.Spec,
+		Status:    state.Status,
+		Pid:       state.Pid,
+		StartTime: state.StartTime,
+		Created:   state.Created,
+		Started:   state.Started,
+		Finished:  state.Finished,
+		ExitCode:  state.ExitCode,
+		Signal:    state.Signal,
+		Streamers: make(map[uuid.UUID]*streamConn),
+		rootfs:    state.Rootfs,
+		volumes:   state.Volumes,
+		endpoint:  state.Network,
+		ports:     state.Ports,
+		Pod:       state.Pod,
+		infra:     state.Infra,
+		execs:     make(map[uuid.UUID]*execSession),
+		done:      make(chan struct{}),
+		attached:  make(chan struct{}),
+	}
+	c.RestartCount, c.stopped = state.Restarts, state.Stopped
+	if state.Cgroup != "" {
+		c.cgroup = &cgroup.Cgroup{Path: state.Cgroup}
+	}
+	return c, nil
+}
+
+// restoreContainers loads the containers of a previous daemon and adopts the running ones.
+func (s *server) restoreContainers() error {
+	dirs, err := ioutil.ReadDir(filepath.Join(s.stateDir, containersDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, dir := range dirs {
+		c, err := s.loadContainer(dir.Name())
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // the container didn't start
+			}
+			log.Printf("cannot load container %s: %v", dir.Name(), err)
+			continue
+		}
+		if c.rootfs != nil {
+			s.imageMounts[c.Id] = c.rootfs.Image
+		}
+		if c.Status == statusRunning && container.IsRunning(c.Pid, c.StartTime) {
+			s.adoptContainer(c)
+			continue
+		}
+		if c.Status == statusRestarting {
+			c.Status = statusExited // the restart policy applies again once the daemon is started
+		}
+		if c.Status == statusRunning {
+			log.Printf("container %s exited while the daemon was down", c.Id.String())
+			c.Status = statusExited
+			c.Finished = time.Now()
+			c.ExitCode = -1
+			s.releaseCgroup(c)
+			s.saveState(c.state()) // the daemon doesn't serve requests yet
+		}
+		close(c.done)
+		s.exited[c.Id] = c
+	}
+	s.linkPods()
+	return nil
+}
+
+// adoptContainer takes over a running container of a previous daemon, it isn't our child.
+func (s *server) adoptContainer(c *Container) {
+	eventChan := s.createEventChan(c.Id)
+
+	sin, sout, serr := s.ContainerStreamIDs(c.Id)
+	stdin, stdout, stderr := s.setupStd(sin, sout, serr)
+	c.Stdin, c.Stdout, c.Stderr = stdin, stdout, stderr
+	c.cancel = func() {
+		if container.IsRunning(c.Pid, c.StartTime) {
+			_ = syscall.Kill(c.Pid, syscall.SIGKILL)
+		}
+	}
+
+	if err := container.Attach(&container.Config{
+		Stdin:    stdin,
+		Stdout:   stdout,
+		Stderr:   stderr,
+		StdioDir: s.containerDir(c.Id),
+		Logging:  s.loggingConfig(c.Id),
+	}); err != nil {
+		log.Printf("cannot attach to container %s: %v", c.Id.String(), err)
+	}
+	if err := s.reconnectSlirp(c); err != nil {
+		log.Printf("cannot reconnect container %s to its network: %v", c.Id.String(), err)
+	}
+	if err := s.startDNS(c); err != nil {
+		log.Printf("cannot start DNS server of container %s: %v", c.Id.String(), err)
+	}
+	if err := s.publishPorts(c, c.ports); err != nil {
+		log.Printf("cannot publish container %s ports: %v", c.Id.String(), err)
+		c.ports = nil
+	}
+	s.addContainer(c)
+	log.Printf("adopted container %s (PID %d)", c.Id.String(), c.Pid)
+
+	go func() {
+		defer s.closeEventChan(eventChan, c.Id)
+		defer s.closeStd(stdin, c.Id, stdout, stderr)
+
+		s.waitContainer(c, eventChan, func() error {
+			container.WaitForExit(c.Pid, c.StartTime)
+			return errUnknownExitStatus
+		})
+		s.keepRestarting(c, eventChan)
+	}()
+}
@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"cont/api"
+	"cont/cgroup"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+const (
+	defaultStatsInterval = time.Second
+	minStatsInterval     = 100 * time.Millisecond
+)
+
+// Stats streams resource usage samples of the requested or all running containers until they exit.
+func (s *server) Stats(request *api.StatsRequest, statsServer api.Api_StatsServer) error {
+	ids := make([]uuid.UUID, 0, len(request.Ids))
+	for _, rawID := range request.Ids {
+		id, err := uuid.ParseBytes(rawID)
+		if err != nil {
+			return err
+		}
+		c, ok := s.getContainer(id)
+		if !ok {
+			return fmt.Errorf("container %s doesn't exist", id.String())
+		}
+		if s.containerCgroup(c) == nil {
+			return fmt.Errorf("container %s doesn't have a cgroup", id.String())
+		}
+		ids = append(ids, id)
+	}
+
+	interval := time.Duration(request.Interval) * time.Millisecond
+	if interval == 0 {
+		interval = defaultStatsInterval
+	} else if interval < minStatsInterval {
+		interval = minStatsInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	previous := make(map[uuid.UUID]cpuSample)
+	last := time.Now()
+	for {
+		now := time.Now()
+		sample := s.sampleStats(ids, previous, now.Sub(last))
+		last = now
+		if len(ids) != 0 && len(sample.Containers) == 0 { // all requested containers exited
+			return nil
+		}
+		if err := statsServer.Send(sample); err != nil {
+			return err
+		}
+
+		select {
+		case <-statsServer.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// cpuSample is the CPU usage of a container cgroup at the previous sample.
+type cpuSample struct {
+	cgroup *cgroup.Cgroup
+	usage  int64
+}
+
+// sampleStats reads stats of containers with cgroups. CPU usage is computed from the previous samples.
+func (s *server) sampleStats(ids []uuid.UUID, previous map[uuid.UUID]cpuSample, elapsed time.Duration) *api.StatsSample {
+	containers := make([]*Container, 0, len(ids))
+	if len(ids) == 0 {
+		containers = s.getCurrentlyRunning()
+	}
+	for _, id := range ids {
+		if c, ok := s.getContainer(id); ok {
+			containers = append(containers, c)
+		}
+	}
+
+	sample := &api.StatsSample{Timestamp: time.Now().UnixNano() / int64(time.Millisecond)}
+	for _, c := range containers {
+		cg := s.containerCgroup(c)
+		if cg == nil {
+			continue
+		}
+		stats, err := cg.Stats()
+		if err != nil {
+			log.Printf("cannot read stats of container %s: %v", c.Id.String(), err)
+			continue
+		}
+		var cpuPercent float64
+		// a restarted container gets a new cgroup, its usage starts over
+		if prev, ok := previous[c.Id]; ok && prev.cgroup == cg && elapsed > 0 {
+			cpuPercent = float64(stats.CPUUsage-prev.usage) / float64(elapsed.Microseconds()) * 100
+		}
+		previous[c.Id] = cpuSample{cgroup: cg, usage: stats.CPUUsage}
+
+		sample.Containers = append(sample.Containers, &api.ContainerStats{
+			Id:          c.Id.String(),
+			Name:        c.Name,
+			Memory:      stats.Memory,
+			MemoryLimit: stats.MemoryLimit,
+			CpuPercent:  cpuPercent,
+			CpuUsage:    stats.CPUUsage,
+			IoRead:      stats.IORead,
+			IoWrite:     stats.IOWrite,
+			Pids:        stats.Pids,
+		})
+	}
+	return sample
+}
@@ -0,0 +1,67 @@
+package daemon
+
+import (
+	"cont/api"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"golang.org/x/sys/unix"
+	"log"
+	"syscall"
+	"time"
+)
+
+func (s *server) Stop(ctx context.Context, request *api.StopRequest) (*api.ContainerResponse, error) {
+	id, err := uuid.ParseBytes(request.Id)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := s.getContainer(id)
+	if !ok {
+		if c, ok := s.findContainer(id); ok && c.Status == statusRestarting {
+			s.markStopped(c) // it isn't running, only its restart is canceled
+			return &api.ContainerResponse{Uuid: request.Id}, nil
+		}
+		return nil, errors.New("container doesn't exist")
+	}
+	if err := s.stopContainer(ctx, c, time.Duration(request.Timeout)*time.Second); err != nil {
+		return nil, err
+	}
+	return &api.ContainerResponse{Uuid: request.Id}, nil
+}
+
+// stopContainer sends the stop signal to a container and kills it if it doesn't exit in time.
+func (s *server) stopContainer(ctx context.Context, c *Container, timeout time.Duration) error {
+	s.markStopped(c)
+	done := s.doneChan(c)
+	signal := syscall.SIGTERM
+	if c.Spec.StopSignal != "" {
+		var err error
+		if signal, err = parseSignal(c.Spec.StopSignal); err != nil {
+			return err
+		}
+	}
+	if err := syscall.Kill(c.Pid, signal); err != nil {
+		return fmt.Errorf("cannot send %s to container %s: %w", unix.SignalName(signal), c.Id.String(), err)
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	log.Printf("container %s didn't stop in %s, killing it", c.Id.String(), timeout)
+
+	if eventChan, ok := s.getEventChan(c.Id); ok {
+		s.killContainer(c, eventChan, []byte(c.Id.String()))
+	}
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return nil
+}
@@ -8,8 +8,11 @@ import (
 	"github.com/google/uuid"
 	"log"
 	"net"
+	"time"
 )
 
+const connectionTimeout = 2 * time.Second
+
 func (s *server) acceptStreamConnections(listener net.Listener) {
 	for {
 		accept, err := listener.Accept()
@@ -70,10 +73,18 @@ func (s *server) RequestStream(streamServer api.Api_RequestStreamServer) error {
 			return err
 		}
 
-		containerId, err := uuid.FromBytes(recv.Id)
+		streamId, err := uuid.FromBytes(recv.Id) // container or exec session ID
 		if err != nil {
 			return err
 		}
+		containerId, ok := s.getStreamOwner(streamId)
+		if !ok {
+			return fmt.Errorf("container or exec session %s doesn't exist", streamId.String())
+		}
+
+		if !s.waitForConnection(clientID) {
+			return fmt.Errorf("no client with ID %s currently streaming", clientID.String())
+		}
 
 		if err = s.updateContainer(containerId, func(c *Container) error {
 			s.connectionsMutex.RLock()
@@ -86,12 +97,19 @@ func (s *server) RequestStream(streamServer api.Api_RequestStreamServer) error {
 			stream.ContainerID = containerId
 
 			c.Streamers[clientID] = stream
+			if streamId == containerId {
+				c.attach.Do(func() { close(c.attached) })
+			}
+			if session, ok := c.execs[streamId]; ok {
+				session.clients = append(session.clients, clientID)
+				session.attach.Do(func() { close(session.attached) })
+			}
 			return nil
 		}); err != nil {
 			return fmt.Errorf("cannot update container: %v", err)
 		}
 
-		stdinId, stdoutId, stderrId := s.ContainerStreamIDs(containerId)
+		stdinId, stdoutId, stderrId := s.ContainerStreamIDs(streamId)
 
 		if err = streamServer.Send(&api.StreamResponse{
 			InId:  stdinId,
@@ -103,6 +121,20 @@ func (s *server) RequestStream(streamServer api.Api_RequestStreamServer) error {
 	}
 }
 
+// waitForConnection waits for the streaming connection of a client, stream requests can arrive first.
+func (s *server) waitForConnection(clientID uuid.UUID) bool {
+	deadline := time.Now().Add(connectionTimeout)
+	for {
+		s.connectionsMutex.RLock()
+		_, ok := s.connections[clientID]
+		s.connectionsMutex.RUnlock()
+		if ok || time.Now().After(deadline) {
+			return ok
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
 func (s *server) ContainerStreamIDs(containerId uuid.UUID) (string, string, string) {
 	cIDString := containerId.String()
 	stdinId := cIDString + "-0"
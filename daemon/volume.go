@@ -0,0 +1,105 @@
+package daemon
+
+import (
+	"cont/api"
+	"cont/container"
+	"cont/volume"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"path/filepath"
+)
+
+func (s *server) VolumeCreate(ctx context.Context, request *api.VolumeRequest) (*api.Volume, error) {
+	v, err := s.volumes.Create(request.Name)
+	if err != nil {
+		return nil, err
+	}
+	return volumeToApi(v), nil
+}
+
+func (s *server) VolumeLs(ctx context.Context, empty *api.Empty) (*api.Volumes, error) {
+	volumes, err := s.volumes.List()
+	if err != nil {
+		return nil, err
+	}
+	return volumesToApi(volumes), nil
+}
+
+func (s *server) VolumeInspect(ctx context.Context, request *api.VolumeRequest) (*api.Volume, error) {
+	v, err := s.volumes.Get(request.Name)
+	if err != nil {
+		return nil, err
+	}
+	return volumeToApi(v), nil
+}
+
+func (s *server) VolumeRm(ctx context.Context, request *api.VolumeRequest) (*api.Empty, error) {
+	return &api.Empty{}, s.volumes.Remove(request.Name)
+}
+
+func (s *server) VolumePrune(ctx context.Context, empty *api.Empty) (*api.Volumes, error) {
+	removed, err := s.volumes.Prune()
+	if err != nil {
+		return nil, err
+	}
+	return volumesToApi(removed), nil
+}
+
+// setupMounts validates container mounts and acquires their named volumes, it returns the volume names.
+func (s *server) setupMounts(id uuid.UUID, request *api.ContainerRequest) ([]container.Mount, []string, error) {
+	mounts := make([]container.Mount, 0, len(request.Mounts))
+	volumes := make([]string, 0)
+	for _, m := range request.Mounts {
+		if !filepath.IsAbs(m.Destination) {
+			s.releaseVolumes(id, volumes)
+			return nil, nil, fmt.Errorf("mount destination %s is not an absolute path", m.Destination)
+		}
+		source := m.Source
+		if !filepath.IsAbs(source) {
+			if !volume.IsName(source) {
+				s.releaseVolumes(id, volumes)
+				return nil, nil, fmt.Errorf("mount source %s is neither an absolute path nor a volume name", source)
+			}
+			v, err := s.volumes.Acquire(source, id.String())
+			if err != nil {
+				s.releaseVolumes(id, volumes)
+				return nil, nil, err
+			}
+			volumes = append(volumes, v.Name)
+			source = v.Path
+		}
+		mounts = append(mounts, container.Mount{
+			Source:      source,
+			Destination: m.Destination,
+			ReadOnly:    m.ReadOnly,
+		})
+	}
+	return mounts, volumes, nil
+}
+
+func (s *server) releaseVolumes(id uuid.UUID, volumes []string) {
+	for _, name := range volumes {
+		if err := s.volumes.Release(name, id.String()); err != nil {
+			log.Printf("cannot release volume %s for container %s: %v", name, id.String(), err)
+		}
+	}
+}
+
+func volumeToApi(v *volume.Volume) *api.Volume {
+	return &api.Volume{
+		Name:       v.Name,
+		Path:       v.Path,
+		Created:    v.Created.Unix(),
+		Containers: v.Containers,
+	}
+}
+
+func volumesToApi(volumes []*volume.Volume) *api.Volumes {
+	result := &api.Volumes{Volumes: make([]*api.Volume, 0, len(volumes))}
+	for _, v := range volumes {
+		result.Volumes = append(result.Volumes, volumeToApi(v))
+	}
+	return result
+}
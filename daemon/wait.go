@@ -0,0 +1,35 @@
+package daemon
+
+import (
+	"cont/api"
+	"context"
+	"errors"
+	"github.com/google/uuid"
+)
+
+func (s *server) Wait(ctx context.Context, request *api.WaitRequest) (*api.ExitStatus, error) {
+	id, err := uuid.ParseBytes(request.Id)
+	if err != nil {
+		return nil, err
+	}
+	c, ok := s.findContainer(id)
+	if !ok {
+		return nil, errors.New("container doesn't exist")
+	}
+
+	select {
+	case <-s.doneChan(c):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	s.currentlyRunningMutex.RLock()
+	defer s.currentlyRunningMutex.RUnlock()
+	return &api.ExitStatus{ExitCode: int32(c.ExitCode), Signal: c.Signal}, nil
+}
+
+// doneChan returns the channel closed once the current run of a container exits, restarts replace it.
+func (s *server) doneChan(c *Container) <-chan struct{} {
+	s.currentlyRunningMutex.RLock()
+	defer s.currentlyRunningMutex.RUnlock()
+	return c.done
+}
@@ -0,0 +1,44 @@
+package daemon
+
+import (
+	"cont/api"
+	"context"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestWait(t *testing.T) {
+	id := uuid.New()
+	c := &Container{Id: id, ExitCode: -1, done: make(chan struct{})}
+	s := &server{currentlyRunning: map[uuid.UUID]*Container{id: c}, exited: map[uuid.UUID]*Container{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if status, err := s.Wait(ctx, &api.WaitRequest{Id: []byte(id.String())}); err != context.DeadlineExceeded {
+		t.Errorf("Wait of a running container = %v, %v, expected a timeout", status, err)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.currentlyRunningMutex.Lock()
+		c.ExitCode, c.Signal = 137, "SIGKILL"
+		delete(s.currentlyRunning, id)
+		s.exited[id] = c
+		close(c.done)
+		s.currentlyRunningMutex.Unlock()
+	}()
+	status, err := s.Wait(context.Background(), &api.WaitRequest{Id: []byte(id.String())})
+	if err != nil || status.ExitCode != 137 || status.Signal != "SIGKILL" {
+		t.Errorf("Wait = %v, %v, expected exit code 137 with SIGKILL", status, err)
+	}
+	// exited containers are waited for immediately
+	if status, err := s.Wait(context.Background(), &api.WaitRequest{Id: []byte(id.String())}); err != nil ||
+		status.ExitCode != 137 {
+		t.Errorf("Wait of an exited container = %v, %v, expected exit code 137", status, err)
+	}
+
+	if _, err := s.Wait(context.Background(), &api.WaitRequest{Id: []byte(uuid.New().String())}); err == nil {
+		t.Error("Wait of a missing container didn't fail")
+	}
+}
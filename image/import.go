@@ -0,0 +1,364 @@
+package image
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+const (
+	ociLayoutFile      = "oci-layout"
+	ociIndexFile       = "index.json"
+	dockerManifestFile = "manifest.json"
+
+	ociIndexMediaType    = "application/vnd.oci.image.index.v1+json"
+	dockerListMediaType  = "application/vnd.docker.distribution.manifest.list.v2+json"
+	ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+)
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+type ociIndex struct {
+	Manifests []descriptor `json:"manifests"`
+}
+
+type ociManifest struct {
+	Config descriptor   `json:"config"`
+	Layers []descriptor `json:"layers"`
+}
+
+type dockerManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+type imageConfig struct {
+	Created time.Time `json:"created"`
+	Config  Config    `json:"config"`
+	Rootfs  struct {
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+}
+
+// blob is a file of an image layout with its expected digest (empty if the layout doesn't specify one).
+type blob struct {
+	path, digest string
+}
+
+// layout is an image found in an OCI image layout or a docker save directory.
+type layout struct {
+	names    []string
+	manifest *blob // nil for docker save archives
+	config   blob
+	layers   []blob
+}
+
+// Import imports an OCI image layout or a docker save archive (a directory or a tarball), tagged with name if it isn't
+// empty.
+func (s *Store) Import(path, name string) (*Image, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	dir := path
+	if !info.IsDir() {
+		dir, err = ioutil.TempDir(filepath.Join(s.root, tmpDir), "import-")
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		if err := unpackArchive(path, dir); err != nil {
+			return nil, fmt.Errorf("cannot unpack image archive: %w", err)
+		}
+	}
+
+	l, err := readLayout(dir)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		l.names = append(l.names, name)
+	}
+	for i, n := range l.names {
+		l.names[i] = normalizeName(n)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.importLayout(l)
+}
+
+func (s *Store) importLayout(l *layout) (*Image, error) {
+	configDigest, err := s.addBlob(l.config.path, l.config.digest)
+	if err != nil {
+		return nil, fmt.Errorf("cannot import image config: %w", err)
+	}
+	config, err := readConfig(s.blobPath(configDigest))
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Rootfs.DiffIDs) != 0 && len(config.Rootfs.DiffIDs) != len(l.layers) {
+		return nil, fmt.Errorf("image config lists %d layers, manifest %d", len(config.Rootfs.DiffIDs), len(l.layers))
+	}
+
+	img, err := s.load(configDigest)
+	if err == nil { // already imported, only update names
+		for _, n := range l.names {
+			if !contains(img.Names, n) {
+				img.Names = append(img.Names, n)
+			}
+		}
+		if err := s.takeNames(img.ID, img.Names); err != nil {
+			return nil, err
+		}
+		return img, s.save(img)
+	}
+
+	img = &Image{
+		ID:      configDigest,
+		Names:   l.names,
+		Blobs:   []string{configDigest},
+		Created: config.Created,
+		Config:  config.Config,
+	}
+	if l.manifest != nil {
+		digest, err := s.addBlob(l.manifest.path, l.manifest.digest)
+		if err != nil {
+			return nil, fmt.Errorf("cannot import image manifest: %w", err)
+		}
+		img.Blobs = append(img.Blobs, digest)
+	}
+
+	dir := s.imageDir(img.ID)
+	if err := os.MkdirAll(dir, imageDirPerms); err != nil {
+		return nil, err
+	}
+	if err := s.unpackLayers(img, l, config); err != nil {
+		_ = os.RemoveAll(dir)
+		_ = s.collect()
+		return nil, err
+	}
+	if err := s.takeNames(img.ID, img.Names); err != nil {
+		return nil, err
+	}
+	if err := s.save(img); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	return img, nil
+}
+
+func (s *Store) unpackLayers(img *Image, l *layout, config *imageConfig) error {
+	for i, layer := range l.layers {
+		digest, err := s.addBlob(layer.path, layer.digest)
+		if err != nil {
+			return fmt.Errorf("cannot import layer %d: %w", i, err)
+		}
+		img.Blobs = append(img.Blobs, digest)
+		img.LayerBlobs = append(img.LayerBlobs, digest)
+		if info, err := os.Stat(s.blobPath(digest)); err == nil {
+			img.Size += info.Size()
+		}
+
+		var expected string
+		if len(config.Rootfs.DiffIDs) != 0 {
+			expected = config.Rootfs.DiffIDs[i]
+			if _, err := os.Stat(s.layerDir(expected)); err == nil {
+				img.Layers = append(img.Layers, expected) // shared with another image
+				continue
+			}
+		}
+		diffID, err := s.unpackLayer(digest, expected)
+		if err != nil {
+			return fmt.Errorf("cannot unpack layer %s: %w", digest, err)
+		}
+		img.Layers = append(img.Layers, diffID)
+	}
+	return nil
+}
+
+// unpackLayer unpacks a layer blob into its own layer directory and returns its diff ID.
+func (s *Store) unpackLayer(digest, expected string) (string, error) {
+	file, err := os.Open(s.blobPath(digest))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	dir, err := ioutil.TempDir(filepath.Join(s.root, tmpDir), "layer-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Chmod(dir, 0755); err != nil { // the layer root becomes the container root
+		return "", err
+	}
+
+	diffID, err := applyLayer(file, dir, layerFormat())
+	if err != nil {
+		return "", err
+	}
+	if expected != "" && diffID != expected {
+		return "", fmt.Errorf("diff ID mismatch: expected %s, got %s", expected, diffID)
+	}
+	if _, err := os.Stat(s.layerDir(diffID)); err == nil {
+		return diffID, nil
+	}
+	return diffID, os.Rename(dir, s.layerDir(diffID))
+}
+
+// readLayout reads an OCI image layout, falling back to the docker save format.
+func readLayout(dir string) (*layout, error) {
+	if _, err := os.Stat(filepath.Join(dir, ociLayoutFile)); err == nil {
+		return readOCILayout(dir)
+	}
+	if _, err := os.Stat(filepath.Join(dir, dockerManifestFile)); err == nil {
+		return readDockerLayout(dir)
+	}
+	return nil, errors.New("neither an OCI image layout nor a docker save archive")
+}
+
+func readOCILayout(dir string) (*layout, error) {
+	var index ociIndex
+	if err := readJSON(filepath.Join(dir, ociIndexFile), &index); err != nil {
+		return nil, err
+	}
+	desc, err := selectManifest(dir, index)
+	if err != nil {
+		return nil, err
+	}
+	manifestBlob, err := ociBlob(dir, desc.Digest)
+	if err != nil {
+		return nil, err
+	}
+	var manifest ociManifest
+	if err := readJSON(manifestBlob.path, &manifest); err != nil {
+		return nil, err
+	}
+
+	result := &layout{manifest: &manifestBlob}
+	if ref := desc.Annotations[ociRefNameAnnotation]; ref != "" {
+		result.names = append(result.names, ref)
+	}
+	if result.config, err = ociBlob(dir, manifest.Config.Digest); err != nil {
+		return nil, err
+	}
+	for _, layer := range manifest.Layers {
+		b, err := ociBlob(dir, layer.Digest)
+		if err != nil {
+			return nil, err
+		}
+		result.layers = append(result.layers, b)
+	}
+	return result, nil
+}
+
+// selectManifest picks the image manifest for the current platform, resolving nested indexes.
+func selectManifest(dir string, index ociIndex) (descriptor, error) {
+	for _, desc := range index.Manifests {
+		if desc.Platform != nil && (desc.Platform.OS != runtime.GOOS || desc.Platform.Architecture != runtime.GOARCH) {
+			continue
+		}
+		if desc.MediaType != ociIndexMediaType && desc.MediaType != dockerListMediaType {
+			return desc, nil
+		}
+		b, err := ociBlob(dir, desc.Digest)
+		if err != nil {
+			return desc, err
+		}
+		var nested ociIndex
+		if err := readJSON(b.path, &nested); err != nil {
+			return desc, err
+		}
+		result, err := selectManifest(dir, nested)
+		if err != nil {
+			return desc, err
+		}
+		if result.Annotations == nil {
+			result.Annotations = desc.Annotations
+		}
+		return result, nil
+	}
+	return descriptor{}, fmt.Errorf("no image manifest for %s/%s", runtime.GOOS, runtime.GOARCH)
+}
+
+func readDockerLayout(dir string) (*layout, error) {
+	var manifests []dockerManifest
+	if err := readJSON(filepath.Join(dir, dockerManifestFile), &manifests); err != nil {
+		return nil, err
+	}
+	if len(manifests) != 1 {
+		return nil, fmt.Errorf("expected a single image in the archive, found %d", len(manifests))
+	}
+	manifest := manifests[0]
+
+	result := &layout{names: manifest.RepoTags}
+	result.config = dockerBlob(dir, manifest.Config)
+	for _, layer := range manifest.Layers {
+		result.layers = append(result.layers, dockerBlob(dir, layer))
+	}
+	return result, nil
+}
+
+func ociBlob(dir, digest string) (blob, error) {
+	if !strings.HasPrefix(digest, digestPrefix) {
+		return blob{}, fmt.Errorf("unsupported digest %s", digest)
+	}
+	hexDigest := strings.TrimPrefix(digest, digestPrefix)
+	if strings.ContainsAny(hexDigest, "/.") {
+		return blob{}, fmt.Errorf("invalid digest %s", digest)
+	}
+	return blob{path: filepath.Join(dir, "blobs", "sha256", hexDigest), digest: digest}, nil
+}
+
+// dockerBlob resolves a docker save path, the digest is known for files named after it.
+func dockerBlob(dir, name string) blob {
+	clean := filepath.Clean("/" + name)
+	b := blob{path: filepath.Join(dir, clean)}
+
+	base := filepath.Base(clean)
+	switch {
+	case strings.HasPrefix(clean, "/blobs/sha256/"):
+		b.digest = digestPrefix + base
+	case strings.HasSuffix(base, ".json"):
+		b.digest = digestPrefix + strings.TrimSuffix(base, ".json")
+	}
+	return b
+}
+
+func readConfig(path string) (*imageConfig, error) {
+	var config imageConfig
+	if err := readJSON(path, &config); err != nil {
+		return nil, fmt.Errorf("cannot read image config: %w", err)
+	}
+	return &config, nil
+}
+
+func readJSON(path string, v interface{}) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := json.NewDecoder(file).Decode(v); err != nil {
+		return fmt.Errorf("cannot decode %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
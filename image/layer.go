@@ -0,0 +1,291 @@
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = ".wh..wh..opq"
+	opaqueXattr    = "trusted.overlay.opaque"
+)
+
+// whiteoutFormat determines what happens with layer whiteouts while unpacking.
+type whiteoutFormat int
+
+const (
+	applyWhiteouts   whiteoutFormat = iota // remove whited out files, flattening the layer onto root
+	overlayWhiteouts                       // convert whiteouts to overlayfs character devices and opaque xattrs
+	keepWhiteouts                          // keep .wh. files as they are, fuse-overlayfs understands them
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// layerFormat returns the whiteout format unpacked layers are kept in. Only root can create overlayfs whiteouts.
+func layerFormat() whiteoutFormat {
+	if os.Geteuid() == 0 {
+		return overlayWhiteouts
+	}
+	return keepWhiteouts
+}
+
+// applyLayer unpacks a (possibly gzip compressed) layer onto root and returns its uncompressed digest.
+func applyLayer(r io.Reader, root string, format whiteoutFormat) (string, error) {
+	stream, err := decompress(r)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	hashed := io.TeeReader(stream, hash)
+
+	written := make(map[string]bool) // paths written by this layer
+	tr := tar.NewReader(hashed)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		name := filepath.Clean("/" + header.Name)
+		dir, base := filepath.Split(name)
+
+		if strings.HasPrefix(base, whiteoutPrefix) && format != keepWhiteouts {
+			if err := applyWhiteout(root, dir, base, written, format); err != nil {
+				return "", fmt.Errorf("cannot apply whiteout %s: %w", name, err)
+			}
+			continue
+		}
+		if err := extractEntry(root, name, header, tr); err != nil {
+			return "", fmt.Errorf("cannot extract %s: %w", name, err)
+		}
+		written[name] = true
+	}
+	// the digest has to cover the whole stream, including the tar padding
+	if _, err := io.Copy(ioutil.Discard, hashed); err != nil {
+		return "", err
+	}
+	return digestPrefix + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// unpackArchive unpacks an image archive into dir.
+func unpackArchive(path, dir string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = applyLayer(file, dir, applyWhiteouts)
+	return err
+}
+
+func applyWhiteout(root, dir, base string, written map[string]bool, format whiteoutFormat) error {
+	if base == opaqueWhiteout {
+		if format == overlayWhiteouts {
+			path, err := securePath(root, dir)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			return unix.Setxattr(path, opaqueXattr, []byte("y"), 0)
+		}
+		return clearDir(root, dir, written)
+	}
+	path, err := securePath(root, filepath.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(path); err != nil {
+		return err
+	}
+	if format == overlayWhiteouts {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		return unix.Mknod(path, unix.S_IFCHR, 0)
+	}
+	return nil
+}
+
+func decompress(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if bytes.Equal(magic, gzipMagic) {
+		return gzip.NewReader(buffered)
+	}
+	return buffered, nil
+}
+
+// clearDir removes everything from a directory this layer didn't write (opaque whiteout).
+func clearDir(root, dir string, written map[string]bool) error {
+	path, err := securePath(root, dir)
+	if err != nil {
+		return err
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		if written[filepath.Join(dir, entry.Name())] {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(path, entry.Name())); err != nil {
+			return fmt.Errorf("cannot apply opaque whiteout in %s: %w", dir, err)
+		}
+	}
+	return nil
+}
+
+func extractEntry(root, name string, header *tar.Header, r io.Reader) error {
+	path, err := securePath(root, name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	mode := header.FileInfo().Mode() & (os.ModePerm | os.ModeSetuid | os.ModeSetgid | os.ModeSticky)
+	isRoot := os.Geteuid() == 0
+
+	switch header.Typeflag {
+	case tar.TypeDir:
+		if info, err := os.Lstat(path); err == nil && !info.IsDir() {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+		}
+		if !isRoot {
+			mode |= 0700 // we have to be able to write into the directory and remove it later
+		}
+		if err := os.MkdirAll(path, mode.Perm()); err != nil {
+			return err
+		}
+	case tar.TypeReg, tar.TypeRegA:
+		if err := removeExisting(path); err != nil {
+			return err
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(file, r)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	case tar.TypeSymlink:
+		if err := removeExisting(path); err != nil {
+			return err
+		}
+		if err := os.Symlink(header.Linkname, path); err != nil {
+			return err
+		}
+		return chown(path, header)
+	case tar.TypeLink:
+		target, err := securePath(root, header.Linkname)
+		if err != nil {
+			return err
+		}
+		if err := removeExisting(path); err != nil {
+			return err
+		}
+		return os.Link(target, path)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		if err := removeExisting(path); err != nil {
+			return err
+		}
+		if err := mknod(path, header); err != nil {
+			if !isRoot {
+				log.Printf("skipping device %s in rootless mode: %v", name, err)
+				return nil
+			}
+			return err
+		}
+	default:
+		log.Printf("skipping unsupported tar entry %s (type %c)", name, header.Typeflag)
+		return nil
+	}
+
+	if err := chown(path, header); err != nil {
+		return err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		return err
+	}
+	return os.Chtimes(path, header.AccessTime, header.ModTime)
+}
+
+func mknod(path string, header *tar.Header) error {
+	mode := uint32(header.Mode & 07777)
+	switch header.Typeflag {
+	case tar.TypeChar:
+		mode |= unix.S_IFCHR
+	case tar.TypeBlock:
+		mode |= unix.S_IFBLK
+	case tar.TypeFifo:
+		mode |= unix.S_IFIFO
+	}
+	return unix.Mknod(path, mode, int(unix.Mkdev(uint32(header.Devmajor), uint32(header.Devminor))))
+}
+
+// chown changes file ownership. Ownership errors are ignored in rootless mode.
+func chown(path string, header *tar.Header) error {
+	if err := os.Lchown(path, header.Uid, header.Gid); err != nil && os.Geteuid() == 0 {
+		return err
+	}
+	return nil
+}
+
+func removeExisting(path string) error {
+	if _, err := os.Lstat(path); err != nil {
+		return nil
+	}
+	return os.RemoveAll(path)
+}
+
+// securePath joins name to root, making sure the result can't escape root through .. or symlinked parents.
+func securePath(root, name string) (string, error) {
+	name = filepath.Clean("/" + name)
+	if name == "/" {
+		return root, nil
+	}
+	parts := strings.Split(name[1:], "/")
+	path := root
+	for _, part := range parts[:len(parts)-1] {
+		path = filepath.Join(path, part)
+		info, err := os.Lstat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+			return "", err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("%s has a symlinked parent directory", name)
+		}
+	}
+	return filepath.Join(root, name), nil
+}
@@ -0,0 +1,235 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+type entry struct {
+	name     string
+	typeflag byte
+	linkname string
+	content  string
+}
+
+func layerTar(t *testing.T, entries ...entry) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	tw := tar.NewWriter(&buffer)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Typeflag: e.typeflag, Linkname: e.linkname, Mode: 0644,
+			Size: int64(len(e.content))}
+		if e.typeflag == tar.TypeDir {
+			header.Mode = 0755
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// files returns the paths of all files under root.
+func files(t *testing.T, root string) []string {
+	t.Helper()
+	var result []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != root {
+			rel, _ := filepath.Rel(root, path)
+			result = append(result, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func lowerLayer(t *testing.T) string {
+	t.Helper()
+	root, err := ioutil.TempDir("", "layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lower := layerTar(t,
+		entry{name: "etc/", typeflag: tar.TypeDir},
+		entry{name: "etc/passwd", typeflag: tar.TypeReg},
+		entry{name: "etc/shadow", typeflag: tar.TypeReg},
+		entry{name: "cache/", typeflag: tar.TypeDir},
+		entry{name: "cache/old", typeflag: tar.TypeReg},
+		entry{name: "cache/stale/", typeflag: tar.TypeDir},
+	)
+	if _, err := applyLayer(bytes.NewReader(lower), root, applyWhiteouts); err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestApplyLayerWhiteouts(t *testing.T) {
+	root := lowerLayer(t)
+	defer os.RemoveAll(root)
+
+	upper := layerTar(t,
+		entry{name: "etc/.wh.shadow", typeflag: tar.TypeReg},
+		entry{name: "cache/", typeflag: tar.TypeDir},
+		entry{name: "cache/new", typeflag: tar.TypeReg}, // written before the opaque whiteout, it stays
+		entry{name: "cache/.wh..wh..opq", typeflag: tar.TypeReg},
+		entry{name: ".wh.missing", typeflag: tar.TypeReg},
+	)
+	if _, err := applyLayer(bytes.NewReader(upper), root, applyWhiteouts); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"cache", "cache/new", "etc", "etc/passwd"}
+	if actual := files(t, root); !equal(actual, expected) {
+		t.Errorf("files after applying whiteouts = %v, expected %v", actual, expected)
+	}
+}
+
+func TestApplyLayerKeepWhiteouts(t *testing.T) {
+	root := lowerLayer(t)
+	defer os.RemoveAll(root)
+
+	upper := layerTar(t,
+		entry{name: "etc/.wh.shadow", typeflag: tar.TypeReg},
+		entry{name: "cache/.wh..wh..opq", typeflag: tar.TypeReg},
+	)
+	if _, err := applyLayer(bytes.NewReader(upper), root, keepWhiteouts); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"cache", "cache/.wh..wh..opq", "cache/old", "cache/stale", "etc", "etc/.wh.shadow",
+		"etc/passwd", "etc/shadow"}
+	if actual := files(t, root); !equal(actual, expected) {
+		t.Errorf("files after keeping whiteouts = %v, expected %v", actual, expected)
+	}
+}
+
+func TestApplyLayerOverlayWhiteouts(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("overlayfs whiteouts can only be created by root")
+	}
+	root := lowerLayer(t)
+	defer os.RemoveAll(root)
+
+	upper := layerTar(t,
+		entry{name: "etc/.wh.shadow", typeflag: tar.TypeReg},
+		entry{name: "cache/.wh..wh..opq", typeflag: tar.TypeReg},
+	)
+	if _, err := applyLayer(bytes.NewReader(upper), root, overlayWhiteouts); err != nil {
+		t.Fatal(err)
+	}
+	var stat unix.Stat_t
+	if err := unix.Lstat(filepath.Join(root, "etc/shadow"), &stat); err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode&unix.S_IFMT != unix.S_IFCHR || stat.Rdev != 0 {
+		t.Errorf("whiteout mode = %o, rdev = %d, expected a 0:0 character device", stat.Mode, stat.Rdev)
+	}
+	value := make([]byte, 1)
+	if n, err := unix.Getxattr(filepath.Join(root, "cache"), opaqueXattr, value); err != nil || string(value[:n]) != "y" {
+		t.Errorf("opaque xattr = %q, %v, expected y", value[:n], err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "cache/old")); err != nil {
+		t.Errorf("opaque directory contents were removed: %v", err)
+	}
+}
+
+func TestApplyLayerDigest(t *testing.T) {
+	layer := layerTar(t, entry{name: "file", typeflag: tar.TypeReg})
+	sum := sha256.Sum256(layer)
+	expected := digestPrefix + hex.EncodeToString(sum[:])
+
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	if _, err := gz.Write(layer); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{"uncompressed": layer, "gzip": compressed.Bytes()} {
+		root, err := ioutil.TempDir("", "layer")
+		if err != nil {
+			t.Fatal(err)
+		}
+		digest, err := applyLayer(bytes.NewReader(data), root, applyWhiteouts)
+		os.RemoveAll(root)
+		if err != nil {
+			t.Errorf("%s layer: %v", name, err)
+		} else if digest != expected {
+			t.Errorf("%s layer digest = %s, expected the digest of the tarball %s", name, digest, expected)
+		}
+	}
+}
+
+func TestSecurePath(t *testing.T) {
+	root, err := ioutil.TempDir("", "layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err := os.Mkdir(filepath.Join(root, "usr"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/etc", filepath.Join(root, "etc")); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, expected := range map[string]string{
+		"":                  root,
+		"/":                 root,
+		"usr/bin/sh":        filepath.Join(root, "usr/bin/sh"),
+		"../../usr/lib":     filepath.Join(root, "usr/lib"),
+		"usr/../../../tmp":  filepath.Join(root, "tmp"),
+		"etc":               filepath.Join(root, "etc"), // the symlink itself can be replaced
+		"missing/dir/entry": filepath.Join(root, "missing/dir/entry"),
+	} {
+		if path, err := securePath(root, name); err != nil || path != expected {
+			t.Errorf("securePath(%q) = %s, %v, expected %s", name, path, err, expected)
+		}
+	}
+	if path, err := securePath(root, "etc/passwd"); err == nil {
+		t.Errorf("securePath through a symlink = %s, expected an error", path)
+	}
+
+	// a layer can't write through a symlink it created itself
+	escape := layerTar(t,
+		entry{name: "link", typeflag: tar.TypeSymlink, linkname: "/tmp"},
+		entry{name: "link/file", typeflag: tar.TypeReg},
+	)
+	if _, err := applyLayer(bytes.NewReader(escape), root, applyWhiteouts); err == nil {
+		t.Error("applyLayer wrote through a symlink")
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
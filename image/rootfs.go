@@ -0,0 +1,119 @@
+package image
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	overlayDriver = "overlay"
+	fuseDriver    = "fuse-overlayfs"
+	copyDriver    = "copy"
+)
+
+// Rootfs is a copy-on-write container root filesystem created from an image.
+type Rootfs struct {
+	Path   string `json:"path"`  // directory the container should use as its root
+	Image  string `json:"image"` // ID of the image the rootfs was created from
+	Driver string `json:"driver"`
+	Dir    string `json:"dir"` // directory with container changes, removed on Unmount
+}
+
+// Mount creates a copy-on-write container root filesystem from an image in dir. It falls back from overlayfs to
+// fuse-overlayfs and to a plain copy.
+func (s *Store) Mount(img *Image, dir string) (*Rootfs, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	upper, work, merged := filepath.Join(dir, "upper"), filepath.Join(dir, "work"), filepath.Join(dir, "merged")
+	for _, d := range []string{upper, work, merged} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			return nil, fmt.Errorf("cannot create rootfs directory: %w", err)
+		}
+	}
+
+	lower := make([]string, 0, len(img.Layers))
+	for i := len(img.Layers) - 1; i >= 0; i-- { // overlayfs expects the topmost layer first
+		lower = append(lower, s.layerDir(img.Layers[i]))
+	}
+	if len(lower) == 0 {
+		empty := filepath.Join(dir, "empty")
+		if err := os.MkdirAll(empty, 0755); err != nil {
+			return nil, err
+		}
+		lower = append(lower, empty)
+	}
+	options := fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", strings.Join(lower, ":"), upper, work)
+
+	err := unix.Mount("overlay", merged, "overlay", 0, options)
+	if err == nil {
+		return &Rootfs{Path: merged, Image: img.ID, Driver: overlayDriver, Dir: dir}, nil
+	}
+	log.Printf("cannot mount overlayfs, trying %s: %v", fuseDriver, err)
+
+	if fuse, err := exec.LookPath(fuseDriver); err == nil {
+		out, err := exec.Command(fuse, "-o", options, merged).CombinedOutput()
+		if err == nil {
+			return &Rootfs{Path: merged, Image: img.ID, Driver: fuseDriver, Dir: dir}, nil
+		}
+		log.Printf("cannot mount %s, copying the image: %v: %s", fuseDriver, err, out)
+	}
+
+	rootfs := filepath.Join(dir, "rootfs")
+	if err := s.unpack(img, rootfs); err != nil {
+		_ = os.RemoveAll(rootfs)
+		return nil, fmt.Errorf("cannot copy image: %w", err)
+	}
+	return &Rootfs{Path: rootfs, Image: img.ID, Driver: copyDriver, Dir: dir}, nil
+}
+
+// Unmount unmounts the root filesystem and removes all container changes.
+func (r *Rootfs) Unmount() error {
+	switch r.Driver {
+	case overlayDriver:
+		if err := unix.Unmount(r.Path, unix.MNT_DETACH); err != nil {
+			return fmt.Errorf("cannot unmount overlayfs: %w", err)
+		}
+	case fuseDriver:
+		if err := fuseUnmount(r.Path); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(r.Dir)
+}
+
+// unpack flattens all image layers into dir.
+func (s *Store) unpack(img *Image, dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	for _, digest := range img.LayerBlobs {
+		file, err := os.Open(s.blobPath(digest))
+		if err != nil {
+			return err
+		}
+		_, err = applyLayer(file, dir, applyWhiteouts)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("cannot apply layer %s: %w", digest, err)
+		}
+	}
+	return nil
+}
+
+func fuseUnmount(path string) error {
+	var err error
+	for _, fusermount := range []string{"fusermount3", "fusermount"} {
+		var out []byte
+		if out, err = exec.Command(fusermount, "-u", path).CombinedOutput(); err == nil {
+			return nil
+		}
+		err = fmt.Errorf("%s failed: %w: %s", fusermount, err, out)
+	}
+	return err
+}
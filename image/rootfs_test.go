@@ -0,0 +1,64 @@
+package image
+
+import (
+	"archive/tar"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMount(t *testing.T) {
+	store, dir := testStore(t)
+	defer os.RemoveAll(dir)
+
+	base := layerTar(t,
+		entry{name: "etc/", typeflag: tar.TypeDir},
+		entry{name: "etc/os-release", typeflag: tar.TypeReg, content: "base"},
+		entry{name: "etc/motd", typeflag: tar.TypeReg, content: "hello"},
+	)
+	upper := layerTar(t,
+		entry{name: "etc/.wh.motd", typeflag: tar.TypeReg},
+		entry{name: "etc/os-release", typeflag: tar.TypeReg, content: "app"},
+	)
+	layout := filepath.Join(dir, "layout")
+	ociLayout(t, layout, "app", Config{}, base, upper)
+	img, err := store.Import(layout, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// whichever driver works here, the container sees the flattened image and its writes don't change the image
+	for _, container := range []string{"first", "second"} {
+		rootfs, err := store.Mount(img, filepath.Join(dir, "containers", container))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if content, err := ioutil.ReadFile(filepath.Join(rootfs.Path, "etc/os-release")); err != nil ||
+			string(content) != "app" {
+			t.Errorf("%s rootfs /etc/os-release = %q, %v, expected the upper layer file", rootfs.Driver, content, err)
+		}
+		if _, err := os.Lstat(filepath.Join(rootfs.Path, "etc/motd")); !os.IsNotExist(err) {
+			t.Errorf("%s rootfs has a whited out file: %v", rootfs.Driver, err)
+		}
+		if _, err := os.Stat(filepath.Join(rootfs.Path, "etc/written")); !os.IsNotExist(err) {
+			t.Errorf("%s rootfs sees writes of another container: %v", rootfs.Driver, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(rootfs.Path, "etc/written"), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Remove(filepath.Join(rootfs.Path, "etc/os-release")); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := rootfs.Unmount(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(rootfs.Dir); !os.IsNotExist(err) {
+			t.Errorf("%s rootfs directory wasn't removed: %v", rootfs.Driver, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(store.layerDir(img.Layers[1]), "etc/os-release")); err != nil {
+		t.Errorf("container changes modified the image layer: %v", err)
+	}
+}
@@ -0,0 +1,312 @@
+package image
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	blobsDir      = "blobs"
+	layersDir     = "layers"
+	imagesDir     = "images"
+	tmpDir        = "tmp"
+	imageFile     = "image.json"
+	digestPrefix  = "sha256:"
+	defaultTag    = "latest"
+	imageDirPerms = 0700
+)
+
+var ErrNotFound = errors.New("image not found")
+
+// Config is the subset of the image runtime configuration cont uses.
+type Config struct {
+	User       string   `json:"User,omitempty"`
+	Env        []string `json:"Env,omitempty"`
+	Entrypoint []string `json:"Entrypoint,omitempty"`
+	Cmd        []string `json:"Cmd,omitempty"`
+	WorkingDir string   `json:"WorkingDir,omitempty"`
+}
+
+type Image struct {
+	ID         string    `json:"id"`         // config digest
+	Names      []string  `json:"names"`      // image references, e.g. alpine:latest
+	Layers     []string  `json:"layers"`     // uncompressed layer digests (diff IDs), lowest first
+	Blobs      []string  `json:"blobs"`      // digests of all blobs the image references
+	LayerBlobs []string  `json:"layerBlobs"` // layer tarball digests, in the same order as Layers
+	Size       int64     `json:"size"`
+	Created    time.Time `json:"created"`
+	Config     Config    `json:"config"`
+}
+
+// ShortID returns the first 12 hex characters of the image ID.
+func (i *Image) ShortID() string {
+	id := strings.TrimPrefix(i.ID, digestPrefix)
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// Store is a content addressed image store. Blobs are kept in blobs/sha256/<hex>, unpacked layers in
+// layers/<diff ID hex> and every image gets its own images/<hex> directory with metadata.
+type Store struct {
+	root  string
+	mutex sync.RWMutex
+}
+
+func NewStore(root string) (*Store, error) {
+	for _, dir := range []string{filepath.Join(root, blobsDir, "sha256"), filepath.Join(root, layersDir), filepath.Join(root, imagesDir), filepath.Join(root, tmpDir)} {
+		if err := os.MkdirAll(dir, imageDirPerms); err != nil {
+			return nil, fmt.Errorf("cannot create image store directory: %w", err)
+		}
+	}
+	return &Store{root: root}, nil
+}
+
+// TempFile creates a temporary file inside the store, on the same filesystem as the images.
+func (s *Store) TempFile() (*os.File, error) {
+	return ioutil.TempFile(filepath.Join(s.root, tmpDir), "file-")
+}
+
+func (s *Store) List() ([]*Image, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.list()
+}
+
+// Get finds an image by its name, ID or a unique ID prefix.
+func (s *Store) Get(ref string) (*Image, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.get(ref)
+}
+
+// Remove removes an image and all blobs and layers no other image references.
+func (s *Store) Remove(ref string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	img, err := s.get(ref)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(s.imageDir(img.ID)); err != nil {
+		return fmt.Errorf("cannot remove image %s: %w", img.ShortID(), err)
+	}
+	return s.collect()
+}
+
+func (s *Store) list() ([]*Image, error) {
+	dirs, err := ioutil.ReadDir(filepath.Join(s.root, imagesDir))
+	if err != nil {
+		return nil, err
+	}
+	images := make([]*Image, 0, len(dirs))
+	for _, dir := range dirs {
+		img, err := s.load(digestPrefix + dir.Name())
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // import in progress or a leftover from a failed one
+			}
+			return nil, err
+		}
+		images = append(images, img)
+	}
+	sort.Slice(images, func(i, j int) bool {
+		return images[i].Created.After(images[j].Created)
+	})
+	return images, nil
+}
+
+func (s *Store) get(ref string) (*Image, error) {
+	images, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	name := normalizeName(ref)
+	for _, img := range images {
+		for _, n := range img.Names {
+			if n == name {
+				return img, nil
+			}
+		}
+	}
+	id := strings.TrimPrefix(ref, digestPrefix)
+	var found *Image
+	for _, img := range images {
+		if strings.HasPrefix(strings.TrimPrefix(img.ID, digestPrefix), id) {
+			if found != nil {
+				return nil, fmt.Errorf("image reference %s is ambiguous", ref)
+			}
+			found = img
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, ref)
+	}
+	return found, nil
+}
+
+func (s *Store) load(id string) (*Image, error) {
+	file, err := os.Open(filepath.Join(s.imageDir(id), imageFile))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var img Image
+	if err := json.NewDecoder(file).Decode(&img); err != nil {
+		return nil, fmt.Errorf("cannot decode image %s: %w", id, err)
+	}
+	return &img, nil
+}
+
+func (s *Store) save(img *Image) error {
+	tmp, err := s.TempFile()
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(img); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(s.imageDir(img.ID), imageFile))
+}
+
+// takeNames removes names from all images except the one with the given ID.
+func (s *Store) takeNames(id string, names []string) error {
+	images, err := s.list()
+	if err != nil {
+		return err
+	}
+	for _, img := range images {
+		if img.ID == id {
+			continue
+		}
+		kept := img.Names[:0]
+		for _, n := range img.Names {
+			if !contains(names, n) {
+				kept = append(kept, n)
+			}
+		}
+		if len(kept) == len(img.Names) {
+			continue
+		}
+		img.Names = kept
+		if err := s.save(img); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collect removes blobs and layers no image references.
+func (s *Store) collect() error {
+	images, err := s.list()
+	if err != nil {
+		return err
+	}
+	referenced := make(map[string]bool)
+	for _, img := range images {
+		for _, digest := range append(img.Blobs, img.Layers...) {
+			referenced[strings.TrimPrefix(digest, digestPrefix)] = true
+		}
+	}
+	for _, dir := range []string{filepath.Join(s.root, blobsDir, "sha256"), filepath.Join(s.root, layersDir)} {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if referenced[entry.Name()] {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				return fmt.Errorf("cannot remove %s: %w", entry.Name(), err)
+			}
+		}
+	}
+	return nil
+}
+
+// addBlob copies a blob into the store and returns its digest. If expected isn't empty the blob has to match it.
+func (s *Store) addBlob(path, expected string) (string, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	tmp, err := s.TempFile()
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), src); err != nil {
+		return "", fmt.Errorf("cannot copy blob: %w", err)
+	}
+	digest := digestPrefix + hex.EncodeToString(hash.Sum(nil))
+	if expected != "" && digest != expected {
+		return "", fmt.Errorf("digest mismatch for %s: expected %s, got %s", filepath.Base(path), expected, digest)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), s.blobPath(digest)); err != nil {
+		return "", err
+	}
+	return digest, nil
+}
+
+func (s *Store) blobPath(digest string) string {
+	return filepath.Join(s.root, blobsDir, "sha256", strings.TrimPrefix(digest, digestPrefix))
+}
+
+func (s *Store) layerDir(diffID string) string {
+	return filepath.Join(s.root, layersDir, strings.TrimPrefix(diffID, digestPrefix))
+}
+
+func (s *Store) imageDir(id string) string {
+	return filepath.Join(s.root, imagesDir, strings.TrimPrefix(id, digestPrefix))
+}
+
+// normalizeName adds the default tag to image names without one.
+func normalizeName(name string) string {
+	if name == "" || strings.HasPrefix(name, digestPrefix) {
+		return name
+	}
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		return name
+	}
+	return name + ":" + defaultTag
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
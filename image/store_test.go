@@ -0,0 +1,155 @@
+package image
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// ociLayout writes an OCI image layout with the layers (lowest first) to dir and returns the image ID.
+func ociLayout(t *testing.T, dir, ref string, config Config, layers ...[]byte) string {
+	t.Helper()
+	writeBlob := func(data []byte) descriptor {
+		sum := sha256.Sum256(data)
+		path := filepath.Join(dir, "blobs", "sha256", hex.EncodeToString(sum[:]))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return descriptor{Digest: digestPrefix + hex.EncodeToString(sum[:]), Size: int64(len(data))}
+	}
+	marshal := func(v interface{}) []byte {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	var manifest ociManifest
+	imgConfig := imageConfig{Config: config}
+	for _, layer := range layers {
+		manifest.Layers = append(manifest.Layers, writeBlob(layer))
+		imgConfig.Rootfs.DiffIDs = append(imgConfig.Rootfs.DiffIDs, manifest.Layers[len(manifest.Layers)-1].Digest)
+	}
+	manifest.Config = writeBlob(marshal(imgConfig))
+	desc := writeBlob(marshal(manifest))
+	desc.MediaType = "application/vnd.oci.image.manifest.v1+json"
+	desc.Annotations = map[string]string{ociRefNameAnnotation: ref}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, ociIndexFile), marshal(ociIndex{Manifests: []descriptor{desc}}),
+		0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ociLayoutFile), []byte(`{"imageLayoutVersion":"1.0.0"}`),
+		0644); err != nil {
+		t.Fatal(err)
+	}
+	return manifest.Config.Digest
+}
+
+func testStore(t *testing.T) (*Store, string) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "images")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewStore(filepath.Join(dir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, dir
+}
+
+func TestImport(t *testing.T) {
+	store, dir := testStore(t)
+	defer os.RemoveAll(dir)
+
+	base := layerTar(t, entry{name: "bin/", typeflag: tar.TypeDir}, entry{name: "bin/sh", typeflag: tar.TypeReg,
+		content: "#!"})
+	app := layerTar(t, entry{name: "app/", typeflag: tar.TypeDir}, entry{name: "app/run", typeflag: tar.TypeReg,
+		content: "run"})
+	config := Config{Env: []string{"PATH=/bin"}, Cmd: []string{"/app/run"}, WorkingDir: "/app"}
+	layout := filepath.Join(dir, "layout")
+	id := ociLayout(t, layout, "app", config, base, app)
+
+	img, err := store.Import(layout, "registry.local/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.ID != id || !reflect.DeepEqual(img.Config, config) {
+		t.Errorf("imported image %s with %+v, expected %s with %+v", img.ID, img.Config, id, config)
+	}
+	if len(img.Layers) != 2 {
+		t.Fatalf("imported image has %d layers, expected 2", len(img.Layers))
+	}
+	if content, err := ioutil.ReadFile(filepath.Join(store.layerDir(img.Layers[1]), "app/run")); err != nil ||
+		string(content) != "run" {
+		t.Errorf("unpacked layer file = %q, %v, expected run", content, err)
+	}
+
+	for _, ref := range []string{"app", "app:latest", "registry.local/app:1.0", id, img.ShortID()} {
+		if found, err := store.Get(ref); err != nil || found.ID != id {
+			t.Errorf("Get(%s) = %v, expected the imported image", ref, err)
+		}
+	}
+	if _, err := store.Get("app:1.0"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of an unknown tag = %v, expected %v", err, ErrNotFound)
+	}
+
+	// importing the same image again only adds the name
+	if again, err := store.Import(layout, "other"); err != nil || again.ID != id || len(again.Names) != 3 {
+		t.Errorf("second import = %+v, %v, expected the image with 3 names", again, err)
+	}
+
+	if err := store.Remove(id); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range []string{blobsDir + "/sha256", layersDir, imagesDir} {
+		if entries, err := ioutil.ReadDir(filepath.Join(store.root, d)); err != nil || len(entries) != 0 {
+			t.Errorf("%s isn't empty after removing the only image: %d entries, %v", d, len(entries), err)
+		}
+	}
+}
+
+func TestImportRejectsCorruptLayers(t *testing.T) {
+	store, dir := testStore(t)
+	defer os.RemoveAll(dir)
+
+	layer := layerTar(t, entry{name: "file", typeflag: tar.TypeReg, content: "data"})
+	layout := filepath.Join(dir, "layout")
+	ociLayout(t, layout, "corrupt", Config{}, layer)
+
+	entries, err := ioutil.ReadDir(filepath.Join(layout, "blobs", "sha256"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries { // the layer is the only tarball, change a byte of the file content
+		path := filepath.Join(layout, "blobs", "sha256", e.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) == len(layer) {
+			data[512] ^= 0xff
+			if err := ioutil.WriteFile(path, data, 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if img, err := store.Import(layout, ""); err == nil {
+		t.Errorf("imported an image with a corrupt layer: %+v", img)
+	}
+	if _, err := store.Get("corrupt"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a failed import = %v, expected %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,231 @@
+package network
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DNSIP is the address of the embedded DNS server in container network namespaces, like in Docker.
+var DNSIP = net.IPv4(127, 0, 0, 11).To4()
+
+const (
+	DNSPort = 53
+
+	dnsHeaderLen      = 12
+	dnsTypeA          = 1
+	dnsClassIN        = 1
+	dnsFlagResponse   = 1 << 15
+	dnsFlagAuthority  = 1 << 10
+	dnsFlagRecursion  = 1 << 8 // recursion desired
+	dnsFlagRecursive  = 1 << 7 // recursion available
+	dnsRcodeServFail  = 2
+	dnsTTL            = 10
+	dnsForwardTimeout = 2 * time.Second
+)
+
+// Resolver returns the address of a name, nil if it's unknown.
+type Resolver func(name string) net.IP
+
+// DNSServer answers queries for container names and forwards other queries to upstream nameservers.
+type DNSServer struct {
+	conn     net.PacketConn
+	resolve  Resolver
+	upstream []string // nameserver addresses
+	mutex    sync.Mutex
+	closed   bool
+}
+
+// NewDNSServer serves DNS on conn, queries resolve doesn't know are forwarded to the upstream nameservers.
+func NewDNSServer(conn net.PacketConn, resolve Resolver, upstream []string) *DNSServer {
+	return &DNSServer{conn: conn, resolve: resolve, upstream: upstream}
+}
+
+// Run answers queries until the server is closed.
+func (s *DNSServer) Run() error {
+	buffer := make([]byte, 65535)
+	for {
+		n, client, err := s.conn.ReadFrom(buffer)
+		if err != nil {
+			if s.isClosed() {
+				return nil
+			}
+			return err
+		}
+		query := make([]byte, n)
+		copy(query, buffer[:n])
+		if response := s.answer(query); response != nil {
+			_, _ = s.conn.WriteTo(response, client)
+			continue
+		}
+		go s.forward(query, client)
+	}
+}
+
+func (s *DNSServer) Close() error {
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+	return s.conn.Close()
+}
+
+func (s *DNSServer) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
+// answer returns the response to a query for a known name, nil if the query has to be forwarded.
+func (s *DNSServer) answer(query []byte) []byte {
+	if len(query) < dnsHeaderLen {
+		return nil
+	}
+	flags := binary.BigEndian.Uint16(query[2:4])
+	if flags&dnsFlagResponse != 0 || (flags>>11)&0xf != 0 || binary.BigEndian.Uint16(query[4:6]) != 1 {
+		return nil // not a standard query with one question
+	}
+	name, end, ok := parseName(query, dnsHeaderLen)
+	if !ok || end+4 > len(query) {
+		return nil
+	}
+	ip := s.resolve(name)
+	if ip == nil {
+		return nil
+	}
+	questionType := binary.BigEndian.Uint16(query[end : end+2])
+	questionClass := binary.BigEndian.Uint16(query[end+2 : end+4])
+
+	response := make([]byte, end+4, end+4+16)
+	copy(response, query[:end+4])
+	binary.BigEndian.PutUint16(response[2:4], dnsFlagResponse|dnsFlagAuthority|flags&dnsFlagRecursion|dnsFlagRecursive)
+	binary.BigEndian.PutUint16(response[8:10], 0)  // authority records
+	binary.BigEndian.PutUint16(response[10:12], 0) // additional records
+	if questionType != dnsTypeA || questionClass != dnsClassIN || ip.To4() == nil {
+		binary.BigEndian.PutUint16(response[6:8], 0)
+		return response
+	}
+	binary.BigEndian.PutUint16(response[6:8], 1)
+	record := make([]byte, 16)
+	binary.BigEndian.PutUint16(record[0:2], 0xc000|dnsHeaderLen) // pointer to the question name
+	binary.BigEndian.PutUint16(record[2:4], dnsTypeA)
+	binary.BigEndian.PutUint16(record[4:6], dnsClassIN)
+	binary.BigEndian.PutUint32(record[6:10], dnsTTL)
+	binary.BigEndian.PutUint16(record[10:12], net.IPv4len)
+	copy(record[12:], ip.To4())
+	return append(response, record...)
+}
+
+// forward relays a query to the upstream nameservers, clients get a server failure if none responds.
+func (s *DNSServer) forward(query []byte, client net.Addr) {
+	buffer := make([]byte, 65535)
+	for _, nameserver := range s.upstream {
+		conn, err := net.Dial("udp", net.JoinHostPort(nameserver, strconv.Itoa(DNSPort)))
+		if err != nil {
+			continue
+		}
+		_ = conn.SetDeadline(time.Now().Add(dnsForwardTimeout))
+		_, err = conn.Write(query)
+		var n int
+		if err == nil {
+			n, err = conn.Read(buffer)
+		}
+		conn.Close()
+		if err != nil {
+			continue
+		}
+		_, _ = s.conn.WriteTo(buffer[:n], client)
+		return
+	}
+	if len(query) < dnsHeaderLen {
+		return
+	}
+	flags := binary.BigEndian.Uint16(query[2:4])
+	response := append([]byte(nil), query...)
+	binary.BigEndian.PutUint16(response[2:4], dnsFlagResponse|flags&(0xf<<11|dnsFlagRecursion)|dnsFlagRecursive|dnsRcodeServFail)
+	_, _ = s.conn.WriteTo(response, client)
+}
+
+// parseName parses an uncompressed name at offset, it returns the lowercase name and the offset after it.
+func parseName(message []byte, offset int) (string, int, bool) {
+	var labels []string
+	for offset < len(message) {
+		length := int(message[offset])
+		offset++
+		if length == 0 {
+			return strings.ToLower(strings.Join(labels, ".")), offset, true
+		}
+		if length > 63 || offset+length > len(message) {
+			return "", 0, false // compressed or truncated
+		}
+		labels = append(labels, string(message[offset:offset+length]))
+		offset += length
+	}
+	return "", 0, false
+}
+
+// HostsFile returns the /etc/hosts of a container, its names resolve to ip or to a loopback address if it's nil.
+func HostsFile(ip net.IP, names []string, extraHosts []string) []byte {
+	if ip == nil {
+		ip = net.IPv4(127, 0, 1, 1)
+	}
+	var hosts bytes.Buffer
+	hosts.WriteString("127.0.0.1\tlocalhost\n")
+	hosts.WriteString("::1\tlocalhost ip6-localhost ip6-loopback\n")
+	if len(names) > 0 {
+		hosts.WriteString(ip.String() + "\t" + strings.Join(names, " ") + "\n")
+	}
+	for _, host := range extraHosts {
+		name, address, _ := ParseExtraHost(host)
+		hosts.WriteString(address.String() + "\t" + name + "\n")
+	}
+	return hosts.Bytes()
+}
+
+// ResolvConf returns the /etc/resolv.conf of a container, which uses the embedded DNS server.
+func ResolvConf(search []string) []byte {
+	var resolvConf bytes.Buffer
+	resolvConf.WriteString("nameserver " + DNSIP.String() + "\n")
+	if len(search) > 0 {
+		resolvConf.WriteString("search " + strings.Join(search, " ") + "\n")
+	}
+	resolvConf.WriteString("options ndots:0\n")
+	return resolvConf.Bytes()
+}
+
+// ParseExtraHost parses a host:ip entry added to /etc/hosts.
+func ParseExtraHost(host string) (string, net.IP, error) {
+	i := strings.IndexByte(host, ':')
+	if i <= 0 {
+		return "", nil, fmt.Errorf("invalid host %s, expected host:ip", host)
+	}
+	ip := net.ParseIP(host[i+1:])
+	if ip == nil || strings.ContainsAny(host[:i], " \t") {
+		return "", nil, fmt.Errorf("invalid host %s, expected host:ip", host)
+	}
+	return host[:i], ip, nil
+}
+
+// HostNameservers returns the nameservers of the host.
+func HostNameservers() []string {
+	file, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	var nameservers []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			nameservers = append(nameservers, fields[1])
+		}
+	}
+	return nameservers
+}
@@ -0,0 +1,94 @@
+package network
+
+import (
+	"encoding/binary"
+	"net"
+	"testing"
+)
+
+func TestHostsFile(t *testing.T) {
+	hosts := HostsFile(net.ParseIP("10.88.0.5"), []string{"web", "web.cont"}, []string{"db:10.0.0.2", "v6:fd00::1"})
+	expected := "127.0.0.1\tlocalhost\n" +
+		"::1\tlocalhost ip6-localhost ip6-loopback\n" +
+		"10.88.0.5\tweb web.cont\n" +
+		"10.0.0.2\tdb\n" +
+		"fd00::1\tv6\n"
+	if string(hosts) != expected {
+		t.Errorf("HostsFile =\n%s\nexpected\n%s", hosts, expected)
+	}
+
+	// containers without a network address resolve their own names to a loopback address
+	hosts = HostsFile(nil, []string{"isolated"}, nil)
+	expected = "127.0.0.1\tlocalhost\n::1\tlocalhost ip6-localhost ip6-loopback\n127.0.1.1\tisolated\n"
+	if string(hosts) != expected {
+		t.Errorf("HostsFile without an IP =\n%s\nexpected\n%s", hosts, expected)
+	}
+}
+
+func TestResolvConf(t *testing.T) {
+	resolvConf := ResolvConf(nil)
+	if expected := "nameserver 127.0.0.11\noptions ndots:0\n"; string(resolvConf) != expected {
+		t.Errorf("ResolvConf = %q, expected %q", resolvConf, expected)
+	}
+	resolvConf = ResolvConf([]string{"cont", "example.com"})
+	if expected := "nameserver 127.0.0.11\nsearch cont example.com\noptions ndots:0\n"; string(resolvConf) != expected {
+		t.Errorf("ResolvConf with search domains = %q, expected %q", resolvConf, expected)
+	}
+}
+
+func TestParseExtraHost(t *testing.T) {
+	name, ip, err := ParseExtraHost("registry.local:192.168.1.10")
+	if err != nil || name != "registry.local" || !ip.Equal(net.IPv4(192, 168, 1, 10)) {
+		t.Errorf("ParseExtraHost = %s, %s, %v, expected registry.local, 192.168.1.10", name, ip, err)
+	}
+	// the first colon separates the host, IPv6 addresses keep theirs
+	name, ip, err = ParseExtraHost("v6:2001:db8::1")
+	if err != nil || name != "v6" || !ip.Equal(net.ParseIP("2001:db8::1")) {
+		t.Errorf("ParseExtraHost = %s, %s, %v, expected v6, 2001:db8::1", name, ip, err)
+	}
+
+	for _, host := range []string{"", "host", ":10.0.0.1", "host:", "host:300.0.0.1", "two words:10.0.0.1",
+		"tab\there:10.0.0.1"} {
+		if _, _, err := ParseExtraHost(host); err == nil {
+			t.Errorf("ParseExtraHost(%q) didn't fail", host)
+		}
+	}
+}
+
+func TestAnswer(t *testing.T) {
+	server := &DNSServer{resolve: func(name string) net.IP {
+		if name == "web" {
+			return net.IPv4(10, 88, 0, 5)
+		}
+		return nil
+	}}
+	query := func(name string, questionType uint16) []byte {
+		message := []byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0}
+		message = append(message, byte(len(name)))
+		message = append(message, name...)
+		return append(message, 0, byte(questionType>>8), byte(questionType), 0, dnsClassIN)
+	}
+
+	response := server.answer(query("WEB", dnsTypeA))
+	if response == nil {
+		t.Fatal("no answer for a known name")
+	}
+	if id := binary.BigEndian.Uint16(response[0:2]); id != 0x1234 {
+		t.Errorf("response ID = %#x, expected the query ID 0x1234", id)
+	}
+	if count := binary.BigEndian.Uint16(response[6:8]); count != 1 {
+		t.Fatalf("%d answers, expected 1", count)
+	}
+	if ip := net.IP(response[len(response)-4:]); !ip.Equal(net.IPv4(10, 88, 0, 5)) {
+		t.Errorf("answer = %s, expected 10.88.0.5", ip)
+	}
+
+	const dnsTypeAAAA = 28
+	if response := server.answer(query("web", dnsTypeAAAA)); response == nil ||
+		binary.BigEndian.Uint16(response[6:8]) != 0 {
+		t.Errorf("AAAA query for a known name = %v, expected an empty answer", response)
+	}
+	if response := server.answer(query("example", dnsTypeA)); response != nil {
+		t.Errorf("query for an unknown name was answered: %v", response)
+	}
+}
@@ -0,0 +1,254 @@
+package network
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"unsafe"
+)
+
+const vethInfoPeer = 1 // VETH_INFO_PEER
+
+var (
+	nativeEndian binary.ByteOrder = binary.LittleEndian
+	sequence     uint32
+)
+
+func init() {
+	probe := uint16(1)
+	if (*[2]byte)(unsafe.Pointer(&probe))[0] == 0 {
+		nativeEndian = binary.BigEndian
+	}
+}
+
+// message is an rtnetlink request: a fixed header (ifinfomsg, ifaddrmsg, rtmsg...) followed by attributes.
+type message struct {
+	typ, flags uint16
+	data       []byte
+}
+
+func newMessage(typ, flags uint16, header []byte) *message {
+	return &message{typ: typ, flags: flags | unix.NLM_F_REQUEST | unix.NLM_F_ACK, data: header}
+}
+
+func ifInfo(index int, flags, change uint32) []byte {
+	info := unix.IfInfomsg{Family: unix.AF_UNSPEC, Index: int32(index), Flags: flags, Change: change}
+	return append([]byte(nil), (*[unix.SizeofIfInfomsg]byte)(unsafe.Pointer(&info))[:]...)
+}
+
+// attribute returns an rtattr with data, padded to 4 bytes.
+func attribute(typ uint16, data []byte) []byte {
+	length := unix.SizeofRtAttr + len(data)
+	result := make([]byte, (length+unix.NLMSG_ALIGNTO-1)&^(unix.NLMSG_ALIGNTO-1))
+	nativeEndian.PutUint16(result[0:2], uint16(length))
+	nativeEndian.PutUint16(result[2:4], typ)
+	copy(result[unix.SizeofRtAttr:], data)
+	return result
+}
+
+func uint32Attribute(typ uint16, value uint32) []byte {
+	data := make([]byte, 4)
+	nativeEndian.PutUint32(data, value)
+	return attribute(typ, data)
+}
+
+func stringAttribute(typ uint16, value string) []byte {
+	return attribute(typ, append([]byte(value), 0))
+}
+
+func (m *message) add(attributes ...[]byte) *message {
+	for _, a := range attributes {
+		m.data = append(m.data, a...)
+	}
+	return m
+}
+
+// execute sends the request over a new rtnetlink socket of the thread network namespace and waits for the
+// acknowledgement.
+func (m *message) execute() error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.NETLINK_ROUTE)
+	if err != nil {
+		return fmt.Errorf("cannot open netlink socket: %w", err)
+	}
+	defer unix.Close(fd)
+	if err := unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("cannot bind netlink socket: %w", err)
+	}
+
+	seq := atomic.AddUint32(&sequence, 1)
+	request := make([]byte, unix.SizeofNlMsghdr, unix.SizeofNlMsghdr+len(m.data))
+	nativeEndian.PutUint32(request[0:4], uint32(unix.SizeofNlMsghdr+len(m.data)))
+	nativeEndian.PutUint16(request[4:6], m.typ)
+	nativeEndian.PutUint16(request[6:8], m.flags)
+	nativeEndian.PutUint32(request[8:12], seq)
+	request = append(request, m.data...)
+	if err := unix.Sendto(fd, request, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return fmt.Errorf("cannot send netlink request: %w", err)
+	}
+
+	buffer := make([]byte, os.Getpagesize())
+	for {
+		n, _, err := unix.Recvfrom(fd, buffer, 0)
+		if err != nil {
+			return fmt.Errorf("cannot receive netlink response: %w", err)
+		}
+		messages, err := syscall.ParseNetlinkMessage(buffer[:n])
+		if err != nil {
+			return err
+		}
+		for _, response := range messages {
+			if response.Header.Seq != seq || response.Header.Type != unix.NLMSG_ERROR {
+				continue
+			}
+			if code := int32(nativeEndian.Uint32(response.Data[0:4])); code != 0 {
+				return unix.Errno(-code)
+			}
+			return nil
+		}
+	}
+}
+
+func createBridge(name string) error {
+	err := newMessage(unix.RTM_NEWLINK, unix.NLM_F_CREATE|unix.NLM_F_EXCL, ifInfo(0, 0, 0)).add(
+		stringAttribute(unix.IFLA_IFNAME, name),
+		attribute(unix.IFLA_LINKINFO, stringAttribute(unix.IFLA_INFO_KIND, "bridge")),
+	).execute()
+	if err != nil {
+		return fmt.Errorf("cannot create bridge %s: %w", name, err)
+	}
+	return nil
+}
+
+// createVeth creates a veth pair, both ends in the current network namespace.
+func createVeth(name, peer string) error {
+	peerInfo := append(ifInfo(0, 0, 0), stringAttribute(unix.IFLA_IFNAME, peer)...)
+	linkInfo := append(stringAttribute(unix.IFLA_INFO_KIND, "veth"),
+		attribute(unix.IFLA_INFO_DATA, attribute(vethInfoPeer, peerInfo))...)
+	err := newMessage(unix.RTM_NEWLINK, unix.NLM_F_CREATE|unix.NLM_F_EXCL, ifInfo(0, 0, 0)).add(
+		stringAttribute(unix.IFLA_IFNAME, name),
+		attribute(unix.IFLA_LINKINFO, linkInfo),
+	).execute()
+	if err != nil {
+		return fmt.Errorf("cannot create veth pair %s: %w", name, err)
+	}
+	return nil
+}
+
+func deleteLink(name string) error {
+	link, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	return newMessage(unix.RTM_DELLINK, 0, ifInfo(link.Index, 0, 0)).execute()
+}
+
+func setLinkUp(name string) error {
+	link, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	if err := newMessage(unix.RTM_NEWLINK, 0, ifInfo(link.Index, unix.IFF_UP, unix.IFF_UP)).execute(); err != nil {
+		return fmt.Errorf("cannot set %s up: %w", name, err)
+	}
+	return nil
+}
+
+// setLink sets link attributes, e.g. its master or name.
+func setLink(name string, attributes ...[]byte) error {
+	link, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	if err := newMessage(unix.RTM_NEWLINK, 0, ifInfo(link.Index, 0, 0)).add(attributes...).execute(); err != nil {
+		return fmt.Errorf("cannot change %s: %w", name, err)
+	}
+	return nil
+}
+
+func addAddress(name string, address *net.IPNet) error {
+	link, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	ones, _ := address.Mask.Size()
+	info := unix.IfAddrmsg{Family: unix.AF_INET, Prefixlen: uint8(ones), Index: uint32(link.Index)}
+	header := append([]byte(nil), (*[unix.SizeofIfAddrmsg]byte)(unsafe.Pointer(&info))[:]...)
+	ip := address.IP.To4()
+	err = newMessage(unix.RTM_NEWADDR, unix.NLM_F_CREATE|unix.NLM_F_EXCL, header).add(
+		attribute(unix.IFA_LOCAL, ip),
+		attribute(unix.IFA_ADDRESS, ip),
+	).execute()
+	if err != nil {
+		return fmt.Errorf("cannot add address %s to %s: %w", address, name, err)
+	}
+	return nil
+}
+
+func addDefaultRoute(name string, gateway net.IP) error {
+	link, err := net.InterfaceByName(name)
+	if err != nil {
+		return err
+	}
+	route := unix.RtMsg{
+		Family:   unix.AF_INET,
+		Table:    unix.RT_TABLE_MAIN,
+		Protocol: unix.RTPROT_BOOT,
+		Scope:    unix.RT_SCOPE_UNIVERSE,
+		Type:     unix.RTN_UNICAST,
+	}
+	header := append([]byte(nil), (*[unix.SizeofRtMsg]byte)(unsafe.Pointer(&route))[:]...)
+	err = newMessage(unix.RTM_NEWROUTE, unix.NLM_F_CREATE|unix.NLM_F_EXCL, header).add(
+		attribute(unix.RTA_GATEWAY, gateway.To4()),
+		uint32Attribute(unix.RTA_OIF, uint32(link.Index)),
+	).execute()
+	if err != nil {
+		return fmt.Errorf("cannot add default route via %s: %w", gateway, err)
+	}
+	return nil
+}
+
+// inNetNS calls f on a locked thread in the network namespace of a process.
+func inNetNS(pid int, f func() error) error {
+	errs := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		origin, err := os.Open("/proc/self/task/" + strconv.Itoa(unix.Gettid()) + "/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			errs <- err
+			return
+		}
+		defer origin.Close()
+		target, err := os.Open("/proc/" + strconv.Itoa(pid) + "/ns/net")
+		if err != nil {
+			runtime.UnlockOSThread()
+			errs <- err
+			return
+		}
+		defer target.Close()
+
+		if err := unix.Setns(int(target.Fd()), unix.CLONE_NEWNET); err != nil {
+			runtime.UnlockOSThread()
+			errs <- fmt.Errorf("cannot enter network namespace of %d: %w", pid, err)
+			return
+		}
+		err = f()
+		if unix.Setns(int(origin.Fd()), unix.CLONE_NEWNET) == nil {
+			runtime.UnlockOSThread() // otherwise the thread exits with the goroutine
+		}
+		errs <- err
+	}()
+	return <-errs
+}
+
+func isNotFound(err error) bool {
+	var opError *net.OpError
+	return errors.As(err, &opError) || errors.Is(err, unix.ENODEV)
+}
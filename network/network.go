@@ -0,0 +1,506 @@
+package network
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultName   = "cont"
+	defaultBridge = "cont0"
+	defaultSubnet = "10.88.0.0/16"
+
+	// None gives containers only a loopback interface.
+	None = "none"
+	// Slirp connects containers through a userspace network stack in the daemon, it doesn't need root.
+	Slirp = "slirp"
+	// ContainerInterface is the name of the container end of a network connection.
+	ContainerInterface = "eth0"
+
+	networkFileSuffix = ".json"
+)
+
+var (
+	ErrNotFound = errors.New("network not found")
+	ErrInUse    = errors.New("network is in use")
+
+	validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+type Network struct {
+	Name      string            `json:"name"`
+	Bridge    string            `json:"bridge"`
+	Subnet    string            `json:"subnet"`
+	Gateway   string            `json:"gateway"` // bridge address
+	Created   time.Time         `json:"created"`
+	Endpoints map[string]string `json:"endpoints"` // container ID -> allocated IP
+}
+
+// Endpoint is a container connected to a network.
+type Endpoint struct {
+	Network string `json:"network"`
+	IP      string `json:"ip"` // with the subnet prefix length, e.g. 10.88.0.2/16
+	Gateway string `json:"gateway"`
+}
+
+// Store keeps bridge networks and their IP allocations as <root>/<name>.json files. The bridges are created on demand,
+// networks outlive them (e.g. after a reboot).
+type Store struct {
+	root  string
+	mutex sync.Mutex
+}
+
+func NewStore(root string) (*Store, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, fmt.Errorf("cannot create network directory: %w", err)
+	}
+	s := &Store{root: root}
+	if _, err := s.load(DefaultName); errors.Is(err, ErrNotFound) {
+		_, err = s.create(DefaultName, defaultBridge, defaultSubnet)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create default network: %w", err)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Create creates a network with a new bridge. A free /16 subnet is picked if subnet is empty.
+func (s *Store) Create(name, subnet string) (*Network, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !validName.MatchString(name) || name == None || name == Slirp {
+		return nil, fmt.Errorf("invalid network name %s", name)
+	}
+	if _, err := s.load(name); err == nil {
+		return nil, fmt.Errorf("network %s already exists", name)
+	}
+	networks, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	if subnet == "" {
+		if subnet, err = freeSubnet(networks); err != nil {
+			return nil, err
+		}
+	}
+	return s.create(name, freeBridge(networks), subnet)
+}
+
+func (s *Store) Get(name string) (*Network, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.load(name)
+}
+
+func (s *Store) List() ([]*Network, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.list()
+}
+
+// Remove removes a network, its bridge and NAT rules. The default network and networks with connected containers
+// can't be removed.
+func (s *Store) Remove(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if name == DefaultName {
+		return fmt.Errorf("the default network %s can't be removed", name)
+	}
+	n, err := s.load(name)
+	if err != nil {
+		return err
+	}
+	if len(n.Endpoints) != 0 {
+		return fmt.Errorf("%w: %s has connected containers %s", ErrInUse, name, strings.Join(n.containers(), ", "))
+	}
+	if os.Geteuid() == 0 { // a rootless daemon never set the network up
+		if err := deleteLink(n.Bridge); err != nil && !isNotFound(err) {
+			return fmt.Errorf("cannot delete bridge %s: %w", n.Bridge, err)
+		}
+		removeNAT(n)
+	}
+	return os.Remove(s.path(name))
+}
+
+// Connect connects the network namespace of a process to a network through a veth pair, the namespace end is eth0.
+func (s *Store) Connect(name, containerID string, pid int) (*Endpoint, error) {
+	if os.Geteuid() != 0 {
+		return nil, fmt.Errorf("network %s needs a daemon running as root", name)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, err := s.load(name)
+	if err != nil {
+		return nil, err
+	}
+	ip, err := n.allocate(containerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(n); err != nil {
+		return nil, err
+	}
+	endpoint := &Endpoint{Network: n.Name, IP: ip.String(), Gateway: n.Gateway}
+
+	if err := s.connect(n, containerID, pid, ip); err != nil {
+		delete(n.Endpoints, containerID)
+		if err := s.save(n); err != nil {
+			log.Printf("cannot release IP %s of container %s: %v", ip, containerID, err)
+		}
+		return nil, err
+	}
+	return endpoint, nil
+}
+
+func (s *Store) connect(n *Network, containerID string, pid int, ip *net.IPNet) error {
+	if err := setupBridge(n); err != nil {
+		return err
+	}
+	setupNAT(n)
+
+	host, peer := "veth"+containerID[:8], "ceth"+containerID[:8]
+	if err := createVeth(host, peer); err != nil {
+		return err
+	}
+	err := setLink(host, uint32Attribute(unix.IFLA_MASTER, uint32(bridgeIndex(n.Bridge))))
+	if err == nil {
+		err = setLinkUp(host)
+	}
+	if err == nil {
+		err = setLink(peer, uint32Attribute(unix.IFLA_NET_NS_PID, uint32(pid)))
+	}
+	if err != nil {
+		if err := deleteLink(host); err != nil {
+			log.Printf("cannot delete %s: %v", host, err)
+		}
+		return err
+	}
+
+	// the host end goes away with the namespace if this fails
+	return inNetNS(pid, func() error {
+		if err := setLink(peer, stringAttribute(unix.IFLA_IFNAME, ContainerInterface)); err != nil {
+			return err
+		}
+		if err := addAddress(ContainerInterface, ip); err != nil {
+			return err
+		}
+		if err := setLinkUp(ContainerInterface); err != nil {
+			return err
+		}
+		return addDefaultRoute(ContainerInterface, net.ParseIP(n.Gateway))
+	})
+}
+
+// Disconnect releases the IP of a container. Its veth pair is deleted with the container network namespace.
+func (s *Store) Disconnect(name, containerID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	n, err := s.load(name)
+	if err != nil {
+		return err
+	}
+	if _, ok := n.Endpoints[containerID]; !ok {
+		return nil
+	}
+	delete(n.Endpoints, containerID)
+	if len(containerID) >= 8 {
+		if err := deleteLink("veth" + containerID[:8]); err != nil && !isNotFound(err) {
+			log.Printf("cannot delete veth of container %s: %v", containerID, err)
+		}
+	}
+	return s.save(n)
+}
+
+// Reconcile releases IPs of containers keep returns false for, e.g. after a daemon restart.
+func (s *Store) Reconcile(keep func(containerID string) bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	networks, err := s.list()
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		changed := false
+		for id := range n.Endpoints {
+			if !keep(id) {
+				delete(n.Endpoints, id)
+				changed = true
+			}
+		}
+		if changed {
+			if err := s.save(n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LoopbackUp sets the loopback interface of the current network namespace up.
+func LoopbackUp() error {
+	return setLinkUp("lo")
+}
+
+func (n *Network) containers() []string {
+	ids := make([]string, 0, len(n.Endpoints))
+	for id := range n.Endpoints {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// allocate assigns the lowest free address of the subnet to a container, the first one belongs to the gateway.
+func (n *Network) allocate(containerID string) (*net.IPNet, error) {
+	_, subnet, err := net.ParseCIDR(n.Subnet)
+	if err != nil {
+		return nil, err
+	}
+	used := map[string]bool{n.Gateway: true}
+	for _, ip := range n.Endpoints {
+		used[ip] = true
+	}
+	ones, bits := subnet.Mask.Size()
+	first := binary.BigEndian.Uint32(subnet.IP.To4())
+	for i := uint32(2); i < 1<<uint(bits-ones)-1; i++ { // skip the network & broadcast address
+		ip := make(net.IP, net.IPv4len)
+		binary.BigEndian.PutUint32(ip, first+i)
+		if used[ip.String()] {
+			continue
+		}
+		n.Endpoints[containerID] = ip.String()
+		return &net.IPNet{IP: ip, Mask: subnet.Mask}, nil
+	}
+	return nil, fmt.Errorf("no free IP in network %s", n.Name)
+}
+
+func setupBridge(n *Network) error {
+	if _, err := net.InterfaceByName(n.Bridge); err != nil {
+		if err := createBridge(n.Bridge); err != nil {
+			return err
+		}
+	}
+	_, subnet, err := net.ParseCIDR(n.Subnet)
+	if err != nil {
+		return err
+	}
+	gateway := &net.IPNet{IP: net.ParseIP(n.Gateway), Mask: subnet.Mask}
+	if err := addAddress(n.Bridge, gateway); err != nil && !errors.Is(err, unix.EEXIST) {
+		return err
+	}
+	return setLinkUp(n.Bridge)
+}
+
+func bridgeIndex(name string) int {
+	link, err := net.InterfaceByName(name)
+	if err != nil {
+		return 0
+	}
+	return link.Index
+}
+
+// natRules masquerade traffic leaving the subnet through other interfaces and accept forwarding for the bridge.
+func natRules(n *Network) [][]string {
+	return [][]string{
+		{"-t", "nat", "POSTROUTING", "-s", n.Subnet, "!", "-o", n.Bridge, "-j", "MASQUERADE"},
+		{"-t", "filter", "FORWARD", "-i", n.Bridge, "-j", "ACCEPT"},
+		{"-t", "filter", "FORWARD", "-o", n.Bridge, "-j", "ACCEPT"},
+	}
+}
+
+// setupNAT enables IP forwarding and adds the NAT rules, failures are only logged.
+func setupNAT(n *Network) {
+	if err := ioutil.WriteFile("/proc/sys/net/ipv4/ip_forward", []byte("1"), 0644); err != nil {
+		log.Printf("cannot enable IP forwarding: %v", err)
+	}
+	for _, rule := range natRules(n) {
+		if iptables(append([]string{rule[0], rule[1], "-C", rule[2]}, rule[3:]...)...) == nil {
+			continue
+		}
+		if err := iptables(append([]string{rule[0], rule[1], "-A", rule[2]}, rule[3:]...)...); err != nil {
+			log.Printf("cannot set up NAT for network %s: %v", n.Name, err)
+			return
+		}
+	}
+}
+
+func removeNAT(n *Network) {
+	for _, rule := range natRules(n) {
+		for iptables(append([]string{rule[0], rule[1], "-C", rule[2]}, rule[3:]...)...) == nil {
+			if err := iptables(append([]string{rule[0], rule[1], "-D", rule[2]}, rule[3:]...)...); err != nil {
+				log.Printf("cannot remove NAT rule of network %s: %v", n.Name, err)
+				break
+			}
+		}
+	}
+}
+
+func iptables(args ...string) error {
+	path, err := exec.LookPath("iptables")
+	if err != nil {
+		return err
+	}
+	if output, err := exec.Command(path, append([]string{"-w"}, args...)...).CombinedOutput(); err != nil {
+		return fmt.Errorf("iptables %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func freeBridge(networks []*Network) string {
+	used := map[string]bool{}
+	for _, n := range networks {
+		used[n.Bridge] = true
+	}
+	for i := 1; ; i++ {
+		if name := "cont" + strconv.Itoa(i); !used[name] {
+			return name
+		}
+	}
+}
+
+func freeSubnet(networks []*Network) (string, error) {
+	for i := 89; i < 256; i++ {
+		subnet := fmt.Sprintf("10.%d.0.0/16", i)
+		if overlapping(networks, subnet) == nil {
+			return subnet, nil
+		}
+	}
+	return "", errors.New("no free subnet, specify one")
+}
+
+// overlapping returns a network whose subnet overlaps subnet.
+func overlapping(networks []*Network, subnet string) *Network {
+	_, a, err := net.ParseCIDR(subnet)
+	if err != nil {
+		return nil
+	}
+	for _, n := range networks {
+		_, b, err := net.ParseCIDR(n.Subnet)
+		if err != nil {
+			continue
+		}
+		if a.Contains(b.IP) || b.Contains(a.IP) {
+			return n
+		}
+	}
+	return nil
+}
+
+func (s *Store) create(name, bridge, subnet string) (*Network, error) {
+	ip, ipNet, err := net.ParseCIDR(subnet)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("invalid IPv4 subnet %s", subnet)
+	}
+	if ones, _ := ipNet.Mask.Size(); ones > 30 {
+		return nil, fmt.Errorf("subnet %s is too small", subnet)
+	}
+	networks, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	if other := overlapping(networks, ipNet.String()); other != nil {
+		return nil, fmt.Errorf("subnet %s overlaps network %s (%s)", subnet, other.Name, other.Subnet)
+	}
+	gateway := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(gateway, binary.BigEndian.Uint32(ipNet.IP.To4())+1)
+	n := &Network{
+		Name:      name,
+		Bridge:    bridge,
+		Subnet:    ipNet.String(),
+		Gateway:   gateway.String(),
+		Created:   time.Now(),
+		Endpoints: make(map[string]string),
+	}
+	return n, s.save(n)
+}
+
+func (s *Store) path(name string) string {
+	return filepath.Join(s.root, name+networkFileSuffix)
+}
+
+func (s *Store) list() ([]*Network, error) {
+	files, err := ioutil.ReadDir(s.root)
+	if err != nil {
+		return nil, err
+	}
+	networks := make([]*Network, 0, len(files))
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), networkFileSuffix) {
+			continue
+		}
+		n, err := s.load(strings.TrimSuffix(file.Name(), networkFileSuffix))
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, n)
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		return networks[i].Name < networks[j].Name
+	})
+	return networks, nil
+}
+
+func (s *Store) load(name string) (*Network, error) {
+	if !validName.MatchString(name) {
+		return nil, fmt.Errorf("invalid network name %s", name)
+	}
+	file, err := os.Open(s.path(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var n Network
+	if err := json.NewDecoder(file).Decode(&n); err != nil {
+		return nil, fmt.Errorf("cannot decode network %s: %w", name, err)
+	}
+	if n.Endpoints == nil {
+		n.Endpoints = make(map[string]string)
+	}
+	return &n, nil
+}
+
+func (s *Store) save(n *Network) error {
+	path := s.path(n.Name)
+	tmp, err := ioutil.TempFile(s.root, n.Name+networkFileSuffix+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(n); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,85 @@
+package network
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func TestAllocate(t *testing.T) {
+	n := &Network{
+		Name:    "test",
+		Subnet:  "10.90.0.0/29",
+		Gateway: "10.90.0.1",
+		Endpoints: map[string]string{
+			"a": "10.90.0.2",
+			"b": "10.90.0.4",
+		},
+	}
+	// the lowest free addresses are used first, the network, gateway & broadcast addresses never
+	for _, expected := range []string{"10.90.0.3/29", "10.90.0.5/29", "10.90.0.6/29"} {
+		id := "container" + expected
+		ip, err := n.allocate(id)
+		if err != nil {
+			t.Fatalf("allocate: %v", err)
+		}
+		if ip.String() != expected {
+			t.Errorf("allocate = %s, expected %s", ip, expected)
+		}
+		if n.Endpoints[id] != ip.IP.String() {
+			t.Errorf("allocated IP %s isn't recorded, endpoints: %v", ip.IP, n.Endpoints)
+		}
+	}
+	if ip, err := n.allocate("full"); err == nil {
+		t.Errorf("allocate = %s in a full subnet, expected an error", ip)
+	}
+
+	delete(n.Endpoints, "b")
+	if ip, err := n.allocate("reused"); err != nil || ip.String() != "10.90.0.4/29" {
+		t.Errorf("allocate = %s, %v, expected a released address 10.90.0.4/29", ip, err)
+	}
+}
+
+func TestAllocateLargeSubnet(t *testing.T) {
+	n := &Network{Name: "test", Subnet: "10.88.0.0/16", Gateway: "10.88.0.1", Endpoints: map[string]string{}}
+	var ip *net.IPNet
+	for i := 0; i < 300; i++ {
+		var err error
+		if ip, err = n.allocate(fmt.Sprint(i)); err != nil {
+			t.Fatalf("allocate: %v", err)
+		}
+	}
+	if expected := "10.88.1.45/16"; ip.String() != expected { // 300 addresses after the gateway
+		t.Errorf("allocate = %s, expected %s", ip, expected)
+	}
+}
+
+func TestOverlapping(t *testing.T) {
+	networks := []*Network{
+		{Name: "cont", Subnet: "10.88.0.0/16"},
+		{Name: "backend", Subnet: "10.90.0.0/24"},
+	}
+	tests := []struct {
+		subnet, expected string
+	}{
+		{"10.88.5.0/24", "cont"},
+		{"10.0.0.0/8", "cont"},
+		{"10.90.0.128/25", "backend"},
+		{"10.89.0.0/16", ""},
+		{"10.90.1.0/24", ""},
+		{"invalid", ""},
+	}
+	for _, test := range tests {
+		name := ""
+		if n := overlapping(networks, test.subnet); n != nil {
+			name = n.Name
+		}
+		if name != test.expected {
+			t.Errorf("overlapping(%s) = %q, expected %q", test.subnet, name, test.expected)
+		}
+	}
+
+	if subnet, err := freeSubnet(networks); err != nil || subnet != "10.89.0.0/16" {
+		t.Errorf("freeSubnet = %s, %v, expected 10.89.0.0/16", subnet, err)
+	}
+}
@@ -0,0 +1,169 @@
+package network
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const udpIdleTimeout = time.Minute
+
+// PortMapping publishes a container port on a host port.
+type PortMapping struct {
+	HostIP        string `json:"hostIp"` // all addresses if empty
+	HostPort      int    `json:"hostPort"`
+	ContainerPort int    `json:"containerPort"`
+	Protocol      string `json:"protocol"` // tcp or udp
+}
+
+// Dialer connects to a container port.
+type Dialer func(network string, port int) (net.Conn, error)
+
+// Proxy relays connections from host ports to container ports in userspace, which doesn't need root or iptables.
+type Proxy struct {
+	Mappings  []PortMapping // with the host ports picked for mappings without one
+	listeners []io.Closer
+	wg        sync.WaitGroup
+}
+
+// NewProxy listens on the host ports of the mappings, random ports are picked for host port 0.
+func NewProxy(mappings []PortMapping, dial Dialer) (*Proxy, error) {
+	p := &Proxy{Mappings: make([]PortMapping, 0, len(mappings))}
+	for _, m := range mappings {
+		address := net.JoinHostPort(m.HostIP, strconv.Itoa(m.HostPort))
+		switch m.Protocol {
+		case "tcp":
+			listener, err := net.Listen("tcp", address)
+			if err != nil {
+				p.Close()
+				return nil, fmt.Errorf("cannot publish port %d: %w", m.ContainerPort, err)
+			}
+			m.HostPort = listener.Addr().(*net.TCPAddr).Port
+			p.listeners = append(p.listeners, listener)
+			p.wg.Add(1)
+			go p.acceptTCP(listener, m.ContainerPort, dial)
+		case "udp":
+			conn, err := net.ListenPacket("udp", address)
+			if err != nil {
+				p.Close()
+				return nil, fmt.Errorf("cannot publish port %d: %w", m.ContainerPort, err)
+			}
+			m.HostPort = conn.LocalAddr().(*net.UDPAddr).Port
+			p.listeners = append(p.listeners, conn)
+			p.wg.Add(1)
+			go p.relayUDP(conn, m.ContainerPort, dial)
+		default:
+			p.Close()
+			return nil, fmt.Errorf("unknown protocol %s", m.Protocol)
+		}
+		p.Mappings = append(p.Mappings, m)
+	}
+	return p, nil
+}
+
+// Close stops listening, established connections are closed by the container side once it's gone.
+func (p *Proxy) Close() error {
+	for _, l := range p.listeners {
+		l.Close()
+	}
+	p.wg.Wait()
+	return nil
+}
+
+func (p *Proxy) acceptTCP(listener net.Listener, port int, dial Dialer) {
+	defer p.wg.Done()
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			target, err := dial("tcp", port)
+			if err != nil {
+				log.Printf("cannot proxy connection to port %d: %v", port, err)
+				return
+			}
+			defer target.Close()
+			relay(conn, target)
+		}()
+	}
+}
+
+// relay copies data both ways, half-closing connections as they reach EOF.
+func relay(a, b net.Conn) {
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(b, a)
+		closeWrite(b)
+		close(done)
+	}()
+	_, _ = io.Copy(a, b)
+	closeWrite(a)
+	<-done
+}
+
+func closeWrite(conn net.Conn) {
+	if c, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = c.CloseWrite()
+	}
+}
+
+// relayUDP relays datagrams, each client gets its own container socket for the replies.
+func (p *Proxy) relayUDP(conn net.PacketConn, port int, dial Dialer) {
+	defer p.wg.Done()
+	var mutex sync.Mutex
+	clients := make(map[string]net.Conn)
+	defer func() {
+		mutex.Lock()
+		for _, target := range clients {
+			target.Close()
+		}
+		mutex.Unlock()
+	}()
+
+	buffer := make([]byte, 65535)
+	for {
+		n, client, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		mutex.Lock()
+		target, ok := clients[client.String()]
+		if !ok {
+			target, err = dial("udp", port)
+			if err != nil {
+				mutex.Unlock()
+				log.Printf("cannot proxy datagram to port %d: %v", port, err)
+				continue
+			}
+			clients[client.String()] = target
+			go func(client net.Addr, target net.Conn) {
+				defer func() {
+					mutex.Lock()
+					delete(clients, client.String())
+					mutex.Unlock()
+					target.Close()
+				}()
+				reply := make([]byte, 65535)
+				for {
+					_ = target.SetReadDeadline(time.Now().Add(udpIdleTimeout))
+					n, err := target.Read(reply)
+					if err != nil {
+						return
+					}
+					if _, err := conn.WriteTo(reply[:n], client); err != nil {
+						return
+					}
+				}
+			}(client, target)
+		}
+		mutex.Unlock()
+		_ = target.SetReadDeadline(time.Now().Add(udpIdleTimeout))
+		_, _ = target.Write(buffer[:n])
+	}
+}
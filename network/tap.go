@@ -0,0 +1,43 @@
+package network
+
+import (
+	"fmt"
+	"golang.org/x/sys/unix"
+	"net"
+	"os"
+	"unsafe"
+)
+
+// SetupTap creates and configures a TAP device (without packet information) in the current network namespace.
+func SetupTap(name string, mac net.HardwareAddr, address *net.IPNet, gateway net.IP) (*os.File, error) {
+	tun, err := os.OpenFile("/dev/net/tun", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	var request [unix.IFNAMSIZ + 24]byte // struct ifreq
+	copy(request[:unix.IFNAMSIZ-1], name)
+	nativeEndian.PutUint16(request[unix.IFNAMSIZ:], unix.IFF_TAP|unix.IFF_NO_PI)
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, tun.Fd(), unix.TUNSETIFF, uintptr(unsafe.Pointer(&request[0]))); errno != 0 {
+		tun.Close()
+		return nil, fmt.Errorf("cannot create TAP device %s: %w", name, errno)
+	}
+
+	err = setLink(name, attribute(unix.IFLA_ADDRESS, mac))
+	if err == nil {
+		err = addAddress(name, address)
+	}
+	if err == nil {
+		err = setLinkUp(name)
+	}
+	if err == nil {
+		err = LoopbackUp()
+	}
+	if err == nil {
+		err = addDefaultRoute(name, gateway)
+	}
+	if err != nil {
+		tun.Close()
+		return nil, err
+	}
+	return tun, nil
+}
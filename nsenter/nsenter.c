@@ -4,9 +4,14 @@
 #include <sched.h>
 #include <errno.h>
 #include <string.h>
-#include <sys/capability.h>
+#include <signal.h>
+#include <sys/ioctl.h>
 #include <sys/prctl.h>
+#include <sys/wait.h>
 #include <unistd.h>
+#include <linux/nsfs.h>
+
+static pid_t child = -1;
 
 static int initPipe(void) {
     int pipenum;
@@ -18,7 +23,7 @@ static int initPipe(void) {
 
     pipenum = strtol(initPipe, &endptr, 10);
     if (*endptr != '\0') {
-        printf("cannot convert string to pipenum\n");
+        fprintf(stderr, "cannot convert string to pipenum\n");
     }
     return pipenum;
 }
@@ -29,7 +34,6 @@ static void getSharedNSes(int *start, int *end) {
 
     value = getenv("_NS_START");
     if (value == NULL || *value == '\0') {
-        printf("no _NS_START\n");
         *start = -1;
         return;
     }
@@ -41,7 +45,6 @@ static void getSharedNSes(int *start, int *end) {
     *start = result;
     value = getenv("_NS_END");
     if (value == NULL || *value == '\0') {
-        printf("no _NS_END\n");
         *end = -1;
         return;
     }
@@ -53,49 +56,107 @@ static void getSharedNSes(int *start, int *end) {
     *end = result;
 }
 
+// getUnshareFlags returns the namespaces to create after joining the shared ones, they're owned by the joined user
+// namespace then.
+static int getUnshareFlags(void) {
+    char *value, *endptr;
+    int flags;
+
+    value = getenv("_NS_UNSHARE");
+    if (value == NULL || *value == '\0')
+        return 0;
+    flags = strtol(value, &endptr, 10);
+    if (*endptr != '\0') {
+        fprintf(stderr, "cannot convert string to unshare flags\n");
+        exit(1);
+    }
+    return flags;
+}
+
+// joinNamespaces joins and closes all namespace fds, returning whether one of them was a PID namespace.
 static int joinNamespaces(int startNSFD, int endNSFD) {
+    int joinedPID = 0;
     for(int fd = startNSFD; fd < endNSFD; fd++) {
+        if (ioctl(fd, NS_GET_NSTYPE) == CLONE_NEWPID) {
+            joinedPID = 1;
+        }
         if (setns(fd, 0) == -1) {
-            printf("cannot setns %d: %s\n", fd, strerror(errno));
+            fprintf(stderr, "cannot setns %d: %s\n", fd, strerror(errno));
             exit(1);
         }
-        printf("setns fd %d call success\n", fd);
+        close(fd);
     }
+    return joinedPID;
 }
 
-void nsexec(void) {
-    cap_t caps;
+static void forwardSignal(int signal) {
+    if (child > 0) {
+        kill(child, signal);
+    }
+}
+
+// forkIntoPIDNamespace forks after joining or creating a PID namespace, the namespace only applies to children and
+// the Go runtime cannot create threads otherwise. The parent stays behind, forwards signals and exits with the child
+// exit status.
+static void forkIntoPIDNamespace(void) {
+    int status;
 
-    printf("Hello world from nsenter (EUID: %d)!\n", geteuid());
+    child = fork();
+    if (child == -1) {
+        fprintf(stderr, "cannot fork: %s\n", strerror(errno));
+        exit(1);
+    }
+    if (child == 0) {
+        // make sure we don't outlive the parent, the daemon only knows about (and kills) the parent. The parent
+        // is outside of our PID namespace so its PID is 0, unless it already died and we were reparented.
+        if (prctl(PR_SET_PDEATHSIG, SIGKILL, 0, 0, 0) == -1 || getppid() != 0) {
+            exit(1);
+        }
+        return;
+    }
 
-    caps = cap_get_proc();
-    if (caps == -1) {
-        printf("cannot get caps: %s\n", strerror(errno));
-    } else {
-        printf("caps: %#0X\n", caps);
+    for (int sig = 1; sig < NSIG; sig++) {
+        if (sig != SIGKILL && sig != SIGSTOP && sig != SIGCHLD) {
+            signal(sig, forwardSignal);
+        }
+    }
+    while (waitpid(child, &status, 0) == -1) {
+        if (errno != EINTR) {
+            fprintf(stderr, "cannot wait for child: %s\n", strerror(errno));
+            exit(1);
+        }
+    }
+    if (WIFSIGNALED(status)) {
+        exit(128 + WTERMSIG(status));
     }
+    exit(WEXITSTATUS(status));
+}
 
+// nsexec joins namespaces passed by the daemon before the Go runtime starts any threads, setns doesn't allow
+// joining user and mount namespaces from multithreaded processes. Namespaces which aren't shared are created
+// afterwards if requested. Nothing is printed to stdout because it's connected to the container (or exec session)
+// output.
+void nsexec(void) {
     if (prctl(PR_SET_DUMPABLE, 1, 0, 0, 0) == -1) {
-        printf("cannot set dumpable\n");
+        fprintf(stderr, "cannot set dumpable\n");
         exit(1);
     }
 
     int pipenum;
-    int startNS, endNS;
+    int startNS = -1, endNS = -1;
 
     pipenum = initPipe();
     if (pipenum == -1) return;
 
-    printf("executing nsenter...\n");
-
     getSharedNSes(&startNS, &endNS);
 
-    if (startNS != -1 && endNS != -1) {
-        printf("sharing NSes from fd %d to %d\n", startNS, endNS);
+    int joinedPID = joinNamespaces(startNS, endNS);
+    int unshareFlags = getUnshareFlags();
+    if (unshareFlags != 0 && unshare(unshareFlags) == -1) {
+        fprintf(stderr, "cannot unshare namespaces: %s\n", strerror(errno));
+        exit(1);
+    }
+    if (joinedPID || (unshareFlags & CLONE_NEWPID)) {
+        forkIntoPIDNamespace();
     }
-
-    joinNamespaces(startNS, endNS);
-
-    printf("done with nsenter!\n");
-    fflush(stdout);
 }
\ No newline at end of file
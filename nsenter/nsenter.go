@@ -1,7 +1,6 @@
 package nsenter
 
 /*
-#cgo LDFLAGS: -lcap
 extern void nsexec();
 void __attribute__((constructor)) init(void) {
 	nsexec();
@@ -0,0 +1,366 @@
+package seccomp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"runtime"
+	"strconv"
+	"strings"
+	"unsafe"
+)
+
+// seccomp filter return values
+const (
+	retKillProcess = 0x80000000
+	retKillThread  = 0x00000000
+	retTrap        = 0x00030000
+	retErrno       = 0x00050000
+	retTrace       = 0x7ff00000
+	retLog         = 0x7ffc0000
+	retAllow       = 0x7fff0000
+)
+
+// seccomp_data offsets
+const (
+	offsetNr   = 0
+	offsetArch = 4
+	offsetArgs = 16
+)
+
+const (
+	maxInstructions = 4096 // BPF_MAXINSNS
+	maxJump         = 255  // conditional jump offsets are 8 bits wide
+	namesPerBlock   = 200  // syscalls compared before jumping to the rule action
+)
+
+// Compile compiles a profile into a BPF program for the native architecture. Rules are filtered by the container
+// capabilities (names without the CAP_ prefix), unknown syscalls are skipped.
+func Compile(profile *Profile, capabilities []string) ([]unix.SockFilter, error) {
+	if err := profile.validate(); err != nil {
+		return nil, err
+	}
+	if !profile.supportsNative() {
+		return nil, fmt.Errorf("seccomp profile doesn't support %s", nativeArchName)
+	}
+	kernel, err := kernelVersion()
+	if err != nil {
+		return nil, err
+	}
+	caps := map[string]bool{}
+	for _, c := range capabilities {
+		caps[strings.TrimPrefix(strings.ToUpper(c), "CAP_")] = true
+	}
+
+	a := newAssembler()
+	a.load(offsetArch)
+	a.jump(unix.BPF_JEQ, nativeArch, "arch", "")
+	a.ret(retKillProcess)
+	a.label("arch")
+	a.load(offsetNr)
+	if x32Bit != 0 {
+		a.jump(unix.BPF_JSET, x32Bit, "", "native")
+		a.ret(retKillProcess)
+		a.label("native")
+	}
+
+	for i, rule := range profile.Syscalls {
+		if !rule.Includes.matches(caps, kernel, true) || rule.Excludes.matches(caps, kernel, false) {
+			continue
+		}
+		ret, _ := action(rule.Action, rule.ErrnoRet) // validated
+		numbers := make([]uint32, 0, len(rule.names()))
+		for _, name := range rule.names() {
+			if nr, ok := syscalls[name]; ok {
+				numbers = append(numbers, nr)
+			}
+		}
+		if len(rule.Args) == 0 {
+			a.names(fmt.Sprintf("rule%d", i), numbers, ret)
+		} else {
+			for j, nr := range numbers {
+				a.args(fmt.Sprintf("rule%d_%d", i, j), nr, rule.Args, ret)
+			}
+		}
+	}
+
+	ret, _ := action(profile.DefaultAction, profile.DefaultErrnoRet)
+	a.ret(ret)
+	return a.assemble()
+}
+
+// Install installs a compiled program for the current thread, it's inherited by its children. The thread has to have
+// no_new_privs set or CAP_SYS_ADMIN.
+func Install(filter []unix.SockFilter) error {
+	if len(filter) == 0 {
+		return errors.New("empty seccomp filter")
+	}
+	program := unix.SockFprog{Len: uint16(len(filter)), Filter: &filter[0]}
+	err := unix.Prctl(unix.PR_SET_SECCOMP, unix.SECCOMP_MODE_FILTER, uintptr(unsafe.Pointer(&program)), 0, 0)
+	runtime.KeepAlive(filter)
+	if err != nil {
+		return fmt.Errorf("cannot install seccomp filter: %w", err)
+	}
+	return nil
+}
+
+func action(name string, errnoRet *uint) (uint32, error) {
+	data := uint32(unix.EPERM)
+	if errnoRet != nil {
+		if *errnoRet > 0xffff {
+			return 0, fmt.Errorf("invalid seccomp errno %d", *errnoRet)
+		}
+		data = uint32(*errnoRet)
+	}
+	switch name {
+	case ActAllow:
+		return retAllow, nil
+	case ActErrno:
+		return retErrno | data, nil
+	case ActKill, ActKillThread:
+		return retKillThread, nil
+	case ActKillProcess:
+		return retKillProcess, nil
+	case ActTrap:
+		return retTrap, nil
+	case ActTrace:
+		return retTrace | data, nil
+	case ActLog:
+		return retLog, nil
+	}
+	return 0, fmt.Errorf("unknown seccomp action %q", name)
+}
+
+func (p *Profile) supportsNative() bool {
+	if len(p.Architectures) == 0 && len(p.ArchMap) == 0 {
+		return true
+	}
+	for _, arch := range p.Architectures {
+		if arch == nativeArchName {
+			return true
+		}
+	}
+	for _, arch := range p.ArchMap {
+		if arch.Architecture == nativeArchName {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether the container matches the filter, an empty filter matches if empty is true.
+func (f Filter) matches(caps map[string]bool, kernel [2]int, empty bool) bool {
+	if len(f.Caps) == 0 && len(f.Arches) == 0 && f.MinKernel == "" {
+		return empty
+	}
+	if empty { // includes, everything has to match
+		for _, c := range f.Caps {
+			if !caps[strings.TrimPrefix(c, "CAP_")] {
+				return false
+			}
+		}
+		if len(f.Arches) > 0 && !contains(f.Arches, runtime.GOARCH) {
+			return false
+		}
+		if f.MinKernel != "" {
+			minimum, _ := parseKernelVersion(f.MinKernel) // validated
+			return !olderKernel(kernel, minimum)
+		}
+		return true
+	}
+	// excludes, anything matching excludes the rule
+	for _, c := range f.Caps {
+		if caps[strings.TrimPrefix(c, "CAP_")] {
+			return true
+		}
+	}
+	if contains(f.Arches, runtime.GOARCH) {
+		return true
+	}
+	if f.MinKernel != "" {
+		minimum, err := parseKernelVersion(f.MinKernel)
+		return err == nil && !olderKernel(kernel, minimum)
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func kernelVersion() ([2]int, error) {
+	var uname unix.Utsname
+	if err := unix.Uname(&uname); err != nil {
+		return [2]int{}, err
+	}
+	release := uname.Release[:]
+	if end := bytes.IndexByte(release, 0); end != -1 {
+		release = release[:end]
+	}
+	return parseKernelVersion(string(release))
+}
+
+// parseKernelVersion parses the major & minor version of kernel releases, e.g. 5.4.0-42-generic.
+func parseKernelVersion(release string) ([2]int, error) {
+	var version [2]int
+	parts := strings.SplitN(release, ".", 3)
+	if len(parts) < 2 {
+		return version, fmt.Errorf("invalid kernel version %q", release)
+	}
+	for i := range version {
+		end := strings.IndexFunc(parts[i], func(r rune) bool { return r < '0' || r > '9' })
+		if end == -1 {
+			end = len(parts[i])
+		}
+		number, err := strconv.Atoi(parts[i][:end])
+		if err != nil {
+			return version, fmt.Errorf("invalid kernel version %q", release)
+		}
+		version[i] = number
+	}
+	return version, nil
+}
+
+func olderKernel(kernel, than [2]int) bool {
+	return kernel[0] < than[0] || (kernel[0] == than[0] && kernel[1] < than[1])
+}
+
+// assembler builds a BPF program with jumps to labels, resolved once the program is complete.
+type assembler struct {
+	program []unix.SockFilter
+	labels  map[string]int
+	jumps   map[int][2]string // instruction index -> true & false jump labels, empty labels don't jump
+}
+
+func newAssembler() *assembler {
+	return &assembler{labels: map[string]int{}, jumps: map[int][2]string{}}
+}
+
+func (a *assembler) label(name string) {
+	a.labels[name] = len(a.program)
+}
+
+func (a *assembler) load(offset uint32) {
+	a.program = append(a.program, unix.SockFilter{Code: unix.BPF_LD | unix.BPF_W | unix.BPF_ABS, K: offset})
+}
+
+func (a *assembler) and(mask uint32) {
+	a.program = append(a.program, unix.SockFilter{Code: unix.BPF_ALU | unix.BPF_AND | unix.BPF_K, K: mask})
+}
+
+func (a *assembler) jump(op uint16, value uint32, jumpTrue, jumpFalse string) {
+	a.jumps[len(a.program)] = [2]string{jumpTrue, jumpFalse}
+	a.program = append(a.program, unix.SockFilter{Code: unix.BPF_JMP | op | unix.BPF_K, K: value})
+}
+
+func (a *assembler) ret(value uint32) {
+	a.program = append(a.program, unix.SockFilter{Code: unix.BPF_RET | unix.BPF_K, K: value})
+}
+
+// names returns ret for any of the syscall numbers.
+func (a *assembler) names(name string, numbers []uint32, ret uint32) {
+	for start := 0; start < len(numbers); start += namesPerBlock {
+		end := start + namesPerBlock
+		if end > len(numbers) {
+			end = len(numbers)
+		}
+		match, next := fmt.Sprintf("%s_%d_match", name, start), fmt.Sprintf("%s_%d_next", name, start)
+		a.load(offsetNr)
+		for i, nr := range numbers[start:end] {
+			jumpFalse := ""
+			if start+i == end-1 {
+				jumpFalse = next
+			}
+			a.jump(unix.BPF_JEQ, nr, match, jumpFalse)
+		}
+		a.label(match)
+		a.ret(ret)
+		a.label(next)
+	}
+}
+
+// args returns ret for the syscall number if all argument conditions hold, 64-bit arguments are compared by halves.
+func (a *assembler) args(name string, nr uint32, args []*Arg, ret uint32) {
+	next := name + "_next"
+	a.load(offsetNr)
+	a.jump(unix.BPF_JEQ, nr, "", next)
+	for i, arg := range args {
+		ok := fmt.Sprintf("%s_%d_ok", name, i)
+		low, high := offsetArgs+8*uint32(arg.Index), offsetArgs+8*uint32(arg.Index)+4
+		value := arg.Value
+		if arg.Op == OpMaskedEqual {
+			value = arg.ValueTwo
+		}
+		valueLow, valueHigh := uint32(value), uint32(value>>32)
+
+		a.load(high)
+		switch arg.Op {
+		case OpEqualTo:
+			a.jump(unix.BPF_JEQ, valueHigh, "", next)
+			a.load(low)
+			a.jump(unix.BPF_JEQ, valueLow, "", next)
+		case OpNotEqual:
+			a.jump(unix.BPF_JEQ, valueHigh, "", ok)
+			a.load(low)
+			a.jump(unix.BPF_JEQ, valueLow, next, "")
+		case OpMaskedEqual:
+			a.and(uint32(arg.Value >> 32))
+			a.jump(unix.BPF_JEQ, valueHigh, "", next)
+			a.load(low)
+			a.and(uint32(arg.Value))
+			a.jump(unix.BPF_JEQ, valueLow, "", next)
+		case OpGreaterThan, OpGreaterEqual:
+			a.jump(unix.BPF_JGT, valueHigh, ok, "")
+			a.jump(unix.BPF_JEQ, valueHigh, "", next)
+			a.load(low)
+			if arg.Op == OpGreaterThan {
+				a.jump(unix.BPF_JGT, valueLow, "", next)
+			} else {
+				a.jump(unix.BPF_JGE, valueLow, "", next)
+			}
+		case OpLessThan, OpLessEqual:
+			a.jump(unix.BPF_JGT, valueHigh, next, "")
+			a.jump(unix.BPF_JEQ, valueHigh, "", ok)
+			a.load(low)
+			if arg.Op == OpLessThan {
+				a.jump(unix.BPF_JGE, valueLow, next, "")
+			} else {
+				a.jump(unix.BPF_JGT, valueLow, next, "")
+			}
+		}
+		a.label(ok)
+	}
+	a.ret(ret)
+	a.label(next)
+}
+
+func (a *assembler) assemble() ([]unix.SockFilter, error) {
+	if len(a.program) > maxInstructions {
+		return nil, fmt.Errorf("seccomp program too large (%d instructions)", len(a.program))
+	}
+	for i, labels := range a.jumps {
+		var offsets [2]uint8
+		for j, label := range labels {
+			if label == "" {
+				continue
+			}
+			target, ok := a.labels[label]
+			if !ok {
+				return nil, fmt.Errorf("unknown seccomp label %q", label)
+			}
+			offset := target - (i + 1)
+			if offset < 0 || offset > maxJump {
+				return nil, fmt.Errorf("seccomp jump to %q out of range", label)
+			}
+			offsets[j] = uint8(offset)
+		}
+		a.program[i].Jt, a.program[i].Jf = offsets[0], offsets[1]
+	}
+	return a.program, nil
+}
@@ -0,0 +1,332 @@
+package seccomp
+
+import (
+	"golang.org/x/sys/unix"
+	"sort"
+	"testing"
+)
+
+// seccompData returns the 32-bit words of a native seccomp_data, arguments are little endian.
+func seccompData(arch, nr uint32, args ...uint64) [16]uint32 {
+	var data [16]uint32
+	data[offsetNr/4], data[offsetArch/4] = nr, arch
+	for i, arg := range args {
+		data[offsetArgs/4+2*i], data[offsetArgs/4+2*i+1] = uint32(arg), uint32(arg>>32)
+	}
+	return data
+}
+
+// run runs a program on seccomp_data like the kernel does, it only knows the instructions Compile emits.
+func run(t *testing.T, program []unix.SockFilter, data [16]uint32) uint32 {
+	t.Helper()
+	var a uint32
+	for pc := 0; pc < len(program); pc++ {
+		instruction := program[pc]
+		if instruction.Code&0x07 == unix.BPF_JMP {
+			var jump bool
+			switch instruction.Code {
+			case unix.BPF_JMP | unix.BPF_JEQ | unix.BPF_K:
+				jump = a == instruction.K
+			case unix.BPF_JMP | unix.BPF_JGT | unix.BPF_K:
+				jump = a > instruction.K
+			case unix.BPF_JMP | unix.BPF_JGE | unix.BPF_K:
+				jump = a >= instruction.K
+			case unix.BPF_JMP | unix.BPF_JSET | unix.BPF_K:
+				jump = a&instruction.K != 0
+			default:
+				t.Fatalf("unexpected jump %#x at %d", instruction.Code, pc)
+			}
+			if jump {
+				pc += int(instruction.Jt)
+			} else {
+				pc += int(instruction.Jf)
+			}
+			continue
+		}
+		switch instruction.Code {
+		case unix.BPF_LD | unix.BPF_W | unix.BPF_ABS:
+			if instruction.K%4 != 0 || instruction.K >= 64 {
+				t.Fatalf("invalid load offset %d at %d", instruction.K, pc)
+			}
+			a = data[instruction.K/4]
+		case unix.BPF_ALU | unix.BPF_AND | unix.BPF_K:
+			a &= instruction.K
+		case unix.BPF_RET | unix.BPF_K:
+			return instruction.K
+		default:
+			t.Fatalf("unexpected instruction %#x at %d", instruction.Code, pc)
+		}
+	}
+	t.Fatal("program doesn't return")
+	return 0
+}
+
+func errnoRet(errno uint) *uint {
+	return &errno
+}
+
+func TestCompile(t *testing.T) {
+	type call struct {
+		name     string
+		args     []uint64
+		expected uint32
+	}
+	const large = 0x100000005 // the halves of 64-bit arguments are compared separately
+
+	tests := []struct {
+		name         string
+		profile      *Profile
+		capabilities []string
+		calls        []call
+	}{
+		{
+			name: "allow list",
+			profile: &Profile{
+				DefaultAction: ActErrno,
+				Syscalls:      []*Syscalls{{Names: []string{"read", "write"}, Action: ActAllow}},
+			},
+			calls: []call{
+				{"read", nil, retAllow},
+				{"write", nil, retAllow},
+				{"getpid", nil, retErrno | uint32(unix.EPERM)},
+			},
+		},
+		{
+			name: "errno",
+			profile: &Profile{
+				DefaultAction:   ActErrno,
+				DefaultErrnoRet: errnoRet(uint(unix.ENOSYS)),
+				Syscalls: []*Syscalls{
+					{Name: "ptrace", Action: ActErrno, ErrnoRet: errnoRet(uint(unix.EACCES))},
+					{Names: []string{"getpid"}, Action: ActLog},
+				},
+			},
+			calls: []call{
+				{"ptrace", nil, retErrno | uint32(unix.EACCES)},
+				{"getpid", nil, retLog},
+				{"read", nil, retErrno | uint32(unix.ENOSYS)},
+			},
+		},
+		{
+			name: "first rule wins",
+			profile: &Profile{
+				DefaultAction: ActAllow,
+				Syscalls: []*Syscalls{
+					{Names: []string{"getpid"}, Action: ActTrap},
+					{Names: []string{"getpid", "read"}, Action: ActKillProcess},
+				},
+			},
+			calls: []call{
+				{"getpid", nil, retTrap},
+				{"read", nil, retKillProcess},
+				{"write", nil, retAllow},
+			},
+		},
+		{
+			name:    "default profile",
+			profile: DefaultProfile(),
+			calls: []call{
+				{"mount", nil, retErrno | uint32(unix.EPERM)},
+				{"ptrace", nil, retErrno | uint32(unix.EPERM)},
+				{"getpid", nil, retAllow},
+			},
+		},
+		{
+			name:         "capabilities",
+			profile:      DefaultProfile(),
+			capabilities: []string{"CAP_SYS_ADMIN", "sys_ptrace"},
+			calls: []call{
+				{"mount", nil, retAllow},
+				{"ptrace", nil, retAllow},
+				{"reboot", nil, retErrno | uint32(unix.EPERM)},
+			},
+		},
+		{
+			name: "equal",
+			profile: &Profile{
+				DefaultAction: ActErrno,
+				Syscalls: []*Syscalls{{Names: []string{"personality"}, Action: ActAllow, Args: []*Arg{
+					{Index: 0, Value: large, Op: OpEqualTo},
+				}}},
+			},
+			calls: []call{
+				{"personality", []uint64{large}, retAllow},
+				{"personality", []uint64{5}, retErrno | uint32(unix.EPERM)},
+				{"personality", []uint64{0x200000005}, retErrno | uint32(unix.EPERM)},
+			},
+		},
+		{
+			name: "not equal",
+			profile: &Profile{
+				DefaultAction: ActErrno,
+				Syscalls: []*Syscalls{{Names: []string{"personality"}, Action: ActAllow, Args: []*Arg{
+					{Index: 0, Value: large, Op: OpNotEqual},
+				}}},
+			},
+			calls: []call{
+				{"personality", []uint64{large}, retErrno | uint32(unix.EPERM)},
+				{"personality", []uint64{5}, retAllow},
+				{"personality", []uint64{0x100000006}, retAllow},
+			},
+		},
+		{
+			name: "less than",
+			profile: &Profile{
+				DefaultAction: ActErrno,
+				Syscalls: []*Syscalls{{Names: []string{"personality"}, Action: ActAllow, Args: []*Arg{
+					{Index: 0, Value: large, Op: OpLessThan},
+				}}},
+			},
+			calls: []call{
+				{"personality", []uint64{0xffffffff}, retAllow},
+				{"personality", []uint64{large - 1}, retAllow},
+				{"personality", []uint64{large}, retErrno | uint32(unix.EPERM)},
+				{"personality", []uint64{0x200000000}, retErrno | uint32(unix.EPERM)},
+			},
+		},
+		{
+			name: "less or equal",
+			profile: &Profile{
+				DefaultAction: ActErrno,
+				Syscalls: []*Syscalls{{Names: []string{"personality"}, Action: ActAllow, Args: []*Arg{
+					{Index: 0, Value: large, Op: OpLessEqual},
+				}}},
+			},
+			calls: []call{
+				{"personality", []uint64{6}, retAllow},
+				{"personality", []uint64{large}, retAllow},
+				{"personality", []uint64{large + 1}, retErrno | uint32(unix.EPERM)},
+				{"personality", []uint64{0x200000000}, retErrno | uint32(unix.EPERM)},
+			},
+		},
+		{
+			name: "greater than",
+			profile: &Profile{
+				DefaultAction: ActErrno,
+				Syscalls: []*Syscalls{{Names: []string{"personality"}, Action: ActAllow, Args: []*Arg{
+					{Index: 0, Value: large, Op: OpGreaterThan},
+				}}},
+			},
+			calls: []call{
+				{"personality", []uint64{6}, retErrno | uint32(unix.EPERM)},
+				{"personality", []uint64{large}, retErrno | uint32(unix.EPERM)},
+				{"personality", []uint64{large + 1}, retAllow},
+				{"personality", []uint64{0x200000000}, retAllow},
+			},
+		},
+		{
+			name: "greater or equal",
+			profile: &Profile{
+				DefaultAction: ActErrno,
+				Syscalls: []*Syscalls{{Names: []string{"personality"}, Action: ActAllow, Args: []*Arg{
+					{Index: 0, Value: large, Op: OpGreaterEqual},
+				}}},
+			},
+			calls: []call{
+				{"personality", []uint64{0xffffffff}, retErrno | uint32(unix.EPERM)},
+				{"personality", []uint64{large - 1}, retErrno | uint32(unix.EPERM)},
+				{"personality", []uint64{large}, retAllow},
+				{"personality", []uint64{0x200000000}, retAllow},
+			},
+		},
+		{
+			name: "masked equal",
+			profile: &Profile{
+				DefaultAction: ActAllow,
+				Syscalls: []*Syscalls{{Names: []string{"clone"}, Action: ActErrno, Args: []*Arg{
+					{Index: 0, Value: unix.CLONE_NEWUSER | 0x100000000, ValueTwo: unix.CLONE_NEWUSER, Op: OpMaskedEqual},
+				}}},
+			},
+			calls: []call{
+				{"clone", []uint64{unix.CLONE_NEWUSER | unix.CLONE_NEWNS}, retErrno | uint32(unix.EPERM)},
+				{"clone", []uint64{unix.CLONE_NEWUSER | 0x100000000}, retAllow},
+				{"clone", []uint64{unix.CLONE_NEWNS}, retAllow},
+			},
+		},
+		{
+			name: "all conditions",
+			profile: &Profile{
+				DefaultAction: ActErrno,
+				Syscalls: []*Syscalls{{Names: []string{"write"}, Action: ActAllow, Args: []*Arg{
+					{Index: 0, Value: 1, Op: OpEqualTo},
+					{Index: 2, Value: 4096, Op: OpLessEqual},
+				}}},
+			},
+			calls: []call{
+				{"write", []uint64{1, 0, 100}, retAllow},
+				{"write", []uint64{2, 0, 100}, retErrno | uint32(unix.EPERM)},
+				{"write", []uint64{1, 0, 8192}, retErrno | uint32(unix.EPERM)},
+			},
+		},
+	}
+	for _, test := range tests {
+		program, err := Compile(test.profile, test.capabilities)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		for _, c := range test.calls {
+			nr, ok := syscalls[c.name]
+			if !ok {
+				t.Fatalf("%s: unknown syscall %s", test.name, c.name)
+			}
+			if ret := run(t, program, seccompData(nativeArch, nr, c.args...)); ret != c.expected {
+				t.Errorf("%s: %s%v returned %#x, expected %#x", test.name, c.name, c.args, ret, c.expected)
+			}
+		}
+	}
+}
+
+func TestCompileManySyscalls(t *testing.T) {
+	names := make([]string, 0, len(syscalls))
+	for name := range syscalls {
+		if name != "getpid" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) <= namesPerBlock {
+		t.Fatalf("only %d syscalls, the rule fits in a block", len(names))
+	}
+
+	program, err := Compile(&Profile{
+		DefaultAction: ActKillProcess,
+		Syscalls:      []*Syscalls{{Names: names, Action: ActAllow}},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		if ret := run(t, program, seccompData(nativeArch, syscalls[name])); ret != retAllow {
+			t.Errorf("%s returned %#x, expected %#x", name, ret, retAllow)
+		}
+	}
+	if ret := run(t, program, seccompData(nativeArch, syscalls["getpid"])); ret != retKillProcess {
+		t.Errorf("getpid returned %#x, expected %#x", ret, retKillProcess)
+	}
+}
+
+func TestCompileArchitecture(t *testing.T) {
+	program, err := Compile(&Profile{DefaultAction: ActAllow, Architectures: []string{nativeArchName}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ret := run(t, program, seccompData(nativeArch, syscalls["getpid"])); ret != retAllow {
+		t.Errorf("native syscall returned %#x, expected %#x", ret, retAllow)
+	}
+	if ret := run(t, program, seccompData(0x40000003, syscalls["getpid"])); ret != retKillProcess { // i386
+		t.Errorf("foreign syscall returned %#x, expected %#x", ret, retKillProcess)
+	}
+	if x32Bit != 0 {
+		if ret := run(t, program, seccompData(nativeArch, x32Bit|syscalls["getpid"])); ret != retKillProcess {
+			t.Errorf("x32 syscall returned %#x, expected %#x", ret, retKillProcess)
+		}
+	}
+
+	if _, err := Compile(&Profile{DefaultAction: ActAllow, Architectures: []string{"SCMP_ARCH_MIPS"}}, nil); err == nil {
+		t.Error("Compile didn't fail on a profile without the native architecture")
+	}
+	if _, err := Compile(&Profile{DefaultAction: "SCMP_ACT_UNKNOWN"}, nil); err == nil {
+		t.Error("Compile didn't fail on an unknown action")
+	}
+}
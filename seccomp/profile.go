@@ -0,0 +1,150 @@
+package seccomp
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Unconfined disables syscall filtering instead of a profile.
+const Unconfined = "unconfined"
+
+const (
+	ActAllow       = "SCMP_ACT_ALLOW"
+	ActErrno       = "SCMP_ACT_ERRNO"
+	ActKill        = "SCMP_ACT_KILL" // kills the thread
+	ActKillThread  = "SCMP_ACT_KILL_THREAD"
+	ActKillProcess = "SCMP_ACT_KILL_PROCESS"
+	ActTrap        = "SCMP_ACT_TRAP"
+	ActTrace       = "SCMP_ACT_TRACE"
+	ActLog         = "SCMP_ACT_LOG"
+
+	OpNotEqual     = "SCMP_CMP_NE"
+	OpLessThan     = "SCMP_CMP_LT"
+	OpLessEqual    = "SCMP_CMP_LE"
+	OpEqualTo      = "SCMP_CMP_EQ"
+	OpGreaterEqual = "SCMP_CMP_GE"
+	OpGreaterThan  = "SCMP_CMP_GT"
+	OpMaskedEqual  = "SCMP_CMP_MASKED_EQ" // arg & value == valueTwo
+)
+
+// Profile is a seccomp profile in the OCI/Docker JSON format. Syscalls are matched against the rules in order, the
+// first matching rule decides, the default action applies if none does.
+type Profile struct {
+	DefaultAction   string      `json:"defaultAction"`
+	DefaultErrnoRet *uint       `json:"defaultErrnoRet,omitempty"` // EPERM if not set
+	Architectures   []string    `json:"architectures,omitempty"`
+	ArchMap         []ArchMap   `json:"archMap,omitempty"`
+	Syscalls        []*Syscalls `json:"syscalls"`
+}
+
+// ArchMap lists architectures supported by a profile, only the native architecture is filtered. Syscalls of other
+// architectures (e.g. 32-bit binaries) kill the process.
+type ArchMap struct {
+	Architecture     string   `json:"architecture"`
+	SubArchitectures []string `json:"subArchitectures"`
+}
+
+// Syscalls is a rule applying an action to syscalls whose arguments match all conditions.
+type Syscalls struct {
+	Names    []string `json:"names,omitempty"`
+	Name     string   `json:"name,omitempty"` // older profiles have a rule per syscall
+	Action   string   `json:"action"`
+	ErrnoRet *uint    `json:"errnoRet,omitempty"`
+	Args     []*Arg   `json:"args,omitempty"`
+	Includes Filter   `json:"includes,omitempty"`
+	Excludes Filter   `json:"excludes,omitempty"`
+}
+
+// Arg is a condition on a syscall argument.
+type Arg struct {
+	Index    uint   `json:"index"`
+	Value    uint64 `json:"value"`
+	ValueTwo uint64 `json:"valueTwo,omitempty"`
+	Op       string `json:"op"`
+}
+
+// Filter restricts a rule to containers with the capabilities, on the architectures and kernel versions.
+type Filter struct {
+	Caps      []string `json:"caps,omitempty"`
+	Arches    []string `json:"arches,omitempty"`
+	MinKernel string   `json:"minKernel,omitempty"`
+}
+
+// Parse parses and validates a JSON profile.
+func Parse(data []byte) (*Profile, error) {
+	var profile Profile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return nil, fmt.Errorf("cannot parse seccomp profile: %w", err)
+	}
+	if err := profile.validate(); err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+func (p *Profile) validate() error {
+	if _, err := action(p.DefaultAction, p.DefaultErrnoRet); err != nil {
+		return fmt.Errorf("invalid default action: %w", err)
+	}
+	for _, rule := range p.Syscalls {
+		if _, err := action(rule.Action, rule.ErrnoRet); err != nil {
+			return err
+		}
+		if len(rule.Names) == 0 && rule.Name == "" {
+			return fmt.Errorf("seccomp rule without syscall names")
+		}
+		for _, arg := range rule.Args {
+			if arg.Index > 5 {
+				return fmt.Errorf("invalid syscall argument index %d", arg.Index)
+			}
+			switch arg.Op {
+			case OpNotEqual, OpLessThan, OpLessEqual, OpEqualTo, OpGreaterEqual, OpGreaterThan, OpMaskedEqual:
+			default:
+				return fmt.Errorf("unknown seccomp operator %q", arg.Op)
+			}
+		}
+		if rule.Includes.MinKernel != "" {
+			if _, err := parseKernelVersion(rule.Includes.MinKernel); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Syscalls) names() []string {
+	if s.Name != "" {
+		return append([]string{s.Name}, s.Names...)
+	}
+	return s.Names
+}
+
+// DefaultProfile allows everything except syscalls that can be used to escape or affect the host, unless the container
+// has the capability they need anyway.
+func DefaultProfile() *Profile {
+	deny := func(capability string, names ...string) *Syscalls {
+		rule := &Syscalls{Names: names, Action: ActErrno}
+		if capability != "" {
+			rule.Excludes.Caps = []string{capability}
+		}
+		return rule
+	}
+	return &Profile{
+		DefaultAction: ActAllow,
+		Syscalls: []*Syscalls{
+			deny("", "kexec_load", "kexec_file_load", "open_by_handle_at", "nfsservctl", "uselib", "lookup_dcookie",
+				"add_key", "request_key", "keyctl", "userfaultfd", "vm86", "vm86old", "create_module",
+				"get_kernel_syms", "query_module", "_sysctl"),
+			deny("CAP_SYS_ADMIN", "mount", "umount", "umount2", "pivot_root", "unshare", "setns", "swapon",
+				"swapoff", "quotactl", "bpf", "perf_event_open", "fanotify_init", "name_to_handle_at", "open_tree",
+				"move_mount", "fsopen", "fsconfig", "fsmount", "fspick", "mount_setattr"),
+			deny("CAP_SYS_PTRACE", "ptrace", "process_vm_readv", "process_vm_writev", "kcmp"),
+			deny("CAP_SYS_MODULE", "init_module", "finit_module", "delete_module"),
+			deny("CAP_SYS_BOOT", "reboot"),
+			deny("CAP_SYS_TIME", "settimeofday", "stime", "clock_settime", "clock_adjtime", "adjtimex"),
+			deny("CAP_SYS_RAWIO", "iopl", "ioperm"),
+			deny("CAP_SYS_PACCT", "acct"),
+			deny("CAP_SYSLOG", "syslog"),
+		},
+	}
+}
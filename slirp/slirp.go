@@ -0,0 +1,280 @@
+package slirp
+
+import (
+	"cont/network"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang.org/x/sys/unix"
+	"log"
+	"net"
+	"os"
+	"sync"
+)
+
+// Addresses of the virtual network, the same as QEMU user networking uses.
+var (
+	Subnet    = &net.IPNet{IP: net.IPv4(10, 0, 2, 0).To4(), Mask: net.CIDRMask(24, 32)}
+	GatewayIP = net.IPv4(10, 0, 2, 2).To4() // the daemon host, connections to it go to the host loopback if allowed
+	DNSIP     = net.IPv4(10, 0, 2, 3).To4() // forwarded to the host nameserver
+	GuestIP   = net.IPv4(10, 0, 2, 100).To4()
+
+	GatewayMAC = net.HardwareAddr{0x52, 0x55, 0x0a, 0x00, 0x02, 0x02}
+	GuestMAC   = net.HardwareAddr{0x52, 0x55, 0x0a, 0x00, 0x02, 0x64}
+)
+
+const (
+	MTU = 1500
+
+	etherHeaderLen = 14
+	etherTypeIPv4  = 0x0800
+	etherTypeARP   = 0x0806
+	ipHeaderLen    = 20
+	protocolICMP   = 1
+	protocolTCP    = 6
+	protocolUDP    = 17
+	defaultTTL     = 64
+
+	fallbackDNS = "8.8.8.8"
+)
+
+// errHostLoopback is returned for guest connections to the gateway when the host loopback isn't reachable.
+var errHostLoopback = errors.New("host loopback access is disabled")
+
+// Options configure a stack.
+type Options struct {
+	// AllowHostLoopback relays connections to the gateway to the host loopback. Services listening on the daemon host
+	// loopback aren't meant to be reachable from containers, so it's disabled by default.
+	AllowHostLoopback bool
+}
+
+// Stack is a userspace TCP/IP stack serving a container through a TAP device, guest connections are relayed through
+// sockets of the daemon.
+type Stack struct {
+	tap          *os.File
+	dns          string // host nameserver address
+	hostLoopback bool   // connections to the gateway are relayed to the host loopback
+	writeMutex   sync.Mutex
+	mutex        sync.Mutex
+	ipID         uint16
+	tcp          map[flow]*tcpConn
+	udp          map[flow]*udpFlow
+	closed       bool
+}
+
+// flow identifies a guest connection, addresses are from the guest point of view.
+type flow struct {
+	localIP, remoteIP     [4]byte
+	localPort, remotePort uint16
+}
+
+// New creates a stack for a TAP device (without packet information), Run serves it.
+func New(tap *os.File, options Options) *Stack {
+	return &Stack{
+		tap:          tap,
+		dns:          hostNameserver(),
+		hostLoopback: options.AllowHostLoopback,
+		tcp:          make(map[flow]*tcpConn),
+		udp:          make(map[flow]*udpFlow),
+	}
+}
+
+// Run serves guest frames until the TAP device goes away or the stack is closed.
+func (s *Stack) Run() error {
+	frame := make([]byte, etherHeaderLen+MTU)
+	for {
+		n, err := s.tap.Read(frame)
+		if err != nil {
+			if s.isClosed() || errors.Is(err, unix.EBADFD) { // EBADFD: the container network namespace is gone
+				return nil
+			}
+			return fmt.Errorf("cannot read from TAP device: %w", err)
+		}
+		s.handleFrame(frame[:n])
+	}
+}
+
+// Close closes the TAP device and all connections.
+func (s *Stack) Close() error {
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	tcp, udp := s.tcp, s.udp
+	s.tcp, s.udp = make(map[flow]*tcpConn), make(map[flow]*udpFlow)
+	s.mutex.Unlock()
+
+	for _, c := range tcp {
+		c.close()
+	}
+	for _, f := range udp {
+		f.close()
+	}
+	return s.tap.Close()
+}
+
+func (s *Stack) isClosed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
+func (s *Stack) handleFrame(frame []byte) {
+	if len(frame) < etherHeaderLen {
+		return
+	}
+	payload := frame[etherHeaderLen:]
+	switch binary.BigEndian.Uint16(frame[12:14]) {
+	case etherTypeARP:
+		s.handleARP(payload)
+	case etherTypeIPv4:
+		s.handleIPv4(payload)
+	}
+}
+
+// handleARP answers requests for gateway addresses, the guest MAC is fixed.
+func (s *Stack) handleARP(packet []byte) {
+	const arpLen = 28
+	if len(packet) < arpLen || binary.BigEndian.Uint16(packet[6:8]) != 1 { // request
+		return
+	}
+	var target [4]byte
+	copy(target[:], packet[24:28])
+	if !isGateway(target) {
+		return
+	}
+	reply := make([]byte, arpLen)
+	copy(reply[0:6], packet[0:6]) // hardware & protocol types and sizes
+	binary.BigEndian.PutUint16(reply[6:8], 2)
+	copy(reply[8:14], GatewayMAC)
+	copy(reply[14:18], target[:])
+	copy(reply[18:28], packet[8:18]) // sender becomes the target
+	s.writeFrame(etherTypeARP, packet[8:14], reply)
+}
+
+func (s *Stack) handleIPv4(packet []byte) {
+	if len(packet) < ipHeaderLen || packet[0]>>4 != 4 {
+		return
+	}
+	headerLen := int(packet[0]&0x0f) * 4
+	totalLen := int(binary.BigEndian.Uint16(packet[2:4]))
+	if headerLen < ipHeaderLen || totalLen < headerLen || totalLen > len(packet) {
+		return
+	}
+	if binary.BigEndian.Uint16(packet[6:8])&0x3fff != 0 { // fragments aren't reassembled, the MTU is the same
+		return
+	}
+	var src, dst [4]byte
+	copy(src[:], packet[12:16])
+	copy(dst[:], packet[16:20])
+	payload := packet[headerLen:totalLen]
+	switch packet[9] {
+	case protocolICMP:
+		s.handleICMP(src, dst, payload)
+	case protocolTCP:
+		s.handleTCP(src, dst, payload)
+	case protocolUDP:
+		s.handleUDP(src, dst, payload)
+	}
+}
+
+// handleICMP answers echo requests to the gateway addresses, unprivileged processes can't send ICMP elsewhere.
+func (s *Stack) handleICMP(src, dst [4]byte, packet []byte) {
+	if len(packet) < 8 || packet[0] != 8 || !isGateway(dst) {
+		return
+	}
+	reply := append([]byte(nil), packet...)
+	reply[0] = 0 // echo reply
+	reply[2], reply[3] = 0, 0
+	binary.BigEndian.PutUint16(reply[2:4], checksum(reply, 0))
+	s.writeIPv4(dst, src, protocolICMP, reply)
+}
+
+func isGateway(ip [4]byte) bool {
+	return net.IP(ip[:]).Equal(GatewayIP) || net.IP(ip[:]).Equal(DNSIP)
+}
+
+// remoteAddress returns the host address a guest destination is relayed to, the gateway is the host loopback if
+// allowed.
+func (s *Stack) remoteAddress(ip [4]byte, port uint16) (string, error) {
+	host := net.IP(ip[:]).String()
+	switch {
+	case net.IP(ip[:]).Equal(GatewayIP):
+		if !s.hostLoopback {
+			return "", errHostLoopback
+		}
+		host = "127.0.0.1"
+	case net.IP(ip[:]).Equal(DNSIP) && port == 53:
+		host = s.dns
+	}
+	return net.JoinHostPort(host, fmt.Sprint(port)), nil
+}
+
+func (s *Stack) writeIPv4(src, dst [4]byte, protocol byte, payload []byte) {
+	s.mutex.Lock()
+	s.ipID++
+	id := s.ipID
+	s.mutex.Unlock()
+
+	packet := make([]byte, ipHeaderLen+len(payload))
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:4], uint16(len(packet)))
+	binary.BigEndian.PutUint16(packet[4:6], id)
+	binary.BigEndian.PutUint16(packet[6:8], 0x4000) // don't fragment
+	packet[8] = defaultTTL
+	packet[9] = protocol
+	copy(packet[12:16], src[:])
+	copy(packet[16:20], dst[:])
+	binary.BigEndian.PutUint16(packet[10:12], checksum(packet[:ipHeaderLen], 0))
+	copy(packet[ipHeaderLen:], payload)
+	s.writeFrame(etherTypeIPv4, GuestMAC, packet)
+}
+
+func (s *Stack) writeFrame(etherType uint16, dst net.HardwareAddr, payload []byte) {
+	frame := make([]byte, etherHeaderLen+len(payload))
+	copy(frame[0:6], dst)
+	copy(frame[6:12], GatewayMAC)
+	binary.BigEndian.PutUint16(frame[12:14], etherType)
+	copy(frame[etherHeaderLen:], payload)
+
+	s.writeMutex.Lock()
+	defer s.writeMutex.Unlock()
+	if _, err := s.tap.Write(frame); err != nil && !s.isClosed() {
+		log.Printf("cannot write to TAP device: %v", err)
+	}
+}
+
+// checksum returns the internet checksum of data, added to an initial (pseudo header) sum.
+func checksum(data []byte, sum uint32) uint16 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i : i+2]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// pseudoHeaderSum returns the sum of the TCP/UDP pseudo header.
+func pseudoHeaderSum(src, dst [4]byte, protocol byte, length int) uint32 {
+	var sum uint32
+	sum += uint32(binary.BigEndian.Uint16(src[0:2])) + uint32(binary.BigEndian.Uint16(src[2:4]))
+	sum += uint32(binary.BigEndian.Uint16(dst[0:2])) + uint32(binary.BigEndian.Uint16(dst[2:4]))
+	sum += uint32(protocol) + uint32(length)
+	return sum
+}
+
+// hostNameserver returns the first IPv4 nameserver of the host.
+func hostNameserver() string {
+	for _, nameserver := range network.HostNameservers() {
+		if net.ParseIP(nameserver).To4() != nil {
+			return nameserver
+		}
+	}
+	return fallbackDNS
+}
@@ -0,0 +1,147 @@
+package slirp
+
+import (
+	"bytes"
+	"encoding/binary"
+	"golang.org/x/sys/unix"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestChecksum(t *testing.T) {
+	// IPv4 header with a zero checksum field
+	header := []byte{0x45, 0x00, 0x00, 0x73, 0x00, 0x00, 0x40, 0x00, 0x40, 0x11, 0x00, 0x00, 0xc0, 0xa8, 0x00, 0x01,
+		0xc0, 0xa8, 0x00, 0xc7}
+	sum := checksum(header, 0)
+	if sum != 0xb861 {
+		t.Errorf("checksum = %#x, expected 0xb861", sum)
+	}
+	binary.BigEndian.PutUint16(header[10:12], sum)
+	if verified := checksum(header, 0); verified != 0 {
+		t.Errorf("checksum of a header with its checksum = %#x, expected 0", verified)
+	}
+	if sum := checksum([]byte{0x01}, 0); sum != ^uint16(0x0100) {
+		t.Errorf("checksum of an odd length = %#x, expected the last byte padded", sum)
+	}
+}
+
+// tap returns a stack running on one end of a packet socket pair, the guest end is returned.
+func tap(t *testing.T, options Options) (*Stack, *os.File) {
+	t.Helper()
+	fds, err := unix.Socketpair(unix.AF_UNIX, unix.SOCK_SEQPACKET, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, fd := range fds {
+		if err := unix.SetNonblock(fd, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stack := New(os.NewFile(uintptr(fds[0]), "tap"), options)
+	go func() {
+		if err := stack.Run(); err != nil {
+			t.Errorf("Run: %v", err)
+		}
+	}()
+	return stack, os.NewFile(uintptr(fds[1]), "guest")
+}
+
+func frame(etherType uint16, payload []byte) []byte {
+	result := make([]byte, etherHeaderLen, etherHeaderLen+len(payload))
+	copy(result[0:6], GatewayMAC)
+	copy(result[6:12], GuestMAC)
+	binary.BigEndian.PutUint16(result[12:14], etherType)
+	return append(result, payload...)
+}
+
+func receive(t *testing.T, guest *os.File) []byte {
+	t.Helper()
+	if err := guest.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	buffer := make([]byte, etherHeaderLen+MTU)
+	n, err := guest.Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer[:n]
+}
+
+func TestGatewayARPAndEcho(t *testing.T) {
+	stack, guest := tap(t, Options{})
+	defer stack.Close()
+	defer guest.Close()
+
+	request := make([]byte, 28)
+	copy(request[0:6], []byte{0, 1, 8, 0, 6, 4}) // Ethernet, IPv4
+	binary.BigEndian.PutUint16(request[6:8], 1)
+	copy(request[8:14], GuestMAC)
+	copy(request[14:18], GuestIP)
+	copy(request[24:28], GatewayIP)
+	if _, err := guest.Write(frame(etherTypeARP, request)); err != nil {
+		t.Fatal(err)
+	}
+	reply := receive(t, guest)
+	if binary.BigEndian.Uint16(reply[12:14]) != etherTypeARP || binary.BigEndian.Uint16(reply[20:22]) != 2 {
+		t.Fatalf("expected an ARP reply, got %x", reply)
+	}
+	arp := reply[etherHeaderLen:]
+	if !bytes.Equal(arp[8:14], GatewayMAC) || !net.IP(arp[14:18]).Equal(GatewayIP) ||
+		!bytes.Equal(arp[18:24], GuestMAC) || !net.IP(arp[24:28]).Equal(GuestIP) {
+		t.Errorf("ARP reply %x doesn't resolve the gateway for the guest", arp)
+	}
+
+	echo := []byte{8, 0, 0, 0, 0x12, 0x34, 0, 1, 'p', 'i', 'n', 'g'}
+	binary.BigEndian.PutUint16(echo[2:4], checksum(echo, 0))
+	packet := make([]byte, ipHeaderLen, ipHeaderLen+len(echo))
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:4], uint16(ipHeaderLen+len(echo)))
+	packet[8] = defaultTTL
+	packet[9] = protocolICMP
+	copy(packet[12:16], GuestIP)
+	copy(packet[16:20], DNSIP)
+	if _, err := guest.Write(frame(etherTypeIPv4, append(packet, echo...))); err != nil {
+		t.Fatal(err)
+	}
+	reply = receive(t, guest)
+	ip := reply[etherHeaderLen:]
+	if binary.BigEndian.Uint16(reply[12:14]) != etherTypeIPv4 || ip[9] != protocolICMP {
+		t.Fatalf("expected an ICMP packet, got %x", reply)
+	}
+	if !net.IP(ip[12:16]).Equal(DNSIP) || !net.IP(ip[16:20]).Equal(GuestIP) || checksum(ip[:ipHeaderLen], 0) != 0 {
+		t.Errorf("invalid IP header of the echo reply %x", ip[:ipHeaderLen])
+	}
+	icmp := ip[ipHeaderLen:]
+	if icmp[0] != 0 || checksum(icmp, 0) != 0 || !bytes.Equal(icmp[4:], echo[4:]) {
+		t.Errorf("echo reply %x doesn't match the request %x", icmp, echo)
+	}
+}
+
+func TestRemoteAddress(t *testing.T) {
+	var gateway, dns, remote [4]byte
+	copy(gateway[:], GatewayIP)
+	copy(dns[:], DNSIP)
+	copy(remote[:], net.IPv4(192, 0, 2, 1).To4())
+
+	stack := &Stack{dns: "192.0.2.53"}
+	if address, err := stack.remoteAddress(gateway, 8080); err != errHostLoopback {
+		t.Errorf("remoteAddress of the gateway = %s, %v, expected %v", address, err, errHostLoopback)
+	}
+	stack.hostLoopback = true
+	for _, test := range []struct {
+		ip       [4]byte
+		port     uint16
+		expected string
+	}{
+		{gateway, 8080, "127.0.0.1:8080"},
+		{dns, 53, "192.0.2.53:53"},
+		{dns, 80, "10.0.2.3:80"},
+		{remote, 443, "192.0.2.1:443"},
+	} {
+		if address, err := stack.remoteAddress(test.ip, test.port); err != nil || address != test.expected {
+			t.Errorf("remoteAddress(%v, %d) = %s, %v, expected %s", test.ip, test.port, address, err, test.expected)
+		}
+	}
+}
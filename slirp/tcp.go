@@ -0,0 +1,522 @@
+package slirp
+
+import (
+	"encoding/binary"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	tcpHeaderLen = 20
+	maxSegment   = MTU - ipHeaderLen - tcpHeaderLen
+	maxWindow    = 65535 // window scaling isn't negotiated
+	queuedWrites = 64    // segments from the guest waiting to be written to the host connection
+
+	dialTimeout   = 10 * time.Second
+	minRetransmit = 200 * time.Millisecond
+	maxRetransmit = 10 * time.Second
+	maxRetries    = 10
+)
+
+const (
+	flagFIN = 1 << iota
+	flagSYN
+	flagRST
+	flagPSH
+	flagACK
+)
+
+const (
+	stateDialing     = iota // the host connection is being established
+	stateSynReceived        // SYN-ACK sent to the guest
+	stateEstablished
+)
+
+// tcpConn terminates a guest TCP connection and relays it through a host connection. Data from the guest is only
+// accepted in order, the guest retransmits anything else.
+type tcpConn struct {
+	stack *Stack
+	flow  flow
+	host  net.Conn
+
+	mutex   sync.Mutex
+	changed *sync.Cond // the guest acknowledged data, changed its window or the connection was closed
+	state   int
+	closed  bool
+
+	rcvNxt      uint32 // next sequence number expected from the guest
+	finReceived bool
+	writes      chan []byte // guest data in order, closed after the guest FIN
+	writesDone  bool
+	advertised  int // the last window sent to the guest
+
+	iss      uint32
+	sndUna   uint32 // oldest sequence number not acknowledged by the guest
+	sndNxt   uint32
+	sndWnd   uint32
+	mss      int
+	unacked  []byte // data sent from sndUna on
+	finSent  bool
+	finAcked bool
+	timer    *time.Timer
+	retries  int
+}
+
+// segment is a parsed TCP segment.
+type segment struct {
+	seq, ack uint32
+	flags    byte
+	window   uint16
+	mss      int // from the SYN options, 0 if not set
+	payload  []byte
+}
+
+func parseSegment(packet []byte) (segment, bool) {
+	if len(packet) < tcpHeaderLen {
+		return segment{}, false
+	}
+	offset := int(packet[12]>>4) * 4
+	if offset < tcpHeaderLen || offset > len(packet) {
+		return segment{}, false
+	}
+	s := segment{
+		seq:     binary.BigEndian.Uint32(packet[4:8]),
+		ack:     binary.BigEndian.Uint32(packet[8:12]),
+		flags:   packet[13],
+		window:  binary.BigEndian.Uint16(packet[14:16]),
+		payload: packet[offset:],
+	}
+	options := packet[tcpHeaderLen:offset]
+	for len(options) > 0 {
+		switch options[0] {
+		case 0: // end of options
+			options = nil
+			continue
+		case 1: // no operation
+			options = options[1:]
+			continue
+		}
+		if len(options) < 2 || int(options[1]) < 2 || int(options[1]) > len(options) {
+			break
+		}
+		if options[0] == 2 && options[1] == 4 {
+			s.mss = int(binary.BigEndian.Uint16(options[2:4]))
+		}
+		options = options[options[1]:]
+	}
+	return s, true
+}
+
+func (s *Stack) handleTCP(src, dst [4]byte, packet []byte) {
+	seg, ok := parseSegment(packet)
+	if !ok {
+		return
+	}
+	key := flow{
+		localIP:    src,
+		remoteIP:   dst,
+		localPort:  binary.BigEndian.Uint16(packet[0:2]),
+		remotePort: binary.BigEndian.Uint16(packet[2:4]),
+	}
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	c, ok := s.tcp[key]
+	if !ok {
+		if seg.flags&flagRST != 0 {
+			s.mutex.Unlock()
+			return
+		}
+		if seg.flags&(flagSYN|flagACK) != flagSYN {
+			s.mutex.Unlock()
+			s.reset(key, seg)
+			return
+		}
+		address, err := s.remoteAddress(dst, key.remotePort)
+		if err != nil {
+			s.mutex.Unlock()
+			s.reset(key, seg)
+			return
+		}
+		c = s.newTCPConn(key, seg)
+		s.tcp[key] = c
+		s.mutex.Unlock()
+		go c.dial(address)
+		return
+	}
+	s.mutex.Unlock()
+	c.handle(seg)
+}
+
+// reset answers a segment of an unknown connection.
+func (s *Stack) reset(key flow, seg segment) {
+	c := &tcpConn{stack: s, flow: key}
+	if seg.flags&flagACK != 0 {
+		c.send(flagRST, seg.ack, nil)
+		return
+	}
+	c.rcvNxt = seg.seq + uint32(len(seg.payload))
+	if seg.flags&(flagSYN|flagFIN) != 0 {
+		c.rcvNxt++
+	}
+	c.send(flagRST|flagACK, 0, nil)
+}
+
+func (s *Stack) newTCPConn(key flow, syn segment) *tcpConn {
+	iss := uint32(time.Now().UnixNano())
+	c := &tcpConn{
+		stack:  s,
+		flow:   key,
+		state:  stateDialing,
+		rcvNxt: syn.seq + 1,
+		writes: make(chan []byte, queuedWrites),
+		iss:    iss,
+		sndUna: iss,
+		sndNxt: iss,
+		sndWnd: uint32(syn.window),
+		mss:    syn.mss,
+	}
+	if c.mss == 0 {
+		c.mss = 536 // the default without the option
+	}
+	if c.mss > maxSegment {
+		c.mss = maxSegment
+	}
+	c.changed = sync.NewCond(&c.mutex)
+	return c
+}
+
+// dial connects to the guest destination and answers the guest SYN.
+func (c *tcpConn) dial(address string) {
+	host, err := net.DialTimeout("tcp", address, dialTimeout)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.closed {
+		if err == nil {
+			host.Close()
+		}
+		return
+	}
+	if err != nil {
+		c.send(flagRST|flagACK, 0, nil)
+		c.closeLocked()
+		return
+	}
+	c.host = host
+	c.state = stateSynReceived
+	c.sndNxt = c.iss + 1
+	c.sendSYN()
+	c.armTimer()
+}
+
+func (c *tcpConn) handle(seg segment) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return
+	}
+	if seg.flags&flagRST != 0 {
+		c.closeLocked()
+		return
+	}
+	switch c.state {
+	case stateDialing:
+		return // a retransmitted SYN, it's answered once connected
+	case stateSynReceived:
+		if seg.flags&flagSYN != 0 {
+			c.sendSYN()
+			return
+		}
+		if seg.flags&flagACK == 0 || seg.ack != c.sndNxt {
+			return
+		}
+		c.state = stateEstablished
+		c.sndUna = seg.ack
+		c.sndWnd = uint32(seg.window)
+		c.stopTimer()
+		go c.relay()
+	}
+
+	if seg.flags&flagACK != 0 {
+		c.acknowledged(seg.ack, seg.window)
+	}
+
+	length := uint32(len(seg.payload))
+	if length == 0 && seg.flags&flagFIN == 0 {
+		c.finishIfDone()
+		return
+	}
+	// trim data the guest retransmitted but we already have
+	if diff := int32(c.rcvNxt - seg.seq); diff > 0 && uint32(diff) < length {
+		seg.payload, seg.seq, length = seg.payload[diff:], c.rcvNxt, length-uint32(diff)
+	}
+	if seg.seq == c.rcvNxt && !c.finReceived {
+		accepted := length == 0
+		if length > 0 && !c.writesDone {
+			select {
+			case c.writes <- append([]byte(nil), seg.payload...):
+				c.rcvNxt += length
+				accepted = true
+			default: // the host connection is behind, the guest retransmits
+			}
+		}
+		if accepted && seg.flags&flagFIN != 0 {
+			c.rcvNxt++
+			c.finReceived = true
+			c.closeWrites()
+		}
+	}
+	c.send(flagACK, c.sndNxt, nil)
+	c.finishIfDone()
+}
+
+// acknowledged processes an acknowledgement from the guest.
+func (c *tcpConn) acknowledged(ack uint32, window uint16) {
+	if int32(ack-c.sndUna) > 0 && int32(ack-c.sndNxt) <= 0 {
+		acked := int(ack - c.sndUna)
+		if acked > len(c.unacked) {
+			acked = len(c.unacked)
+		}
+		c.unacked = c.unacked[acked:]
+		if c.finSent && ack == c.sndNxt {
+			c.finAcked = true
+		}
+		c.sndUna = ack
+		c.retries = 0
+		c.stopTimer()
+		if c.outstanding() {
+			c.armTimer()
+		}
+	}
+	c.sndWnd = uint32(window)
+	c.changed.Broadcast()
+}
+
+// relay copies data in both directions once the connection is established.
+func (c *tcpConn) relay() {
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		c.fromHost()
+	}()
+	go func() {
+		defer wg.Done()
+		c.toHost()
+	}()
+	wg.Wait()
+	c.host.Close()
+}
+
+// fromHost sends data from the host connection to the guest, within the guest window.
+func (c *tcpConn) fromHost() {
+	buffer := make([]byte, maxSegment)
+	for {
+		c.mutex.Lock()
+		for !c.closed && c.sndNxt-c.sndUna >= c.sndWnd {
+			c.changed.Wait()
+		}
+		if c.closed {
+			c.mutex.Unlock()
+			return
+		}
+		size := c.sndWnd - (c.sndNxt - c.sndUna)
+		if size > uint32(c.mss) {
+			size = uint32(c.mss)
+		}
+		c.mutex.Unlock()
+
+		n, err := c.host.Read(buffer[:size])
+
+		c.mutex.Lock()
+		if c.closed {
+			c.mutex.Unlock()
+			return
+		}
+		if n > 0 {
+			c.unacked = append(c.unacked, buffer[:n]...)
+			c.send(flagACK|flagPSH, c.sndNxt, buffer[:n])
+			c.sndNxt += uint32(n)
+			c.armTimer()
+		}
+		if err != nil { // EOF or the connection failed, either way the guest gets a FIN
+			c.finSent = true
+			c.send(flagFIN|flagACK, c.sndNxt, nil)
+			c.sndNxt++
+			c.armTimer()
+			c.mutex.Unlock()
+			return
+		}
+		c.mutex.Unlock()
+	}
+}
+
+// toHost writes guest data to the host connection and closes its write side after the guest FIN.
+func (c *tcpConn) toHost() {
+	for data := range c.writes {
+		if _, err := c.host.Write(data); err != nil {
+			c.mutex.Lock()
+			c.send(flagRST|flagACK, c.sndNxt, nil)
+			c.closeLocked()
+			c.mutex.Unlock()
+			return
+		}
+		// the guest only sends once it knows the window opened again
+		c.mutex.Lock()
+		if !c.closed && !c.finReceived && int(c.window())-c.advertised >= maxWindow/4 {
+			c.send(flagACK, c.sndNxt, nil)
+		}
+		c.mutex.Unlock()
+	}
+	if tcp, ok := c.host.(interface{ CloseWrite() error }); ok {
+		_ = tcp.CloseWrite()
+	}
+}
+
+// retransmit resends the oldest unacknowledged segment.
+func (c *tcpConn) retransmit() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.timer = nil
+	if c.closed || (c.state == stateEstablished && !c.outstanding()) {
+		return
+	}
+	c.retries++
+	if c.retries > maxRetries {
+		c.send(flagRST|flagACK, c.sndNxt, nil)
+		c.closeLocked()
+		return
+	}
+	switch {
+	case c.state == stateSynReceived:
+		c.sendSYN()
+	case len(c.unacked) > 0:
+		size := len(c.unacked)
+		if size > c.mss {
+			size = c.mss
+		}
+		c.send(flagACK|flagPSH, c.sndUna, c.unacked[:size])
+	default:
+		c.send(flagFIN|flagACK, c.sndNxt-1, nil)
+	}
+	c.armTimer()
+}
+
+func (c *tcpConn) outstanding() bool {
+	return len(c.unacked) > 0 || (c.finSent && !c.finAcked)
+}
+
+func (c *tcpConn) armTimer() {
+	if c.timer != nil {
+		return
+	}
+	timeout := minRetransmit << uint(c.retries)
+	if timeout > maxRetransmit {
+		timeout = maxRetransmit
+	}
+	c.timer = time.AfterFunc(timeout, c.retransmit)
+}
+
+func (c *tcpConn) stopTimer() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+}
+
+// window returns the receive window, the room left for guest data.
+func (c *tcpConn) window() uint16 {
+	if c.writes == nil {
+		return 0
+	}
+	window := (cap(c.writes) - len(c.writes)) * maxSegment
+	if window > maxWindow {
+		window = maxWindow
+	}
+	return uint16(window)
+}
+
+func (c *tcpConn) sendSYN() {
+	options := make([]byte, 4)
+	options[0], options[1] = 2, 4 // MSS
+	binary.BigEndian.PutUint16(options[2:4], maxSegment)
+	c.sendWithOptions(flagSYN|flagACK, c.iss, options, nil)
+}
+
+func (c *tcpConn) send(flags byte, seq uint32, payload []byte) {
+	c.sendWithOptions(flags, seq, nil, payload)
+}
+
+func (c *tcpConn) sendWithOptions(flags byte, seq uint32, options, payload []byte) {
+	headerLen := tcpHeaderLen + len(options)
+	packet := make([]byte, headerLen+len(payload))
+	binary.BigEndian.PutUint16(packet[0:2], c.flow.remotePort)
+	binary.BigEndian.PutUint16(packet[2:4], c.flow.localPort)
+	binary.BigEndian.PutUint32(packet[4:8], seq)
+	if flags&flagACK != 0 {
+		binary.BigEndian.PutUint32(packet[8:12], c.rcvNxt)
+	}
+	packet[12] = byte(headerLen/4) << 4
+	packet[13] = flags
+	window := c.window()
+	c.advertised = int(window)
+	binary.BigEndian.PutUint16(packet[14:16], window)
+	copy(packet[tcpHeaderLen:], options)
+	copy(packet[headerLen:], payload)
+	sum := checksum(packet, pseudoHeaderSum(c.flow.remoteIP, c.flow.localIP, protocolTCP, len(packet)))
+	binary.BigEndian.PutUint16(packet[16:18], sum)
+	c.stack.writeIPv4(c.flow.remoteIP, c.flow.localIP, protocolTCP, packet)
+}
+
+// finishIfDone removes the connection once both sides closed it.
+func (c *tcpConn) finishIfDone() {
+	if c.finReceived && c.finAcked {
+		c.closed = true
+		c.stopTimer()
+		c.changed.Broadcast()
+		c.remove()
+	}
+}
+
+func (c *tcpConn) closeWrites() {
+	if !c.writesDone {
+		c.writesDone = true
+		close(c.writes)
+	}
+}
+
+func (c *tcpConn) close() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.closeLocked()
+}
+
+// closeLocked aborts the connection, the caller holds the connection lock.
+func (c *tcpConn) closeLocked() {
+	if c.closed {
+		return
+	}
+	c.closed = true
+	c.stopTimer()
+	c.closeWrites()
+	if c.host != nil {
+		c.host.Close()
+	}
+	c.changed.Broadcast()
+	c.remove()
+}
+
+func (c *tcpConn) remove() {
+	c.stack.mutex.Lock()
+	defer c.stack.mutex.Unlock()
+	if c.stack.tcp[c.flow] == c {
+		delete(c.stack.tcp, c.flow)
+	}
+}
@@ -0,0 +1,101 @@
+package slirp
+
+import (
+	"encoding/binary"
+	"log"
+	"net"
+	"time"
+)
+
+const (
+	udpHeaderLen   = 8
+	udpIdleTimeout = time.Minute
+)
+
+// udpFlow relays datagrams between a guest socket and a remote address through a connected host socket.
+type udpFlow struct {
+	stack *Stack
+	flow  flow
+	conn  net.Conn
+}
+
+func (s *Stack) handleUDP(src, dst [4]byte, packet []byte) {
+	if len(packet) < udpHeaderLen {
+		return
+	}
+	length := int(binary.BigEndian.Uint16(packet[4:6]))
+	if length < udpHeaderLen || length > len(packet) {
+		return
+	}
+	key := flow{
+		localIP:    src,
+		remoteIP:   dst,
+		localPort:  binary.BigEndian.Uint16(packet[0:2]),
+		remotePort: binary.BigEndian.Uint16(packet[2:4]),
+	}
+
+	s.mutex.Lock()
+	if s.closed {
+		s.mutex.Unlock()
+		return
+	}
+	f, ok := s.udp[key]
+	if !ok {
+		address, err := s.remoteAddress(dst, key.remotePort)
+		if err != nil {
+			s.mutex.Unlock()
+			return // datagrams to the gateway are dropped
+		}
+		conn, err := net.Dial("udp", address)
+		if err != nil {
+			s.mutex.Unlock()
+			log.Printf("cannot relay UDP to %s: %v", net.IP(dst[:]), err)
+			return
+		}
+		f = &udpFlow{stack: s, flow: key, conn: conn}
+		s.udp[key] = f
+		go f.receive()
+	}
+	s.mutex.Unlock()
+
+	_ = f.conn.SetReadDeadline(time.Now().Add(udpIdleTimeout))
+	if _, err := f.conn.Write(packet[udpHeaderLen:length]); err != nil {
+		f.remove()
+	}
+}
+
+// receive relays datagrams from the remote address to the guest until the flow is idle for too long.
+func (f *udpFlow) receive() {
+	defer f.remove()
+	buffer := make([]byte, MTU-ipHeaderLen-udpHeaderLen)
+	for {
+		n, err := f.conn.Read(buffer)
+		if err != nil {
+			return
+		}
+		datagram := make([]byte, udpHeaderLen+n)
+		binary.BigEndian.PutUint16(datagram[0:2], f.flow.remotePort)
+		binary.BigEndian.PutUint16(datagram[2:4], f.flow.localPort)
+		binary.BigEndian.PutUint16(datagram[4:6], uint16(len(datagram)))
+		copy(datagram[udpHeaderLen:], buffer[:n])
+		sum := checksum(datagram, pseudoHeaderSum(f.flow.remoteIP, f.flow.localIP, protocolUDP, len(datagram)))
+		if sum == 0 {
+			sum = 0xffff // zero means no checksum
+		}
+		binary.BigEndian.PutUint16(datagram[6:8], sum)
+		f.stack.writeIPv4(f.flow.remoteIP, f.flow.localIP, protocolUDP, datagram)
+	}
+}
+
+func (f *udpFlow) remove() {
+	f.stack.mutex.Lock()
+	if f.stack.udp[f.flow] == f {
+		delete(f.stack.udp, f.flow)
+	}
+	f.stack.mutex.Unlock()
+	f.close()
+}
+
+func (f *udpFlow) close() {
+	_ = f.conn.Close()
+}
@@ -15,6 +15,10 @@ func AttachPTSToTerminal(stdin io.Reader, stdout io.Writer) (*PTY, error) {
 	if err != nil {
 		return nil, err
 	}
+	if !Isatty(os.Stdin) { // e.g. container stdio pipes, there are no terminal attributes to take over
+		go Snoop(pty, stdin, stdout)
+		return pty, nil
+	}
 
 	backupTerm, err := Attr(os.Stdin)
 	if err != nil {
@@ -0,0 +1,258 @@
+package volume
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	volumeFile = "volume.json"
+	dataDir    = "data"
+)
+
+var (
+	ErrNotFound = errors.New("volume not found")
+	ErrInUse    = errors.New("volume is in use")
+
+	validName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+type Volume struct {
+	Name       string    `json:"name"`
+	Path       string    `json:"path"` // host directory mounted into containers
+	Created    time.Time `json:"created"`
+	Containers []string  `json:"containers"` // IDs of containers referencing the volume
+}
+
+// Store keeps named volumes as <root>/<name>/data directories, with metadata next to them.
+type Store struct {
+	root  string
+	mutex sync.Mutex
+}
+
+// IsName reports whether a mount source is a volume name rather than a host path.
+func IsName(source string) bool {
+	return validName.MatchString(source)
+}
+
+func NewStore(root string) (*Store, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, fmt.Errorf("cannot create volume directory: %w", err)
+	}
+	return &Store{root: root}, nil
+}
+
+// Create creates a new volume. A random name is generated if name is empty.
+func (s *Store) Create(name string) (*Volume, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if name == "" {
+		name = strings.ReplaceAll(uuid.New().String(), "-", "")
+	}
+	if _, err := s.load(name); err == nil {
+		return nil, fmt.Errorf("volume %s already exists", name)
+	}
+	return s.create(name)
+}
+
+func (s *Store) Get(name string) (*Volume, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.load(name)
+}
+
+func (s *Store) List() ([]*Volume, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.list()
+}
+
+// Remove removes a volume and its data. Volumes referenced by containers can't be removed.
+func (s *Store) Remove(name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, err := s.load(name)
+	if err != nil {
+		return err
+	}
+	if len(v.Containers) != 0 {
+		return fmt.Errorf("%w: %s is referenced by %s", ErrInUse, name, strings.Join(v.Containers, ", "))
+	}
+	return os.RemoveAll(filepath.Join(s.root, name))
+}
+
+// Prune removes all volumes no container references and returns them.
+func (s *Store) Prune() ([]*Volume, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	volumes, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	removed := make([]*Volume, 0)
+	for _, v := range volumes {
+		if len(v.Containers) != 0 {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(s.root, v.Name)); err != nil {
+			return removed, fmt.Errorf("cannot remove volume %s: %w", v.Name, err)
+		}
+		removed = append(removed, v)
+	}
+	return removed, nil
+}
+
+// Acquire marks a volume as used by a container, creating the volume if it doesn't exist.
+func (s *Store) Acquire(name, containerID string) (*Volume, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, err := s.load(name)
+	if errors.Is(err, ErrNotFound) {
+		v, err = s.create(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range v.Containers {
+		if id == containerID {
+			return v, nil
+		}
+	}
+	v.Containers = append(v.Containers, containerID)
+	return v, s.save(v)
+}
+
+// Release removes a container reference from a volume.
+func (s *Store) Release(name, containerID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	v, err := s.load(name)
+	if err != nil {
+		return err
+	}
+	return s.release(v, func(id string) bool { return id == containerID })
+}
+
+// Reconcile drops references of containers keep returns false for, e.g. after a daemon restart.
+func (s *Store) Reconcile(keep func(containerID string) bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	volumes, err := s.list()
+	if err != nil {
+		return err
+	}
+	for _, v := range volumes {
+		if err := s.release(v, func(id string) bool { return !keep(id) }); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Store) release(v *Volume, drop func(containerID string) bool) error {
+	kept := make([]string, 0, len(v.Containers))
+	for _, id := range v.Containers {
+		if !drop(id) {
+			kept = append(kept, id)
+		}
+	}
+	if len(kept) == len(v.Containers) {
+		return nil
+	}
+	v.Containers = kept
+	return s.save(v)
+}
+
+func (s *Store) create(name string) (*Volume, error) {
+	if !IsName(name) {
+		return nil, fmt.Errorf("invalid volume name %s", name)
+	}
+	v := &Volume{
+		Name:       name,
+		Path:       filepath.Join(s.root, name, dataDir),
+		Created:    time.Now(),
+		Containers: make([]string, 0),
+	}
+	if err := os.MkdirAll(v.Path, 0755); err != nil {
+		return nil, fmt.Errorf("cannot create volume %s: %w", name, err)
+	}
+	return v, s.save(v)
+}
+
+func (s *Store) list() ([]*Volume, error) {
+	dirs, err := ioutil.ReadDir(s.root)
+	if err != nil {
+		return nil, err
+	}
+	volumes := make([]*Volume, 0, len(dirs))
+	for _, dir := range dirs {
+		v, err := s.load(dir.Name())
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				continue
+			}
+			return nil, err
+		}
+		volumes = append(volumes, v)
+	}
+	sort.Slice(volumes, func(i, j int) bool {
+		return volumes[i].Name < volumes[j].Name
+	})
+	return volumes, nil
+}
+
+func (s *Store) load(name string) (*Volume, error) {
+	if !IsName(name) {
+		return nil, fmt.Errorf("invalid volume name %s", name)
+	}
+	file, err := os.Open(filepath.Join(s.root, name, volumeFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var v Volume
+	if err := json.NewDecoder(file).Decode(&v); err != nil {
+		return nil, fmt.Errorf("cannot decode volume %s: %w", name, err)
+	}
+	return &v, nil
+}
+
+func (s *Store) save(v *Volume) error {
+	path := filepath.Join(s.root, v.Name, volumeFile)
+	tmp, err := ioutil.TempFile(filepath.Dir(path), volumeFile)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
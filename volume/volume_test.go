@@ -0,0 +1,84 @@
+package volume
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsName(t *testing.T) {
+	for source, expected := range map[string]bool{
+		"cache":       true,
+		"db-data_1.0": true,
+		"0abc":        true,
+		"/srv/data":   false,
+		"./data":      false,
+		"data/sub":    false,
+		".hidden":     false,
+		"-flag":       false,
+		"":            false,
+		"with space":  false,
+	} {
+		if IsName(source) != expected {
+			t.Errorf("IsName(%q) = %v, expected %v", source, !expected, expected)
+		}
+	}
+}
+
+func TestStoreReferences(t *testing.T) {
+	root, err := ioutil.TempDir("", "volumes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	store, err := NewStore(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := store.Acquire("cache", "a") // created on first use
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.Path != filepath.Join(root, "cache", dataDir) {
+		t.Errorf("volume path = %s, expected it under the store root", v.Path)
+	}
+	if _, err := store.Acquire("cache", "b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Acquire("cache", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Create("cache"); err == nil {
+		t.Error("created an existing volume")
+	}
+	if err := store.Remove("cache"); !errors.Is(err, ErrInUse) {
+		t.Errorf("Remove of a used volume = %v, expected %v", err, ErrInUse)
+	}
+
+	if err := store.Release("cache", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := store.Get("cache"); err != nil || len(v.Containers) != 1 || v.Containers[0] != "b" {
+		t.Fatalf("volume after a release = %+v, %v, expected it to be referenced by b", v, err)
+	}
+	if _, err := store.Create(""); err != nil { // unused, pruned below
+		t.Fatal(err)
+	}
+	if err := store.Reconcile(func(id string) bool { return id != "b" }); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := store.Prune()
+	if err != nil || len(removed) != 2 {
+		t.Fatalf("Prune = %d volumes, %v, expected 2", len(removed), err)
+	}
+	if _, err := store.Get("cache"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get of a pruned volume = %v, expected %v", err, ErrNotFound)
+	}
+	if _, err := os.Stat(v.Path); !os.IsNotExist(err) {
+		t.Errorf("data of a pruned volume wasn't removed: %v", err)
+	}
+}